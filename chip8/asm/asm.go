@@ -0,0 +1,874 @@
+// Package asm assembles CHIP-8, SUPER-CHIP and XO-CHIP programs written in the syntax of the Octo assembler,
+// the syntax chip8/disasm produces. The program is assembled to load at 0x200 and execution starts at its first byte.
+//
+// Besides the instructions, the following directives are supported:
+//
+//	: name             defines a label at the current address
+//	:const name value  defines a constant
+//	:calc name { expr }  defines a constant from an expression
+//	:alias name vX     gives register vX another name
+//	:byte value        emits a byte, bare numbers like 0xFF or 0b11110000 are emitted as bytes too
+//	:org address       continues assembling at address
+//	:include "file"    assembles another file, relative to the including file
+//
+// Wherever a number is expected, an expression in braces like { HERE + 2 * WIDTH } can be used,
+// HERE is the address of the current statement.
+package asm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Origin is the address the program is assembled to, the address chip8.CPU.LoadROM loads ROMs at
+const Origin = 0x200
+
+// Error is an assembly error at a position in a source file
+type Error struct {
+	Pos     Pos
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Message
+}
+
+// Program is the result of an assembly
+type Program struct {
+	// ROM holds the bytes from Origin up to the last byte emitted
+	ROM []byte
+	// Labels maps label names to their addresses
+	Labels map[string]uint16
+	// Lines are the source lines of the instructions, in the order they were assembled
+	Lines []Line
+}
+
+// Assemble assembles source, file is the name used in error messages and to resolve includes
+func Assemble(file string, source []byte) (*Program, error) {
+	a := &assembler{
+		here:    Origin,
+		end:     Origin,
+		symbols: make(map[string]symbol),
+		aliases: make(map[string]byte),
+	}
+	if err := a.assemble(file, source); err != nil {
+		return nil, err
+	}
+	return a.finish()
+}
+
+// AssembleFile reads and assembles a source file
+func AssembleFile(path string) (*Program, error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Assemble(path, source)
+}
+
+// operand is the part of an instruction a value is written to
+type operand int
+
+const (
+	operandN    operand = iota // low nibble of the second byte
+	operandX                   // low nibble of the first byte
+	operandNN                  // second byte
+	operandNNN                 // low 12 bits
+	operandLong                // both bytes, the address following F000
+	operandByte                // a data byte
+)
+
+// fixup is a value written once all labels are known
+type fixup struct {
+	addr    int
+	operand operand
+	value   token
+	// here is the address of the statement, the value of HERE
+	here   int
+	negate bool
+}
+
+type symbol struct {
+	value int
+	pos   Pos
+	label bool
+}
+
+type flowKind int
+
+const (
+	flowIf flowKind = iota
+	flowElse
+	flowLoop
+)
+
+// flow is an open if ... begin ... else ... end or loop ... again block
+type flow struct {
+	kind flowKind
+	pos  Pos
+	// addr is the jump to patch at else or end, or the start of a loop
+	addr int
+	// whiles are the jumps out of a loop to patch at again
+	whiles []int
+}
+
+// source is the token stream of the file being assembled
+type source struct {
+	file   string
+	tokens []token
+	next   int
+	end    Pos
+}
+
+type assembler struct {
+	memory  [0x10000]byte
+	here    int
+	end     int
+	symbols map[string]symbol
+	aliases map[string]byte
+	fixups  []fixup
+	flows   []flow
+	// files are the files being assembled, the last one is the current file
+	files []*source
+	src   *source
+	lines []Line
+	// Position of the statement of the last line
+	linePos Pos
+}
+
+func (a *assembler) assemble(file string, data []byte) error {
+	tokens, end, err := tokenize(file, data)
+	if err != nil {
+		return err
+	}
+	src := &source{file: file, tokens: tokens, end: end}
+	a.files = append(a.files, src)
+	previous := a.src
+	a.src = src
+	defer func() {
+		a.files = a.files[:len(a.files)-1]
+		a.src = previous
+	}()
+	for src.next < len(src.tokens) {
+		if err := a.statement(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *assembler) finish() (*Program, error) {
+	if len(a.flows) > 0 {
+		open := a.flows[len(a.flows)-1]
+		if open.kind == flowLoop {
+			return nil, &Error{open.pos, "loop without again"}
+		}
+		return nil, &Error{open.pos, "begin without end"}
+	}
+	for _, f := range a.fixups {
+		if err := a.resolve(f); err != nil {
+			return nil, err
+		}
+	}
+	program := &Program{
+		ROM:    append([]byte(nil), a.memory[Origin:a.end]...),
+		Labels: make(map[string]uint16),
+		Lines:  a.lines,
+	}
+	for name, s := range a.symbols {
+		if s.label {
+			program.Labels[name] = uint16(s.value)
+		}
+	}
+	return program, nil
+}
+
+// take returns the next token, what describes the expected token in the error at the end of the file
+func (a *assembler) take(what string) (token, error) {
+	if a.src.next == len(a.src.tokens) {
+		return token{}, &Error{a.src.end, "unexpected end of file, expected " + what}
+	}
+	t := a.src.tokens[a.src.next]
+	a.src.next++
+	return t, nil
+}
+
+// peek returns the text of the next word, or "" at the end of the file
+func (a *assembler) peek() string {
+	if a.src.next == len(a.src.tokens) || a.src.tokens[a.src.next].kind != tokenWord {
+		return ""
+	}
+	return a.src.tokens[a.src.next].text
+}
+
+func (a *assembler) expectWord(word string) error {
+	t, err := a.take(word)
+	if err != nil {
+		return err
+	}
+	if t.kind != tokenWord || t.text != word {
+		return &Error{t.pos, fmt.Sprintf("expected %s, found %s", word, describe(t))}
+	}
+	return nil
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokenString:
+		return fmt.Sprintf("%q", t.text)
+	case tokenExpr:
+		return "{" + t.text + "}"
+	}
+	return t.text
+}
+
+func (a *assembler) register() (uint16, error) {
+	t, err := a.take("a register")
+	if err != nil {
+		return 0, err
+	}
+	if x, ok := a.parseRegister(t); ok {
+		return uint16(x), nil
+	}
+	return 0, &Error{t.pos, fmt.Sprintf("expected a register, found %s", describe(t))}
+}
+
+func (a *assembler) parseRegister(t token) (byte, bool) {
+	if t.kind != tokenWord {
+		return 0, false
+	}
+	if x, ok := a.aliases[t.text]; ok {
+		return x, true
+	}
+	text := strings.ToLower(t.text)
+	if len(text) != 2 || text[0] != 'v' {
+		return 0, false
+	}
+	switch c := text[1]; {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	}
+	return 0, false
+}
+
+// value takes a value token, numbers, names and braced expressions are accepted
+func (a *assembler) value() (token, error) {
+	t, err := a.take("a value")
+	if err != nil {
+		return token{}, err
+	}
+	if t.kind == tokenString {
+		return token{}, &Error{t.pos, fmt.Sprintf("expected a value, found %s", describe(t))}
+	}
+	return t, nil
+}
+
+// eval evaluates a value token with the symbols defined so far
+func (a *assembler) eval(t token, here int) (int, error) {
+	lookup := func(name string) (int, bool) {
+		if name == "HERE" {
+			return here, true
+		}
+		s, ok := a.symbols[name]
+		return s.value, ok
+	}
+	if t.kind == tokenWord {
+		if value, ok := parseNumber(t.text); ok {
+			return value, nil
+		}
+		if value, ok := lookup(t.text); ok {
+			return value, nil
+		}
+		return 0, &Error{t.pos, "undefined name " + t.text}
+	}
+	value, err := evalExpr(t.text, lookup)
+	if err != nil {
+		e := err.(*exprError)
+		pos := t.pos
+		// Positions inside an expression spanning lines are reported at its first line
+		if !strings.Contains(t.text[:e.offset], "\n") {
+			pos.Column += e.offset
+		}
+		return 0, &Error{pos, e.message}
+	}
+	return value, nil
+}
+
+// name takes the name of a new symbol
+func (a *assembler) name() (token, error) {
+	t, err := a.take("a name")
+	if err != nil {
+		return token{}, err
+	}
+	if t.kind != tokenWord || !isName(t.text) {
+		return token{}, &Error{t.pos, fmt.Sprintf("invalid name %s", describe(t))}
+	}
+	if _, ok := a.parseRegister(t); ok {
+		return token{}, &Error{t.pos, fmt.Sprintf("invalid name %s, it is a register", t.text)}
+	}
+	if s, ok := a.symbols[t.text]; ok {
+		return token{}, &Error{t.pos, fmt.Sprintf("%s redefined, previous definition at %s", t.text, s.pos)}
+	}
+	return t, nil
+}
+
+func isName(text string) bool {
+	if text == "" || text == "HERE" || text[0] == '-' || text[0] >= '0' && text[0] <= '9' {
+		return false
+	}
+	// Names may contain dashes like Octo's, they cannot be used in expressions though
+	for i := 0; i < len(text); i++ {
+		if !isNameChar(text[i]) && text[i] != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *assembler) define(t token, value int, label bool) {
+	a.symbols[t.text] = symbol{value: value, pos: t.pos, label: label}
+}
+
+// emit writes an instruction or data at the current address
+func (a *assembler) emit(pos Pos, data ...byte) error {
+	if a.here+len(data) > len(a.memory) {
+		return &Error{pos, "program does not fit in memory"}
+	}
+	copy(a.memory[a.here:], data)
+	a.here += len(data)
+	if a.here > a.end {
+		a.end = a.here
+	}
+	return nil
+}
+
+// emitOpcode writes an instruction at the current address, the first instruction of a statement is added to the lines
+func (a *assembler) emitOpcode(pos Pos, opcode uint16) error {
+	if pos != a.linePos {
+		a.lines = append(a.lines, Line{Addr: uint16(a.here), File: pos.File, Line: pos.Line})
+		a.linePos = pos
+	}
+	return a.emit(pos, byte(opcode>>8), byte(opcode))
+}
+
+// emitWithValue emits an instruction whose operand is written once all labels are known
+func (a *assembler) emitWithValue(pos Pos, opcode uint16, operand operand, value token, negate bool) error {
+	f := fixup{addr: a.here, operand: operand, value: value, here: a.here, negate: negate}
+	if err := a.emitOpcode(pos, opcode); err != nil {
+		return err
+	}
+	a.fixups = append(a.fixups, f)
+	return nil
+}
+
+func (a *assembler) resolve(f fixup) error {
+	value, err := a.eval(f.value, f.here)
+	if err != nil {
+		return err
+	}
+	if f.negate {
+		value = -value
+	}
+	min, max, what := -128, 255, "a byte"
+	switch f.operand {
+	case operandN, operandX:
+		min, max, what = 0, 15, "a nibble"
+	case operandNNN:
+		min, max, what = 0, 0xFFF, "a 12-bit address"
+	case operandLong:
+		min, max, what = 0, 0xFFFF, "a 16-bit address"
+	}
+	if value < min || value > max {
+		return &Error{f.value.pos, fmt.Sprintf("value %d out of range for %s", value, what)}
+	}
+	switch f.operand {
+	case operandN:
+		a.memory[f.addr+1] |= byte(value)
+	case operandX:
+		a.memory[f.addr] |= byte(value)
+	case operandNN:
+		a.memory[f.addr+1] = byte(value)
+	case operandNNN:
+		a.memory[f.addr] |= byte(value >> 8)
+		a.memory[f.addr+1] = byte(value)
+	case operandLong:
+		a.memory[f.addr] = byte(value >> 8)
+		a.memory[f.addr+1] = byte(value)
+	case operandByte:
+		a.memory[f.addr] = byte(value)
+	}
+	return nil
+}
+
+// patchJump points the jump at addr to the current address
+func (a *assembler) patchJump(pos Pos, addr int) error {
+	if a.here > 0xFFF {
+		return &Error{pos, fmt.Sprintf("jump target %X is out of reach", a.here)}
+	}
+	a.memory[addr] = 0x10 | byte(a.here>>8)
+	a.memory[addr+1] = byte(a.here)
+	return nil
+}
+
+func (a *assembler) statement() error {
+	t, _ := a.take("a statement")
+	pos := t.pos
+	if t.kind == tokenString {
+		return &Error{pos, fmt.Sprintf("unexpected %s", describe(t))}
+	}
+	if t.kind == tokenExpr {
+		return a.emitData(t)
+	}
+	if x, ok := a.parseRegister(t); ok {
+		return a.registerStatement(pos, uint16(x))
+	}
+	switch t.text {
+	case ":":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		a.define(name, a.here, true)
+		return nil
+	case ":const", ":calc":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		v, err := a.value()
+		if err != nil {
+			return err
+		}
+		if t.text == ":calc" && v.kind != tokenExpr {
+			return &Error{v.pos, fmt.Sprintf("expected an expression in braces, found %s", describe(v))}
+		}
+		value, err := a.eval(v, a.here)
+		if err != nil {
+			return err
+		}
+		a.define(name, value, false)
+		return nil
+	case ":alias":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		a.aliases[name.text] = byte(x)
+		return nil
+	case ":org":
+		v, err := a.value()
+		if err != nil {
+			return err
+		}
+		addr, err := a.eval(v, a.here)
+		if err != nil {
+			return err
+		}
+		if addr < Origin || addr > len(a.memory) {
+			return &Error{v.pos, fmt.Sprintf("address %X outside of %X-%X", addr, Origin, len(a.memory))}
+		}
+		a.here = addr
+		return nil
+	case ":byte":
+		v, err := a.value()
+		if err != nil {
+			return err
+		}
+		return a.emitData(v)
+	case ":include":
+		return a.include()
+	case ":call":
+		v, err := a.value()
+		if err != nil {
+			return err
+		}
+		return a.emitWithValue(pos, 0x2000, operandNNN, v, false)
+	case "clear":
+		return a.emitOpcode(pos, 0x00E0)
+	case "return", ";":
+		return a.emitOpcode(pos, 0x00EE)
+	case "scroll-right":
+		return a.emitOpcode(pos, 0x00FB)
+	case "scroll-left":
+		return a.emitOpcode(pos, 0x00FC)
+	case "exit":
+		return a.emitOpcode(pos, 0x00FD)
+	case "lores":
+		return a.emitOpcode(pos, 0x00FE)
+	case "hires":
+		return a.emitOpcode(pos, 0x00FF)
+	case "audio":
+		return a.emitOpcode(pos, 0xF002)
+	case "scroll-down", "scroll-up":
+		v, err := a.value()
+		if err != nil {
+			return err
+		}
+		opcode := uint16(0x00C0)
+		if t.text == "scroll-up" {
+			opcode = 0x00D0
+		}
+		return a.emitWithValue(pos, opcode, operandN, v, false)
+	case "plane":
+		v, err := a.value()
+		if err != nil {
+			return err
+		}
+		return a.emitWithValue(pos, 0xF001, operandX, v, false)
+	case "jump", "jump0":
+		v, err := a.value()
+		if err != nil {
+			return err
+		}
+		opcode := uint16(0x1000)
+		if t.text == "jump0" {
+			opcode = 0xB000
+		}
+		return a.emitWithValue(pos, opcode, operandNNN, v, false)
+	case "sprite":
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		y, err := a.register()
+		if err != nil {
+			return err
+		}
+		v, err := a.value()
+		if err != nil {
+			return err
+		}
+		return a.emitWithValue(pos, 0xD000|x<<8|y<<4, operandN, v, false)
+	case "save", "load":
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		if a.peek() == "-" {
+			a.src.next++
+			y, err := a.register()
+			if err != nil {
+				return err
+			}
+			opcode := uint16(0x5002)
+			if t.text == "load" {
+				opcode = 0x5003
+			}
+			return a.emitOpcode(pos, opcode|x<<8|y<<4)
+		}
+		opcode := uint16(0xF055)
+		if t.text == "load" {
+			opcode = 0xF065
+		}
+		return a.emitOpcode(pos, opcode|x<<8)
+	case "saveflags", "loadflags", "bcd":
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		opcode := map[string]uint16{"saveflags": 0xF075, "loadflags": 0xF085, "bcd": 0xF033}[t.text]
+		return a.emitOpcode(pos, opcode|x<<8)
+	case "delay", "buzzer", "pitch":
+		if err := a.expectWord(":="); err != nil {
+			return err
+		}
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		opcode := map[string]uint16{"delay": 0xF015, "buzzer": 0xF018, "pitch": 0xF03A}[t.text]
+		return a.emitOpcode(pos, opcode|x<<8)
+	case "i":
+		return a.indexStatement(pos)
+	case "if":
+		return a.ifStatement(pos)
+	case "else":
+		if len(a.flows) == 0 || a.flows[len(a.flows)-1].kind != flowIf {
+			return &Error{pos, "else without if ... begin"}
+		}
+		top := &a.flows[len(a.flows)-1]
+		jump := a.here
+		if err := a.emitOpcode(pos, 0x1000); err != nil {
+			return err
+		}
+		if err := a.patchJump(pos, top.addr); err != nil {
+			return err
+		}
+		top.kind, top.addr = flowElse, jump
+		return nil
+	case "end":
+		if len(a.flows) == 0 || a.flows[len(a.flows)-1].kind == flowLoop {
+			return &Error{pos, "end without if ... begin"}
+		}
+		if err := a.patchJump(pos, a.flows[len(a.flows)-1].addr); err != nil {
+			return err
+		}
+		a.flows = a.flows[:len(a.flows)-1]
+		return nil
+	case "loop":
+		a.flows = append(a.flows, flow{kind: flowLoop, pos: pos, addr: a.here})
+		return nil
+	case "while":
+		loop := -1
+		for i := len(a.flows) - 1; i >= 0; i-- {
+			if a.flows[i].kind == flowLoop {
+				loop = i
+				break
+			}
+		}
+		if loop < 0 {
+			return &Error{pos, "while outside of loop"}
+		}
+		// Skip the jump out of the loop while the condition holds
+		if err := a.condition(pos, true); err != nil {
+			return err
+		}
+		a.flows[loop].whiles = append(a.flows[loop].whiles, a.here)
+		return a.emitOpcode(pos, 0x1000)
+	case "again":
+		if len(a.flows) == 0 || a.flows[len(a.flows)-1].kind != flowLoop {
+			return &Error{pos, "again without loop"}
+		}
+		loop := a.flows[len(a.flows)-1]
+		a.flows = a.flows[:len(a.flows)-1]
+		if loop.addr > 0xFFF {
+			return &Error{loop.pos, fmt.Sprintf("jump target %X is out of reach", loop.addr)}
+		}
+		if err := a.emitOpcode(pos, 0x1000|uint16(loop.addr)); err != nil {
+			return err
+		}
+		for _, addr := range loop.whiles {
+			if err := a.patchJump(pos, addr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, ok := parseNumber(t.text); ok {
+		return a.emitData(t)
+	}
+	if isName(t.text) {
+		// A bare label name calls the subroutine
+		return a.emitWithValue(pos, 0x2000, operandNNN, t, false)
+	}
+	return &Error{pos, "unexpected " + t.text}
+}
+
+func (a *assembler) emitData(value token) error {
+	f := fixup{addr: a.here, operand: operandByte, value: value, here: a.here}
+	if err := a.emit(value.pos, 0); err != nil {
+		return err
+	}
+	a.fixups = append(a.fixups, f)
+	return nil
+}
+
+// registerStatement assembles the assignments and arithmetic on vX
+func (a *assembler) registerStatement(pos Pos, x uint16) error {
+	op, err := a.take("an operator")
+	if err != nil {
+		return err
+	}
+	opcodes := map[string]uint16{
+		"|=":  0x8001,
+		"&=":  0x8002,
+		"^=":  0x8003,
+		"+=":  0x8004,
+		"-=":  0x8005,
+		">>=": 0x8006,
+		"=-":  0x8007,
+		"<<=": 0x800E,
+		":=":  0x8000,
+	}
+	opcode, ok := opcodes[op.text]
+	if op.kind != tokenWord || !ok {
+		return &Error{op.pos, fmt.Sprintf("expected an operator, found %s", describe(op))}
+	}
+	v, err := a.value()
+	if err != nil {
+		return err
+	}
+	if y, ok := a.parseRegister(v); ok {
+		return a.emitOpcode(pos, opcode|x<<8|uint16(y)<<4)
+	}
+	switch op.text {
+	case ":=":
+		switch v.text {
+		case "random":
+			mask, err := a.value()
+			if err != nil {
+				return err
+			}
+			return a.emitWithValue(pos, 0xC000|x<<8, operandNN, mask, false)
+		case "delay":
+			return a.emitOpcode(pos, 0xF007|x<<8)
+		case "key":
+			return a.emitOpcode(pos, 0xF00A|x<<8)
+		}
+		return a.emitWithValue(pos, 0x6000|x<<8, operandNN, v, false)
+	case "+=":
+		return a.emitWithValue(pos, 0x7000|x<<8, operandNN, v, false)
+	case "-=":
+		// There is no subtraction of a constant, add its two's complement
+		return a.emitWithValue(pos, 0x7000|x<<8, operandNN, v, true)
+	}
+	return &Error{v.pos, fmt.Sprintf("expected a register, found %s", describe(v))}
+}
+
+// indexStatement assembles the assignments to I
+func (a *assembler) indexStatement(pos Pos) error {
+	op, err := a.take("an operator")
+	if err != nil {
+		return err
+	}
+	switch op.text {
+	case "+=":
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		return a.emitOpcode(pos, 0xF01E|x<<8)
+	case ":=":
+		switch a.peek() {
+		case "hex", "bighex":
+			kind, _ := a.take("")
+			x, err := a.register()
+			if err != nil {
+				return err
+			}
+			if kind.text == "hex" {
+				return a.emitOpcode(pos, 0xF029|x<<8)
+			}
+			return a.emitOpcode(pos, 0xF030|x<<8)
+		case "long":
+			a.src.next++
+			v, err := a.value()
+			if err != nil {
+				return err
+			}
+			if err := a.emitOpcode(pos, 0xF000); err != nil {
+				return err
+			}
+			f := fixup{addr: a.here, operand: operandLong, value: v, here: a.here - 2}
+			if err := a.emit(pos, 0, 0); err != nil {
+				return err
+			}
+			a.fixups = append(a.fixups, f)
+			return nil
+		}
+		v, err := a.value()
+		if err != nil {
+			return err
+		}
+		return a.emitWithValue(pos, 0xA000, operandNNN, v, false)
+	}
+	return &Error{op.pos, fmt.Sprintf("expected := or +=, found %s", describe(op))}
+}
+
+// ifStatement assembles if ... then and if ... begin
+func (a *assembler) ifStatement(pos Pos) error {
+	// then skips the next instruction when the condition does not hold, begin skips the jump
+	// to the else or end block when it holds
+	if err := a.condition(pos, a.ifKeyword() == "begin"); err != nil {
+		return err
+	}
+	t, err := a.take("then or begin")
+	if err != nil {
+		return err
+	}
+	switch t.text {
+	case "then":
+		return nil
+	case "begin":
+		a.flows = append(a.flows, flow{kind: flowIf, pos: pos, addr: a.here})
+		return a.emitOpcode(pos, 0x1000)
+	}
+	return &Error{t.pos, fmt.Sprintf("expected then or begin, found %s", describe(t))}
+}
+
+// ifKeyword looks ahead for the then or begin ending the condition of an if, it returns "" without one
+func (a *assembler) ifKeyword() string {
+	for _, t := range a.src.tokens[a.src.next:] {
+		if t.kind == tokenWord && (t.text == "then" || t.text == "begin") {
+			return t.text
+		}
+	}
+	return ""
+}
+
+// condition emits the skip instruction that skips the next instruction when the condition does not hold,
+// or when it holds if inverted
+func (a *assembler) condition(pos Pos, inverted bool) error {
+	x, err := a.register()
+	if err != nil {
+		return err
+	}
+	op, err := a.take("a comparison")
+	if err != nil {
+		return err
+	}
+	// Each pair holds the instruction skipping when the condition does not hold and its inverse
+	var skip [2]uint16
+	switch op.text {
+	case "key":
+		skip = [2]uint16{0xE0A1, 0xE09E}
+	case "-key":
+		skip = [2]uint16{0xE09E, 0xE0A1}
+	case "==", "!=":
+		v, err := a.value()
+		if err != nil {
+			return err
+		}
+		skipWhenEqual := (op.text == "!=") != inverted
+		if y, ok := a.parseRegister(v); ok {
+			opcode := uint16(0x9000)
+			if skipWhenEqual {
+				opcode = 0x5000
+			}
+			return a.emitOpcode(pos, opcode|x<<8|uint16(y)<<4)
+		}
+		opcode := uint16(0x4000)
+		if skipWhenEqual {
+			opcode = 0x3000
+		}
+		return a.emitWithValue(pos, opcode|x<<8, operandNN, v, false)
+	default:
+		return &Error{op.pos, fmt.Sprintf("expected ==, !=, key or -key, found %s", describe(op))}
+	}
+	if inverted {
+		skip[0] = skip[1]
+	}
+	return a.emitOpcode(pos, skip[0]|x<<8)
+}
+
+func (a *assembler) include() error {
+	t, err := a.take("a file name")
+	if err != nil {
+		return err
+	}
+	if t.kind != tokenString {
+		return &Error{t.pos, fmt.Sprintf("expected a file name in quotes, found %s", describe(t))}
+	}
+	path := t.text
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(a.src.file), path)
+	}
+	for _, file := range a.files {
+		if filepath.Clean(file.file) == path {
+			return &Error{t.pos, "include cycle: " + t.text}
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return &Error{t.pos, err.Error()}
+	}
+	return a.assemble(path, data)
+}
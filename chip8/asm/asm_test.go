@@ -0,0 +1,268 @@
+package asm
+
+import (
+	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/disasm"
+	"bytes"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func assembleHex(t *testing.T, source string) string {
+	program, err := Assemble("test.8o", []byte(source))
+	if !assert.Nil(t, err) {
+		return ""
+	}
+	return strings.ToUpper(hex.EncodeToString(program.ROM))
+}
+
+func TestAssembleInstructions(t *testing.T) {
+	tests := []struct {
+		source string
+		opcode string
+	}{
+		{"clear", "00E0"},
+		{"return", "00EE"},
+		{";", "00EE"},
+		{"scroll-down 3", "00C3"},
+		{"scroll-up 2", "00D2"},
+		{"scroll-right", "00FB"},
+		{"scroll-left", "00FC"},
+		{"exit", "00FD"},
+		{"lores", "00FE"},
+		{"hires", "00FF"},
+		{"jump 0x345", "1345"},
+		{"jump0 0x300", "B300"},
+		{":call 0x456", "2456"},
+		{"v3 := 0x12", "6312"},
+		{"v3 += 5", "7305"},
+		{"v3 -= 1", "73FF"},
+		{"va := vb", "8AB0"},
+		{"va |= vb", "8AB1"},
+		{"va &= vb", "8AB2"},
+		{"va ^= vb", "8AB3"},
+		{"va += vb", "8AB4"},
+		{"va -= vb", "8AB5"},
+		{"va >>= vb", "8AB6"},
+		{"va =- vb", "8AB7"},
+		{"va <<= vb", "8ABE"},
+		{"V4 := random 0x0F", "C40F"},
+		{"v5 := delay", "F507"},
+		{"v6 := key", "F60A"},
+		{"i := 0x123", "A123"},
+		{"i := long 0x1234", "F0001234"},
+		{"i += v7", "F71E"},
+		{"i := hex v8", "F829"},
+		{"i := bighex v9", "F930"},
+		{"delay := v1", "F115"},
+		{"buzzer := v2", "F218"},
+		{"pitch := v3", "F33A"},
+		{"sprite v1 v2 15", "D12F"},
+		{"save v5", "F555"},
+		{"load v5", "F565"},
+		{"save v1 - v3", "5132"},
+		{"load v3 - v1", "5313"},
+		{"saveflags v2", "F275"},
+		{"loadflags v2", "F285"},
+		{"bcd vf", "FF33"},
+		{"plane 3", "F301"},
+		{"audio", "F002"},
+		{"if v1 == 5 then", "4105"},
+		{"if v1 != 5 then", "3105"},
+		{"if v1 == v2 then", "9120"},
+		{"if v1 != v2 then", "5120"},
+		{"if v1 key then", "E1A1"},
+		{"if v1 -key then", "E19E"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.opcode, assembleHex(t, test.source), test.source)
+	}
+}
+
+func TestAssembleControlFlow(t *testing.T) {
+	source := `
+loop
+	v0 += 1
+	while v0 != 10
+	if v0 == 5 begin
+		v1 := 1
+	else
+		v1 := 2
+	end
+again
+exit
+`
+	assert.Equal(t, "7001"+"400A"+"1212"+"3005"+"120E"+"6101"+"1210"+"6102"+"1200"+"00FD", assembleHex(t, source))
+}
+
+func TestAssembledBranchesRun(t *testing.T) {
+	source := `
+	if v0 == 1 begin
+		v1 := 0xAA
+	else
+		v1 := 0xBB
+	end
+	if v0 != 1 then v2 := 0xCC
+	exit
+`
+	program, err := Assemble("test.8o", []byte(source))
+	assert.Nil(t, err)
+	for v0, want := range map[byte][2]byte{1: {0xAA, 0x00}, 2: {0xBB, 0xCC}} {
+		cpu := chip8.NewCPU()
+		copy(cpu.Memory.Memory[0x200:], program.ROM)
+		cpu.Register.V[0] = v0
+		for !cpu.Exited {
+			assert.Nil(t, cpu.Cycle())
+		}
+		assert.Equal(t, want, [2]byte{cpu.Register.V[1], cpu.Register.V[2]}, "v0 = %d", v0)
+	}
+}
+
+func TestAssembleDirectives(t *testing.T) {
+	source := `
+:alias x v3
+:const SPEED 2
+: main
+	x := SPEED
+	i := data
+	jump { HERE + 4 }
+	:byte 0xAA
+	:byte { -1 }
+	x += { SPEED * 3 + 1 }
+: data
+	0xF0 0b1001
+:calc END { data + 2 }
+:org { END + 2 }
+	:call main
+`
+	program, err := Assemble("test.8o", []byte(source))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{
+		0x63, 0x02, 0xA2, 0x0A, 0x12, 0x08, 0xAA, 0xFF,
+		0x73, 0x07, 0xF0, 0x09, 0x00, 0x00, 0x22, 0x00,
+	}, program.ROM)
+	assert.Equal(t, map[string]uint16{"main": 0x200, "data": 0x20A}, program.Labels)
+}
+
+func TestAssembleFile(t *testing.T) {
+	program, err := AssembleFile("testdata/smile.8o")
+	assert.Nil(t, err)
+	expected, _ := ioutil.ReadFile("testdata/smile.ch8")
+	assert.Equal(t, expected, program.ROM)
+	assert.Equal(t, uint16(0x20E), program.Labels["smile"])
+	file := filepath.Join("testdata", "smile.8o")
+	assert.Equal(t, []Line{
+		{0x200, file, 5},
+		{0x202, file, 6},
+		{0x204, file, 7},
+		{0x206, file, 8},
+		{0x208, file, 9},
+		{0x20A, file, 10},
+		{0x20C, file, 11},
+	}, program.Lines)
+}
+
+func TestSymbols(t *testing.T) {
+	program, err := Assemble("test.8o", []byte(": main\n\ti := long main\n\tif v0 == 1 then jump main\n"))
+	assert.Nil(t, err)
+	// The second half of i := long is not a line of its own, while the statement after then is
+	assert.Equal(t, []Line{{0x200, "test.8o", 2}, {0x204, "test.8o", 3}, {0x206, "test.8o", 3}}, program.Lines)
+
+	var buf bytes.Buffer
+	assert.Nil(t, WriteSymbols(&buf, program.Symbols()))
+	symbols, err := ReadSymbols(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, program.Symbols(), symbols)
+	assert.Equal(t, "roms/demo.sym", SymbolsPath("roms/demo.ch8"))
+	assert.Equal(t, "roms/PONG.sym", SymbolsPath("roms/PONG"))
+
+	symbols = Symbols{Labels: map[string]uint16{"main": 0x200, "start": 0x200, "draw": 0x210}}
+	assert.Equal(t, "", symbols.Symbol(0x1FE))
+	assert.Equal(t, "main", symbols.Symbol(0x200))
+	assert.Equal(t, "main+0x4", symbols.Symbol(0x204))
+	assert.Equal(t, "draw+0x2", symbols.Symbol(0x212))
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"v0 := 256", "test.8o:1:7: value 256 out of range for a byte"},
+		{"jump nowhere", "test.8o:1:6: undefined name nowhere"},
+		{"sprite v0 v1 16", "test.8o:1:14: value 16 out of range for a nibble"},
+		{"v0 := { 1 + }", "test.8o:1:13: unexpected end of expression"},
+		{"v0 := { 1 / 0 }", "test.8o:1:11: division by zero"},
+		{"clear\n  sprite v0 v1", "test.8o:2:15: unexpected end of file, expected a value"},
+		{": a\n: a", "test.8o:2:3: a redefined, previous definition at test.8o:1:3"},
+		{": v1", "test.8o:1:3: invalid name v1, it is a register"},
+		{"loop\nv0 += 1", "test.8o:1:1: loop without again"},
+		{"if v0 == 1 begin", "test.8o:1:1: begin without end"},
+		{"else", "test.8o:1:1: else without if ... begin"},
+		{"while v0 == 1", "test.8o:1:1: while outside of loop"},
+		{"v0 <- v1", "test.8o:1:4: expected an operator, found <-"},
+		{"i := hex 5", "test.8o:1:10: expected a register, found 5"},
+		{"if v0 < 1 then", "test.8o:1:7: expected ==, !=, key or -key, found <"},
+		{"v0 := \"a", "test.8o:1:7: unterminated string"},
+		{"v0 := { 1", "test.8o:1:7: unterminated expression, missing }"},
+	}
+	for _, test := range tests {
+		_, err := Assemble("test.8o", []byte(test.source))
+		if assert.NotNil(t, err, test.source) {
+			assert.Equal(t, test.err, err.Error(), test.source)
+		}
+	}
+
+	_, err := AssembleFile(filepath.Join("testdata", "cycle.8o"))
+	assert.Equal(t, filepath.Join("testdata", "cycle.8o")+":1:10: include cycle: cycle.8o", err.Error())
+}
+
+func TestAssembledROMRuns(t *testing.T) {
+	source := `
+	v0 := 3
+	v1 := 4
+	v0 += v1
+	i := result
+	save v0
+	exit
+: result
+	0
+`
+	program, err := Assemble("test.8o", []byte(source))
+	assert.Nil(t, err)
+	dir, err := ioutil.TempDir("", "asm")
+	assert.Nil(t, err)
+	path := filepath.Join(dir, "test.ch8")
+	assert.Nil(t, ioutil.WriteFile(path, program.ROM, 0644))
+
+	cpu := chip8.NewCPU()
+	assert.Nil(t, cpu.LoadROM(path))
+	for !cpu.Exited {
+		assert.Nil(t, cpu.Cycle())
+	}
+	assert.Equal(t, byte(7), cpu.Register.V[0])
+	assert.Equal(t, byte(7), cpu.Memory.Memory[program.Labels["result"]])
+}
+
+// Disassembling a ROM in Octo syntax and assembling the listing gives back the ROM
+func TestDisassemblyRoundTrip(t *testing.T) {
+	roms, _ := filepath.Glob("../../roms/*")
+	assert.NotEmpty(t, roms)
+	for _, path := range roms {
+		if strings.Contains(path, ".slot") {
+			continue
+		}
+		rom, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		var listing bytes.Buffer
+		assert.Nil(t, disasm.Analyze(rom, Origin).Write(&listing, disasm.Octo))
+		program, err := Assemble(path+".8o", listing.Bytes())
+		if assert.Nil(t, err, path) {
+			assert.Equal(t, rom, program.ROM, path)
+		}
+	}
+}
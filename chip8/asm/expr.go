@@ -0,0 +1,191 @@
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// exprError is an error at a byte offset inside an expression
+type exprError struct {
+	offset  int
+	message string
+}
+
+func (e *exprError) Error() string {
+	return e.message
+}
+
+// exprParser evaluates infix expressions with the operators of Go, from lowest to highest precedence:
+//
+//	|  ^  &  << >>  + -  * / %
+//
+// and the unary operators - and ~. Names are looked up with lookup.
+type exprParser struct {
+	text   string
+	offset int
+	lookup func(name string) (int, bool)
+}
+
+func evalExpr(text string, lookup func(name string) (int, bool)) (int, error) {
+	p := &exprParser{text: text, lookup: lookup}
+	value, err := p.binary(0)
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.offset < len(p.text) {
+		return 0, &exprError{p.offset, fmt.Sprintf("unexpected %q in expression", p.text[p.offset:])}
+	}
+	return value, nil
+}
+
+var binaryOperators = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) skipSpace() {
+	for p.offset < len(p.text) && isSpace(p.text[p.offset]) {
+		p.offset++
+	}
+}
+
+func (p *exprParser) binary(level int) (int, error) {
+	if level == len(binaryOperators) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		p.skipSpace()
+		operator := ""
+		for _, op := range binaryOperators[level] {
+			if strings.HasPrefix(p.text[p.offset:], op) {
+				operator = op
+				break
+			}
+		}
+		if operator == "" {
+			return left, nil
+		}
+		offset := p.offset
+		p.offset += len(operator)
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return 0, err
+		}
+		switch operator {
+		case "|":
+			left |= right
+		case "^":
+			left ^= right
+		case "&":
+			left &= right
+		case "<<", ">>":
+			if right < 0 || right > 31 {
+				return 0, &exprError{offset, fmt.Sprintf("invalid shift count %d", right)}
+			}
+			if operator == "<<" {
+				left <<= uint(right)
+			} else {
+				left >>= uint(right)
+			}
+		case "+":
+			left += right
+		case "-":
+			left -= right
+		case "*":
+			left *= right
+		case "/", "%":
+			if right == 0 {
+				return 0, &exprError{offset, "division by zero"}
+			}
+			if operator == "/" {
+				left /= right
+			} else {
+				left %= right
+			}
+		}
+	}
+}
+
+func (p *exprParser) unary() (int, error) {
+	p.skipSpace()
+	if p.offset == len(p.text) {
+		return 0, &exprError{p.offset, "unexpected end of expression"}
+	}
+	switch c := p.text[p.offset]; {
+	case c == '-' || c == '~':
+		p.offset++
+		value, err := p.unary()
+		if c == '-' {
+			return -value, err
+		}
+		return ^value, err
+	case c == '(':
+		start := p.offset
+		p.offset++
+		value, err := p.binary(0)
+		if err != nil {
+			return 0, err
+		}
+		p.skipSpace()
+		if p.offset == len(p.text) || p.text[p.offset] != ')' {
+			return 0, &exprError{start, "missing )"}
+		}
+		p.offset++
+		return value, nil
+	}
+	start := p.offset
+	for p.offset < len(p.text) && isNameChar(p.text[p.offset]) {
+		p.offset++
+	}
+	word := p.text[start:p.offset]
+	if word == "" {
+		return 0, &exprError{start, fmt.Sprintf("unexpected %q in expression", p.text[start:start+1])}
+	}
+	if value, ok := parseNumber(word); ok {
+		return value, nil
+	}
+	if value, ok := p.lookup(word); ok {
+		return value, nil
+	}
+	return 0, &exprError{start, fmt.Sprintf("undefined name %s", word)}
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// parseNumber parses decimal, 0x hexadecimal and 0b binary numbers with an optional minus sign
+func parseNumber(text string) (int, bool) {
+	negative := strings.HasPrefix(text, "-")
+	if negative {
+		text = text[1:]
+	}
+	base := 10
+	lower := strings.ToLower(text)
+	if strings.HasPrefix(lower, "0x") {
+		base, text = 16, text[2:]
+	} else if strings.HasPrefix(lower, "0b") {
+		base, text = 2, text[2:]
+	}
+	if text == "" || text[0] == '-' || text[0] == '+' {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(text, base, 32)
+	if err != nil {
+		return 0, false
+	}
+	if negative {
+		value = -value
+	}
+	return int(value), true
+}
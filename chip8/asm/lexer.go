@@ -0,0 +1,102 @@
+package asm
+
+import "fmt"
+
+// Pos is a position in a source file, lines and columns start at 1
+type Pos struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	// A string literal in double quotes, text holds the contents without the quotes
+	tokenString
+	// An expression in braces, text holds the contents without the braces
+	tokenExpr
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  Pos
+}
+
+// tokenize splits source into whitespace separated words, strings and braced expressions.
+// Comments start with # and run to the end of the line. The position of the end of the file is returned too.
+func tokenize(file string, source []byte) ([]token, Pos, error) {
+	var tokens []token
+	line, column := 1, 1
+	i := 0
+	advance := func() {
+		if source[i] == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+		i++
+	}
+	for i < len(source) {
+		c := source[i]
+		pos := Pos{file, line, column}
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			advance()
+		case c == '#':
+			for i < len(source) && source[i] != '\n' {
+				advance()
+			}
+		case c == '"':
+			advance()
+			start := i
+			for i < len(source) && source[i] != '"' && source[i] != '\n' {
+				advance()
+			}
+			if i == len(source) || source[i] != '"' {
+				return nil, Pos{}, &Error{pos, "unterminated string"}
+			}
+			tokens = append(tokens, token{tokenString, string(source[start:i]), pos})
+			advance()
+		case c == '{':
+			advance()
+			start := i
+			depth := 1
+			for i < len(source) {
+				if source[i] == '{' {
+					depth++
+				} else if source[i] == '}' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+				advance()
+			}
+			if i == len(source) {
+				return nil, Pos{}, &Error{pos, "unterminated expression, missing }"}
+			}
+			// The expression keeps the position of its first character for error reporting
+			tokens = append(tokens, token{tokenExpr, string(source[start:i]), Pos{file, pos.Line, pos.Column + 1}})
+			advance()
+		default:
+			start := i
+			for i < len(source) && !isSpace(source[i]) && source[i] != '#' {
+				advance()
+			}
+			tokens = append(tokens, token{tokenWord, string(source[start:i]), pos})
+		}
+	}
+	return tokens, Pos{file, line, column}, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
@@ -0,0 +1,65 @@
+package asm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Line maps the address of an instruction to the source line it was assembled from
+type Line struct {
+	Addr uint16 `json:"addr"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// Symbols are the labels and source lines of a program, saved next to its ROM for debuggers
+type Symbols struct {
+	Labels map[string]uint16 `json:"labels"`
+	Lines  []Line            `json:"lines"`
+}
+
+// Symbols returns the labels and source lines of the program
+func (program *Program) Symbols() Symbols {
+	return Symbols{Labels: program.Labels, Lines: program.Lines}
+}
+
+// Symbol returns the name of addr relative to the nearest label before it, like main+0x4,
+// or an empty string when no label is at or before addr
+func (symbols Symbols) Symbol(addr uint16) string {
+	var name string
+	var at uint16
+	for n, a := range symbols.Labels {
+		if a <= addr && (name == "" || a > at || a == at && n < name) {
+			name, at = n, a
+		}
+	}
+	switch {
+	case name == "":
+		return ""
+	case at == addr:
+		return name
+	}
+	return fmt.Sprintf("%s+0x%X", name, addr-at)
+}
+
+// SymbolsPath returns the path of the symbol file accompanying a ROM, the ROM path with the extension .sym
+func SymbolsPath(romPath string) string {
+	return strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".sym"
+}
+
+// WriteSymbols writes symbols as JSON
+func WriteSymbols(w io.Writer, symbols Symbols) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(symbols)
+}
+
+// ReadSymbols reads symbols written by WriteSymbols
+func ReadSymbols(r io.Reader) (Symbols, error) {
+	var symbols Symbols
+	err := json.NewDecoder(r).Decode(&symbols)
+	return symbols, err
+}
@@ -0,0 +1,40 @@
+package chip8
+
+// AccessKind tells why memory is accessed
+type AccessKind byte
+
+const (
+	// FetchAccess reads an instruction at PC
+	FetchAccess AccessKind = iota
+	// ReadAccess reads data, like FX65 loading registers or DXYN reading a sprite
+	ReadAccess
+	// WriteAccess writes data, like FX55 storing registers
+	WriteAccess
+)
+
+func (kind AccessKind) String() string {
+	switch kind {
+	case FetchAccess:
+		return "fetch"
+	case ReadAccess:
+		return "read"
+	}
+	return "write"
+}
+
+// Bus carries the memory accesses of the instructions. It sees the addresses once the access policy
+// was applied, so they are always inside the address space, and no write to a protected page.
+type Bus interface {
+	Read8(addr uint16, kind AccessKind) byte
+	Write8(addr uint16, value byte, kind AccessKind)
+}
+
+// Read8 returns the byte at addr, so Memory is the Bus buses wrapping memory end with
+func (memory *Memory) Read8(addr uint16, kind AccessKind) byte {
+	return memory.Memory[addr]
+}
+
+// Write8 stores value at addr
+func (memory *Memory) Write8(addr uint16, value byte, kind AccessKind) {
+	memory.Memory[addr] = value
+}
@@ -0,0 +1,91 @@
+// Package bus has chip8.Bus implementations to attach to a CPU: observers of the memory accesses,
+// and a map of address ranges to devices, like a console printing the bytes written to it.
+//
+// Buses end with the memory of the CPU they are attached to, like NewMapper(&cpu.Memory). They hold a
+// pointer to that memory, so once a CPU is copied by value its copy still reads and writes the memory of
+// the original through them. Build the buses after the CPU reached its final place, and build new ones
+// for copies.
+package bus
+
+import (
+	"GoCHIP-8/chip8"
+	"io"
+)
+
+// Observer passes every access of Bus to Observe once it is done, for watchpoints, heatmaps or cheats
+// rewriting memory. The value of a write is the byte written.
+type Observer struct {
+	chip8.Bus
+	Observe func(addr uint16, value byte, kind chip8.AccessKind)
+}
+
+// Read8 reads Bus and passes the byte read to Observe
+func (o *Observer) Read8(addr uint16, kind chip8.AccessKind) byte {
+	value := o.Bus.Read8(addr, kind)
+	o.Observe(addr, value, kind)
+	return value
+}
+
+// Write8 writes Bus and passes the byte written to Observe
+func (o *Observer) Write8(addr uint16, value byte, kind chip8.AccessKind) {
+	o.Bus.Write8(addr, value, kind)
+	o.Observe(addr, value, kind)
+}
+
+// mapping is a range of addresses handled by a device
+type mapping struct {
+	start, end uint16
+	device     chip8.Bus
+}
+
+// Mapper sends the accesses of the address ranges mapped by Map to their devices, and the others to Bus
+type Mapper struct {
+	chip8.Bus
+	mappings []mapping
+}
+
+// NewMapper returns a Mapper with no device, all accesses go to bus
+func NewMapper(bus chip8.Bus) *Mapper {
+	return &Mapper{Bus: bus}
+}
+
+// Map sends the accesses of length bytes at addr to device, instead of a device mapped before
+func (m *Mapper) Map(addr uint16, length int, device chip8.Bus) {
+	end := int(addr) + length - 1
+	if end > 0xFFFF {
+		end = 0xFFFF
+	}
+	m.mappings = append([]mapping{{addr, uint16(end), device}}, m.mappings...)
+}
+
+// device returns the device handling addr, Bus when no device is mapped to it
+func (m *Mapper) device(addr uint16) chip8.Bus {
+	for _, mapping := range m.mappings {
+		if addr >= mapping.start && addr <= mapping.end {
+			return mapping.device
+		}
+	}
+	return m.Bus
+}
+
+func (m *Mapper) Read8(addr uint16, kind chip8.AccessKind) byte {
+	return m.device(addr).Read8(addr, kind)
+}
+
+func (m *Mapper) Write8(addr uint16, value byte, kind chip8.AccessKind) {
+	m.device(addr).Write8(addr, value, kind)
+}
+
+// Console is a device writing the bytes stored to it to W, so test ROMs can print text with FX55.
+// It reads as 0.
+type Console struct {
+	W io.Writer
+}
+
+func (c Console) Read8(uint16, chip8.AccessKind) byte {
+	return 0
+}
+
+func (c Console) Write8(addr uint16, value byte, kind chip8.AccessKind) {
+	_, _ = c.W.Write([]byte{value})
+}
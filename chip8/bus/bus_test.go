@@ -0,0 +1,157 @@
+package bus
+
+import (
+	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/internal/romtest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// printROM prints Hi with FX55 to 0x300, then loops
+var printROM = []byte{
+	0xA3, 0x00, // 0x200: i := 0x300
+	0x60, 0x48, // 0x202: v0 := 'H'
+	0xF0, 0x55, // 0x204: save v0
+	0x60, 0x69, // 0x206: v0 := 'i'
+	0xF0, 0x55, // 0x208: save v0
+	0x12, 0x0A, // 0x20A: jump 0x20A
+}
+
+func TestConsole(t *testing.T) {
+	cpu := chip8.NewCPU()
+	copy(cpu.Memory.Memory[0x200:], printROM)
+	var output strings.Builder
+	mapper := NewMapper(&cpu.Memory)
+	mapper.Map(0x300, 1, Console{W: &output})
+	cpu.Bus = mapper
+	assert.Nil(t, cpu.Run())
+	assert.Equal(t, "Hi", output.String())
+	// The console took the writes, memory is left as is
+	assert.Equal(t, byte(0x00), cpu.Memory.Memory[0x300])
+	assert.Equal(t, byte(0x00), mapper.Read8(0x300, chip8.ReadAccess))
+	assert.Equal(t, byte(0xA3), mapper.Read8(0x200, chip8.ReadAccess))
+}
+
+func TestMapperOverlap(t *testing.T) {
+	var memory, first, second chip8.Memory
+	mapper := NewMapper(&memory)
+	mapper.Map(0x100, 0x10, &first)
+	mapper.Map(0x108, 0x10, &second)
+	for _, addr := range []uint16{0x0FF, 0x100, 0x107, 0x108, 0x117, 0x118, 0xFFFF} {
+		mapper.Write8(addr, 0x01, chip8.WriteAccess)
+	}
+	assert.Equal(t, byte(0x01), memory.Memory[0x0FF])
+	assert.Equal(t, byte(0x01), memory.Memory[0x118])
+	assert.Equal(t, byte(0x01), memory.Memory[0xFFFF])
+	assert.Equal(t, []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, first.Memory[0x100:0x108])
+	assert.Equal(t, byte(0x00), first.Memory[0x108])
+	assert.Equal(t, byte(0x01), second.Memory[0x108])
+	assert.Equal(t, byte(0x01), second.Memory[0x117])
+
+	// A mapping past the end of the address space is cut at 0xFFFF
+	mapper.Map(0xFFF0, 0x100, &first)
+	mapper.Write8(0xFFFF, 0x02, chip8.WriteAccess)
+	assert.Equal(t, byte(0x02), first.Memory[0xFFFF])
+}
+
+func TestObserver(t *testing.T) {
+	cpu := chip8.NewCPU()
+	copy(cpu.Memory.Memory[0x200:], printROM)
+	counts := make(map[chip8.AccessKind]int)
+	var writes []byte
+	cpu.Bus = &Observer{Bus: &cpu.Memory, Observe: func(addr uint16, value byte, kind chip8.AccessKind) {
+		counts[kind]++
+		if kind == chip8.WriteAccess {
+			assert.Equal(t, uint16(0x300), addr)
+			writes = append(writes, value)
+		}
+	}}
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, cpu.Cycle())
+	}
+	assert.Equal(t, map[chip8.AccessKind]int{chip8.FetchAccess: 10, chip8.WriteAccess: 2}, counts)
+	assert.Equal(t, []byte("Hi"), writes)
+	assert.Equal(t, byte('i'), cpu.Memory.Memory[0x300])
+}
+
+func TestObserverSkip(t *testing.T) {
+	// Skipped instructions are not fetched
+	cpu := chip8.NewCPU()
+	cpu.Quirks = chip8.QuirksXOCHIP
+	copy(cpu.Memory.Memory[0x200:], []byte{
+		0x30, 0x00, // 0x200: if v0 != 0 then
+		0xF0, 0x00, 0x03, 0x00, // 0x202: i := long 0x300
+		0x30, 0x00, // 0x206: if v0 != 0 then
+		0x60, 0x01, // 0x208: v0 := 1
+	})
+	var fetches []uint16
+	cpu.Bus = &Observer{Bus: &cpu.Memory, Observe: func(addr uint16, value byte, kind chip8.AccessKind) {
+		if kind == chip8.FetchAccess {
+			fetches = append(fetches, addr)
+		}
+	}}
+	assert.Nil(t, cpu.Cycle())
+	assert.Equal(t, uint16(0x206), cpu.Register.PC)
+	assert.Nil(t, cpu.Cycle())
+	assert.Equal(t, uint16(0x20A), cpu.Register.PC)
+	assert.Equal(t, []uint16{0x200, 0x201, 0x206, 0x207}, fetches)
+}
+
+// TestMemoryBus runs a game with memory as the bus, it must run exactly like without bus
+func TestMemoryBus(t *testing.T) {
+	direct := chip8.NewCPU()
+	bused := chip8.NewCPU()
+	bused.Bus = &bused.Memory
+	for _, cpu := range []*chip8.CPU{&direct, &bused} {
+		cpu.Quirks = chip8.QuirksVIP
+		cpu.SetSeed(1)
+		assert.Nil(t, cpu.LoadROM("../../roms/BRIX"))
+		for i := 0; i < 300; i++ {
+			assert.Nil(t, cpu.Run())
+		}
+	}
+	assert.Equal(t, direct.Register, bused.Register)
+	assert.Equal(t, direct.Memory.Memory, bused.Memory.Memory)
+	assert.Equal(t, direct.Display, bused.Display)
+}
+
+// passThrough forwards every access to memory, the least a Bus does
+type passThrough struct {
+	memory *chip8.Memory
+}
+
+func (p passThrough) Read8(addr uint16, kind chip8.AccessKind) byte {
+	return p.memory.Read8(addr, kind)
+}
+
+func (p passThrough) Write8(addr uint16, value byte, kind chip8.AccessKind) {
+	p.memory.Write8(addr, value, kind)
+}
+
+// buses are the buses compared by the benchmarks, nil for direct memory accesses. The ROMs must run
+// 10 times faster than real time at least through a pass-through bus.
+var buses = []struct {
+	name        string
+	minRealTime float64
+	bus         func(cpu *chip8.CPU) chip8.Bus
+}{
+	{"nil", 0, func(*chip8.CPU) chip8.Bus { return nil }},
+	{"passthrough", 10, func(cpu *chip8.CPU) chip8.Bus { return passThrough{&cpu.Memory} }},
+	{"observer", 0, func(cpu *chip8.CPU) chip8.Bus {
+		return &Observer{Bus: &cpu.Memory, Observe: func(uint16, byte, chip8.AccessKind) {}}
+	}},
+}
+
+// BenchmarkBus compares the instructions per second of every ROM without a bus and through buses
+func BenchmarkBus(b *testing.B) {
+	for _, bench := range buses {
+		bench := bench
+		b.Run(bench.name, func(b *testing.B) {
+			romtest.Benchmark(b, bench.minRealTime, func(cpu *chip8.CPU) {
+				cpu.Bus = bench.bus(cpu)
+			}, (*chip8.CPU).Run)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package chip8
+
+// cachePageSize is the number of addresses of a page of the instruction cache, pages are
+// allocated when an instruction in them is first decoded so only code takes memory
+const cachePageSize = 256
+
+type cachedInstruction struct {
+	Instruction
+	valid bool
+}
+
+// instructionCache holds the instructions decoded by RunFrames, by address
+type instructionCache struct {
+	// memory the instructions were decoded from, a copy of the CPU gets a cache of its own
+	memory *Memory
+	pages  [65536 / cachePageSize]*[cachePageSize]cachedInstruction
+}
+
+// invalidate drops the instructions overlapping addr, the one at addr and the one before it
+func (cache *instructionCache) invalidate(addr int) {
+	for _, a := range [2]int{addr, addr - 1} {
+		if a < 0 {
+			continue
+		}
+		if page := cache.pages[a/cachePageSize]; page != nil {
+			page[a%cachePageSize].valid = false
+		}
+	}
+}
+
+// InvalidateCache drops the instructions decoded by RunFrames. It must be called after writing Memory
+// directly, the writes of the instructions and of the buses, LoadROM, Reset and LoadState invalidate it already.
+func (cpu *CPU) InvalidateCache() {
+	if cpu.cache != nil {
+		cpu.cache.pages = [len(cpu.cache.pages)]*[cachePageSize]cachedInstruction{}
+	}
+}
+
+// decodeCached returns the instruction at PC, from the cache when it was decoded before. Size is MemorySize.
+func (cpu *CPU) decodeCached(size int) (Instruction, error) {
+	pc := int(cpu.Register.PC)
+	if pc+1 >= size {
+		// Fetched across the end of memory, never cached
+		opcode, err := cpu.fetch(0)
+		return Decode(opcode), err
+	}
+	page := cpu.cache.pages[pc/cachePageSize]
+	if page == nil {
+		page = new([cachePageSize]cachedInstruction)
+		cpu.cache.pages[pc/cachePageSize] = page
+	}
+	entry := &page[pc%cachePageSize]
+	if !entry.valid {
+		entry.Instruction = Decode(uint16(cpu.Memory.Memory[pc])<<8 | uint16(cpu.Memory.Memory[pc+1]))
+		entry.valid = true
+	}
+	return entry.Instruction, nil
+}
+
+// RunFrames runs n frames like n calls to Run, faster as instructions are decoded once and cached by
+// address. Instructions writing memory invalidate the cache, so self-modifying programs still work.
+// A Tracer or a Bus sees every fetch, RunFrames runs like Run while one is set.
+func (cpu *CPU) RunFrames(n int) error {
+	if cpu.Tracer != nil || cpu.Bus != nil {
+		for i := 0; i < n; i++ {
+			if err := cpu.Run(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if cpu.cache == nil || cpu.cache.memory != &cpu.Memory {
+		cpu.cache = &instructionCache{memory: &cpu.Memory}
+	}
+	size := cpu.MemorySize()
+	for i := 0; i < n; i++ {
+		for j := 0; j < cpu.InstructionsPerFrame; j++ {
+			instruction, err := cpu.decodeCached(size)
+			if err != nil {
+				return err
+			}
+			if err := cpu.exec(instruction); err != nil {
+				return err
+			}
+		}
+		cpu.TickTimers()
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package chip8_test
+
+import (
+	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/internal/romtest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunFramesMatchesRun(t *testing.T) {
+	for _, path := range romtest.Paths(t) {
+		run := romtest.NewCPU(t, path)
+		runFrames := romtest.NewCPU(t, path)
+		for frame := 0; frame < 300; frame++ {
+			// Hold a key for a while, so games waiting for one start
+			run.SetKey(0x5, frame%60 < 10)
+			runFrames.SetKey(0x5, frame%60 < 10)
+			assert.Nil(t, run.Run(), path)
+			assert.Nil(t, runFrames.RunFrames(1), path)
+		}
+		chip8.DropCache(&runFrames)
+		assert.Equal(t, run, runFrames, path)
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	romtest.Benchmark(b, 0, nil, (*chip8.CPU).Run)
+}
+
+func BenchmarkRunFrames(b *testing.B) {
+	romtest.Benchmark(b, 0, nil, func(cpu *chip8.CPU) error {
+		return cpu.RunFrames(1)
+	})
+}
@@ -0,0 +1,87 @@
+package chip8
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunFramesSelfModifyingCode(t *testing.T) {
+	cpu := NewCPU()
+	copy(cpu.Memory.Memory[0x200:], []byte{
+		0x6A, 0x01, // 0x200: va := 1
+		0xA2, 0x00, // 0x202: i := 0x200
+		0x60, 0x7B, // 0x204: v0 := 0x7B
+		0x61, 0x10, // 0x206: v1 := 0x10
+		0xF1, 0x55, // 0x208: save v1, 0x200 becomes vb += 0x10
+		0x12, 0x00, // 0x20A: jump 0x200
+	})
+	assert.Nil(t, cpu.RunFrames(1))
+	assert.Equal(t, byte(0x01), cpu.Register.V[0xA])
+	assert.Equal(t, byte(0x10), cpu.Register.V[0xB])
+
+	// Memory written directly is seen once the cache is invalidated
+	cpu.Register.PC = 0x200
+	cpu.Memory.Memory[0x201] = 0x20
+	cpu.InvalidateCache()
+	cpu.InstructionsPerFrame = 1
+	assert.Nil(t, cpu.RunFrames(1))
+	assert.Equal(t, byte(0x30), cpu.Register.V[0xB])
+
+	// A copy of the CPU does not share the instructions of the original
+	other := cpu
+	other.Register.PC = 0x200
+	other.Memory.Memory[0x201] = 0x01
+	assert.Nil(t, other.RunFrames(1))
+	assert.Equal(t, byte(0x31), other.Register.V[0xB])
+	cpu.Register.PC = 0x200
+	assert.Nil(t, cpu.RunFrames(1))
+	assert.Equal(t, byte(0x50), cpu.Register.V[0xB])
+}
+
+func TestRunFramesMemoryEdits(t *testing.T) {
+	cpu := NewCPU()
+	cpu.InstructionsPerFrame = 2
+	copy(cpu.Memory.Memory[0x200:], []byte{
+		0x70, 0x01, // 0x200: v0 += 1
+		0x12, 0x00, // 0x202: jump 0x200
+	})
+	var state bytes.Buffer
+	assert.Nil(t, cpu.SaveState(&state))
+	assert.Nil(t, cpu.RunFrames(1))
+	assert.Equal(t, byte(0x01), cpu.Register.V[0])
+
+	// Between frames, as a debugger or a frontend would
+	cpu.Memory.Memory[0x201] = 0x10
+	cpu.InvalidateCache()
+	assert.Nil(t, cpu.RunFrames(1))
+	assert.Equal(t, byte(0x11), cpu.Register.V[0])
+
+	// A save state brings back the old program, with the same CPU
+	cpu.Memory.Memory[0x201] = 0x20
+	assert.Nil(t, cpu.LoadState(bytes.NewReader(state.Bytes())))
+	assert.Nil(t, cpu.RunFrames(1))
+	assert.Equal(t, byte(0x01), cpu.Register.V[0])
+
+	// And so does rewinding
+	rewind := NewRewindBuffer(2)
+	assert.Nil(t, rewind.Push(&cpu))
+	cpu.Memory.Memory[0x201] = 0x40
+	cpu.InvalidateCache()
+	assert.Nil(t, cpu.RunFrames(1))
+	assert.Equal(t, byte(0x41), cpu.Register.V[0])
+	assert.Nil(t, rewind.Push(&cpu))
+	ok, err := rewind.Rewind(&cpu)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Nil(t, cpu.RunFrames(1))
+	assert.Equal(t, byte(0x02), cpu.Register.V[0])
+
+	// Writes of the instructions through a bus invalidate the cache too
+	cpu.Bus = &cpu.Memory
+	assert.Nil(t, cpu.writeMemory(0x201, 0x30))
+	cpu.Bus = nil
+	assert.Nil(t, cpu.RunFrames(1))
+	assert.Equal(t, byte(0x32), cpu.Register.V[0])
+}
@@ -1,33 +1,78 @@
 package chip8
 
-import (
-	"fmt"
-	"log"
-)
-
 const (
 	DisplayHeight = 32
 	DisplayWidth  = 64
+	// SUPER-CHIP high resolution mode
+	HiResDisplayHeight = 64
+	HiResDisplayWidth  = 128
+	// Rate of the delay and sound timers in Hz
+	TimerFrequency = 60
+	// Instructions executed per frame by default, about 400 Hz
+	DefaultInstructionsPerFrame = 7
+	// Nested subroutine calls allowed by default, see Quirks.StackDepth
+	DefaultStackDepth = 16
+	// Largest stack depth of any quirks
+	MaxStackDepth = 128
 )
 
 type CPU struct {
 	Register Register
 	Memory   Memory
-	// internal stack to store return addresses when calling procedures
-	Stack [16]uint16
-	// 2D array representing 32 x 64 grid
-	Display [DisplayHeight][DisplayWidth]byte
-	// State of the keys
+	// internal stack to store return addresses when calling procedures, StackDepth of them are used
+	Stack [MaxStackDepth]uint16
+	// 2D array representing 64 x 128 grid, only the top left 32 x 64 is used in low resolution mode
+	Display [HiResDisplayHeight][HiResDisplayWidth]byte
+	// Is high resolution mode enabled (toggled by 00FE/00FF)
+	HiRes bool
+	// Bitmask of the display planes drawn to (set by FN01), each Display pixel holds one bit per plane
+	Plane byte
+	// 1-bit audio pattern played while the sound timer is active (loaded by F002)
+	AudioPattern [16]byte
+	// Is an audio pattern loaded (set by F002), the pattern replaces the tone even when it is all zeros
+	PatternLoaded bool
+	// Playback pitch of the audio pattern (set by FX3A)
+	Pitch byte
+	// Is program exited (used by 00FD)
+	Exited bool
+	// RPL user flags (used by FX75/FX85)
+	Flags [16]byte
+	// State of the keys, set by SetKey
 	KeyState [16]byte
+	// Keys pressed and released since FX0A started waiting, one bit per key
+	KeyPresses, KeyReleases uint16
 	// Need draw or not
 	NeedDraw bool
 	// Is wait for input (used by FX0A)
 	WaitInput bool
+	// Interpretation of ambiguous instructions, kept across resets
+	Quirks Quirks
+	// Number of instructions executed by Run before the timers are ticked, kept across resets
+	InstructionsPerFrame int
+	// Receives every executed instruction when not nil, kept across resets
+	Tracer Tracer
+	// Carries the memory accesses of the instructions when not nil, Memory is accessed directly otherwise.
+	// Save states, traces and the debugger still read Memory, kept across resets. A bus wrapping
+	// &cpu.Memory keeps pointing at the memory of this CPU, a copy of the CPU needs a bus of its own.
+	Bus Bus
+	// Source of the random numbers of CXNN, a Rand seeded with Seed by default
+	Random Random
+	// Seed of the default generator, Reset restarts the generator from it
+	Seed int64
+
+	// Instructions decoded by RunFrames, by address
+	cache *instructionCache
+	// Trace of the instruction being traced, it receives the memory written
+	trace *Trace
 }
 
 func NewCPU() CPU {
 	cpu := CPU{}
 	cpu.Register.PC = 0x200
+	cpu.Plane = 0x01
+	cpu.Pitch = 64
+	cpu.InstructionsPerFrame = DefaultInstructionsPerFrame
+	cpu.Random = NewRand(cpu.Seed)
 	cpu.Memory = Memory{}
 	cpu.Memory.LoadFontSet()
 	return cpu
@@ -41,36 +86,102 @@ func (cpu *CPU) Reset() {
 	cpu.Register.ST = 0
 	cpu.NeedDraw = false
 	cpu.WaitInput = false
+	cpu.KeyPresses = 0
+	cpu.KeyReleases = 0
+	cpu.HiRes = false
+	cpu.Exited = false
+	cpu.Plane = 0x01
+	cpu.Pitch = 64
+	for i := 0; i < len(cpu.AudioPattern); i++ {
+		cpu.AudioPattern[i] = 0
+	}
+	cpu.PatternLoaded = false
 	for i := 0; i < len(cpu.Register.V); i++ {
 		cpu.Register.V[i] = 0
 	}
 	for i := 0; i < len(cpu.Memory.Memory); i++ {
 		cpu.Memory.Memory[i] = 0
 	}
+	cpu.Memory.ROMSize = 0
+	// The pages protected for the previous ROM are writable until the next one is loaded
+	cpu.protectAreas()
+	cpu.InvalidateCache()
 	for i := 0; i < len(cpu.Stack); i++ {
 		cpu.Stack[i] = 0
 	}
 	for i := 0; i < len(cpu.KeyState); i++ {
 		cpu.KeyState[i] = 0
 	}
+	for i := 0; i < len(cpu.Flags); i++ {
+		cpu.Flags[i] = 0
+	}
+	if r, ok := cpu.Random.(*Rand); ok {
+		r.Seed(cpu.Seed)
+	}
 	cpu.Memory.LoadFontSet()
 	cpu.ClearDisplay()
 }
 
 func (cpu *CPU) ClearDisplay() {
-	for x := 0; x < DisplayHeight; x++ {
-		for y := 0; y < DisplayWidth; y++ {
+	for x := 0; x < HiResDisplayHeight; x++ {
+		for y := 0; y < HiResDisplayWidth; y++ {
 			cpu.Display[x][y] = 0
 		}
 	}
 }
 
+// DisplaySize returns the width and height of the display in the current resolution
+func (cpu *CPU) DisplaySize() (width, height int) {
+	if cpu.HiRes {
+		return HiResDisplayWidth, HiResDisplayHeight
+	}
+	return DisplayWidth, DisplayHeight
+}
+
+// InstructionsPerFrame returns the number of instructions per frame running at a clock speed in Hz, at least 1
+func InstructionsPerFrame(clockSpeed int) int {
+	n := (clockSpeed + TimerFrequency/2) / TimerFrequency
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// StackDepth returns the number of nested subroutine calls allowed by the quirks
+func (cpu *CPU) StackDepth() int {
+	depth := int(cpu.Quirks.StackDepth)
+	if depth == 0 {
+		return DefaultStackDepth
+	}
+	if depth > MaxStackDepth {
+		return MaxStackDepth
+	}
+	return depth
+}
+
 func (cpu *CPU) LoadROM(romPath string) error {
-	return cpu.Memory.LoadROM(romPath)
+	cpu.InvalidateCache()
+	if err := cpu.Memory.LoadROM(romPath); err != nil {
+		return err
+	}
+	cpu.protectAreas()
+	return nil
 }
 
-func (cpu *CPU) Run() {
-	cpu.Cycle()
+// Run executes one frame: InstructionsPerFrame instructions followed by a single timer tick.
+// Calling it TimerFrequency times per second keeps the timers at 60 Hz whatever the instruction rate is.
+func (cpu *CPU) Run() error {
+	for i := 0; i < cpu.InstructionsPerFrame; i++ {
+		if err := cpu.Cycle(); err != nil {
+			return err
+		}
+	}
+	cpu.TickTimers()
+	return nil
+}
+
+// TickTimers decrements the delay and sound timers, the host calls it at TimerFrequency
+func (cpu *CPU) TickTimers() {
 	if cpu.Register.DT > 0 {
 		cpu.Register.DT--
 	}
@@ -79,162 +190,244 @@ func (cpu *CPU) Run() {
 	}
 }
 
-func (cpu *CPU) getOpCode() uint16 {
-	return uint16(cpu.Memory.Memory[cpu.Register.PC])<<8 | uint16(cpu.Memory.Memory[cpu.Register.PC+1])
+// fetch returns the 16 bits at PC+offset. Fetching past the address space wraps around with
+// AccessWrap and faults otherwise, since execution can't continue without an instruction.
+// While tracing, the opcode and the operand of F000 NNNN are recorded as fetched.
+func (cpu *CPU) fetch(offset int) (uint16, error) {
+	addr := int(cpu.Register.PC) + offset
+	size := cpu.MemorySize()
+	if addr+1 >= size && cpu.Memory.Policy != AccessWrap {
+		return 0, ErrMemoryOutOfBounds{Addr: addr + 1}
+	}
+	high, low := addr&(size-1), (addr+1)&(size-1)
+	var value uint16
+	if cpu.Bus == nil {
+		value = uint16(cpu.Memory.Memory[high])<<8 | uint16(cpu.Memory.Memory[low])
+	} else {
+		value = uint16(cpu.Bus.Read8(uint16(high), FetchAccess))<<8 | uint16(cpu.Bus.Read8(uint16(low), FetchAccess))
+	}
+	if cpu.trace != nil {
+		if offset == 0 {
+			cpu.trace.Opcode = value
+		} else {
+			cpu.trace.Long = value
+		}
+	}
+	return value, nil
 }
 
-func (cpu *CPU) Cycle() {
-	defer func() {
-		if err := recover(); err != nil {
-			cpu.Debug()
-			log.Fatalf("CPU Panic: %s\n", err)
-		}
-	}()
-	opcode := cpu.getOpCode()
-	x := (opcode & 0x0F00) >> 8
-	y := (opcode & 0x00F0) >> 4
-	nn := byte(opcode & 0x00FF)
-	nnn := opcode & 0x0FFF
-	switch opcode & 0xF000 {
-	case 0x0000:
-		switch opcode & 0x00FF {
-		// 00E0: Clears the screen
-		case 0x00E0:
-			cpu.exec00E0()
-		// 00EE: Returns from a subroutine
-		case 0x00EE:
-			cpu.exec00EE()
-		default:
-			panic(fmt.Sprintf("Unknown opcode: %X", opcode))
+// skipNextInstruction advances PC past the next instruction, which is 4 bytes long for the XO-CHIP F000 NNNN.
+// The skipped instruction is peeked at in Memory without going through the Bus, since it never runs.
+func (cpu *CPU) skipNextInstruction() {
+	addr := int(cpu.Register.PC) + 2
+	size := cpu.MemorySize()
+	inside := addr+1 < size || cpu.Memory.Policy == AccessWrap
+	if inside && cpu.Memory.Memory[addr&(size-1)] == 0xF0 && cpu.Memory.Memory[(addr+1)&(size-1)] == 0x00 {
+		cpu.Register.PC += 6
+	} else {
+		cpu.Register.PC += 4
+	}
+}
+
+// scrollDisplay moves the selected planes of the display by dx, dy pixels, blank pixels are shifted in
+func (cpu *CPU) scrollDisplay(dx, dy int) {
+	width, height := cpu.DisplaySize()
+	var display [HiResDisplayHeight][HiResDisplayWidth]byte
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			var pixel byte
+			if i-dy >= 0 && i-dy < height && j-dx >= 0 && j-dx < width {
+				pixel = cpu.Display[i-dy][j-dx]
+			}
+			display[i][j] = cpu.Display[i][j]&^cpu.Plane | pixel&cpu.Plane
 		}
+	}
+	cpu.Display = display
+	cpu.NeedDraw = true
+}
+
+// Cycle fetches, decodes and executes a single instruction. Faults are returned as
+// ErrUnknownOpcode, ErrStackOverflow, ErrStackUnderflow, ErrMemoryOutOfBounds or ErrWriteProtected.
+func (cpu *CPU) Cycle() error {
+	if cpu.Tracer != nil {
+		return cpu.traceCycle()
+	}
+	return cpu.execute()
+}
+
+func (cpu *CPU) execute() error {
+	opcode, err := cpu.fetch(0)
+	if err != nil {
+		return err
+	}
+	return cpu.exec(Decode(opcode))
+}
+
+// exec executes a decoded instruction at PC
+func (cpu *CPU) exec(instruction Instruction) error {
+	opcode := instruction.Opcode
+	x, y, n, nn, nnn := instruction.X, instruction.Y, instruction.N, instruction.NN, instruction.NNN
+	switch instruction.Op {
+	// 00CN: Scrolls the display down by N pixels (SUPER-CHIP)
+	case Op00CN:
+		cpu.exec00CN(n)
+	// 00DN: Scrolls the display up by N pixels (XO-CHIP)
+	case Op00DN:
+		cpu.exec00DN(n)
+	// 00E0: Clears the screen
+	case Op00E0:
+		cpu.exec00E0()
+	// 00EE: Returns from a subroutine
+	case Op00EE:
+		return cpu.exec00EE()
+	// 00FB: Scrolls the display right by 4 pixels (SUPER-CHIP)
+	case Op00FB:
+		cpu.exec00FB()
+	// 00FC: Scrolls the display left by 4 pixels (SUPER-CHIP)
+	case Op00FC:
+		cpu.exec00FC()
+	// 00FD: Exits the interpreter (SUPER-CHIP)
+	case Op00FD:
+		cpu.exec00FD()
+	// 00FE: Switches to low resolution mode (SUPER-CHIP)
+	case Op00FE:
+		cpu.exec00FE()
+	// 00FF: Switches to high resolution mode (SUPER-CHIP)
+	case Op00FF:
+		cpu.exec00FF()
 	// 1NNN: goto NNN
-	case 0x1000:
+	case Op1NNN:
 		cpu.exec1NNN(nnn)
 	// 2NNN: Calls subroutine at NNN
-	case 0x2000:
-		cpu.exec2NNN(nnn)
+	case Op2NNN:
+		return cpu.exec2NNN(nnn)
 	// 3XNN: Skips the next instruction if VX equals NN
-	case 0x3000:
+	case Op3XNN:
 		cpu.exec3XNN(x, nn)
 	// 4XNN: Skips the next instruction if VX doesn't equal NN
-	case 0x4000:
+	case Op4XNN:
 		cpu.exec4XNN(x, nn)
 	// 5XY0: Skips the next instruction if VX equals VY
-	case 0x5000:
+	case Op5XY0:
 		cpu.exec5XY0(x, y)
+	// 5XY2: Stores VX to VY (including VY) in memory starting at address I, I is not modified (XO-CHIP)
+	case Op5XY2:
+		return cpu.exec5XY2(x, y)
+	// 5XY3: Fills VX to VY (including VY) with values from memory starting at address I, I is not modified (XO-CHIP)
+	case Op5XY3:
+		return cpu.exec5XY3(x, y)
 	// 6XNN: Sets VX to NN
-	case 0x6000:
+	case Op6XNN:
 		cpu.exec6XNN(x, nn)
 	// 7XNN: Adds NN to VX (Carry flag is not changed)
-	case 0x7000:
+	case Op7XNN:
 		cpu.exec7XNN(x, nn)
-	case 0x8000:
-		switch opcode & 0x000F {
-		// 8XY0: Sets VX to the value of VY
-		case 0x0000:
-			cpu.exec8XY0(x, y)
-		// 8XY1: Sets VX to VX or VY (Bitwise OR operation)
-		case 0x0001:
-			cpu.exec8XY1(x, y)
-		// 8XY2: Sets VX to VX and VY (Bitwise AND operation)
-		case 0x0002:
-			cpu.exec8XY2(x, y)
-		// 8XY3: Sets VX to VX xor VY
-		case 0x0003:
-			cpu.exec8XY3(x, y)
-		// 8XY4: Adds VY to VX. VF is set to 1 when there's a carry, and to 0 when there isn't.
-		case 0x0004:
-			cpu.exec8XY4(x, y)
-		// 8XY5: VY is subtracted from VX. VF is set to 0 when there's a borrow, and 1 when there isn't.
-		case 0x0005:
-			cpu.exec8XY5(x, y)
-		// 8XY6: Stores the least significant bit of VX in VF and then shifts VX to the right by 1.
-		case 0x0006:
-			cpu.exec8XY6(x)
-		// 8XY7: Sets VX to VY minus VX. VF is set to 0 when there's a borrow, and 1 when there isn't.
-		case 0x0007:
-			cpu.exec8XY7(x, y)
-		// 8XYE: Stores the most significant bit of VX in VF and then shifts VX to the left by 1.
-		case 0x000E:
-			cpu.exec8XYE(x)
-		default:
-			panic(fmt.Sprintf("Unknown opcode: %X", opcode))
-		}
+	// 8XY0: Sets VX to the value of VY
+	case Op8XY0:
+		cpu.exec8XY0(x, y)
+	// 8XY1: Sets VX to VX or VY (Bitwise OR operation)
+	case Op8XY1:
+		cpu.exec8XY1(x, y)
+	// 8XY2: Sets VX to VX and VY (Bitwise AND operation)
+	case Op8XY2:
+		cpu.exec8XY2(x, y)
+	// 8XY3: Sets VX to VX xor VY
+	case Op8XY3:
+		cpu.exec8XY3(x, y)
+	// 8XY4: Adds VY to VX. VF is set to 1 when there's a carry, and to 0 when there isn't.
+	case Op8XY4:
+		cpu.exec8XY4(x, y)
+	// 8XY5: VY is subtracted from VX. VF is set to 0 when there's a borrow, and 1 when there isn't.
+	case Op8XY5:
+		cpu.exec8XY5(x, y)
+	// 8XY6: Stores the least significant bit of VX in VF and then shifts VX to the right by 1.
+	case Op8XY6:
+		cpu.exec8XY6(x, y)
+	// 8XY7: Sets VX to VY minus VX. VF is set to 0 when there's a borrow, and 1 when there isn't.
+	case Op8XY7:
+		cpu.exec8XY7(x, y)
+	// 8XYE: Stores the most significant bit of VX in VF and then shifts VX to the left by 1.
+	case Op8XYE:
+		cpu.exec8XYE(x, y)
 	// 9XY0: Skips the next instruction if VX doesn't equal VY. (Usually the next instruction is a jump to skip a code block)
-	case 0x9000:
+	case Op9XY0:
 		cpu.exec9XY0(x, y)
 	// ANNN: Sets I to the address NNN
-	case 0xA000:
+	case OpANNN:
 		cpu.execANNN(nnn)
-	// BNNN: Jumps to the address NNN plus V0
-	case 0xB000:
+	// BNNN: Jumps to the address NNN plus V0 (or XNN plus VX with the JumpUsesVX quirk)
+	case OpBNNN:
 		cpu.execBNNN(nnn)
 	// CXNN: Sets VX to the result of a bitwise and operation on a random number (Typically: 0 to 255) and NN
-	case 0xC000:
+	case OpCXNN:
 		cpu.execCXNN(x, nn)
 	// DXYN: Draws a sprite at coordinate (VX, VY) that has a width of 8 pixels and a height of N pixels. Each row of 8 pixels is read as bit-coded starting from memory
 	//	     location I; I value doesn't change after the execution of this instruction. As described above, VF is set to 1 if any screen pixels are flipped from set to
-	//       unset when the sprite is drawn, and to 0 if that doesn’t happen
-	case 0xD000:
-		cpu.execDXYN(opcode)
-	case 0xE000:
-		switch opcode & 0x00FF {
-		// EX9E: Skips the next instruction if the key stored in VX is pressed. (Usually the next instruction is a jump to skip a code block)
-		case 0x009E:
-			cpu.execEX9E(x)
-		// EXA1: Skips the next instruction if the key stored in VX isn't pressed. (Usually the next instruction is a jump to skip a code block)
-		case 0x00A1:
-			cpu.execEXA1(x)
-		default:
-			panic(fmt.Sprintf("Unknown opcode: %X", opcode))
-		}
-	case 0xF000:
-		switch opcode & 0x00FF {
-		// FX07: Sets VX to the value of the delay timer
-		case 0x0007:
-			cpu.execFX07(x)
-		// FX0A: A key press is awaited, and then stored in VX. (Blocking Operation. All instruction halted until next key event)
-		case 0x000A:
-			cpu.execFX0A(x)
-		// FX15: Sets the delay timer to VX
-		case 0x0015:
-			cpu.execFX15(x)
-		// FX18: Sets the sound timer to VX
-		case 0x0018:
-			cpu.execFX18(x)
-		// FX1E: Adds VX to I, VF is not affected
-		case 0x001E:
-			cpu.execFX1E(x)
-		// FX29: Sets I to the location of the sprite for the character in VX. Characters 0-F (in hexadecimal) are represented by a 4x5 font
-		case 0x0029:
-			cpu.execFX29(x)
-		// FX33: Stores the binary-coded decimal representation of VX, with the most significant of three digits at the address in I, the middle digit at I plus 1, and the
-		//       least significant digit at I plus 2. (In other words, take the decimal representation of VX, place the hundreds digit in memory at location in I, the tens digit
-		//       at location I+1, and the ones digit at location I+2.)
-		case 0x0033:
-			cpu.execFX33(x)
-		// FX55: Stores V0 to VX (including VX) in memory starting at address I. The offset from I is increased by 1 for each value written, but I itself is left unmodified
-		case 0x0055:
-			cpu.execFX55(x)
-		// FX65: Fills V0 to VX (including VX) with values from memory starting at address I. The offset from I is increased by 1 for each value written, but I itself is left unmodified
-		case 0x0065:
-			cpu.execFX65(x)
-		default:
-			panic(fmt.Sprintf("Unknown opcode: %X", opcode))
+	//       unset when the sprite is drawn, and to 0 if that doesn’t happen. DXY0 draws a 16x16 sprite (SUPER-CHIP)
+	case OpDXYN:
+		return cpu.execDXYN(opcode)
+	// EX9E: Skips the next instruction if the key stored in VX is pressed. (Usually the next instruction is a jump to skip a code block)
+	case OpEX9E:
+		cpu.execEX9E(x)
+	// EXA1: Skips the next instruction if the key stored in VX isn't pressed. (Usually the next instruction is a jump to skip a code block)
+	case OpEXA1:
+		cpu.execEXA1(x)
+	// F000 NNNN: Sets I to the 16-bit address NNNN stored in the following two bytes (XO-CHIP)
+	case OpF000:
+		nnnn, err := cpu.fetch(2)
+		if err != nil {
+			return err
 		}
+		cpu.execF000(nnnn)
+	// FN01: Selects the display planes to draw to, N is a bitmask (XO-CHIP)
+	case OpFN01:
+		cpu.execFN01(x)
+	// F002: Loads the 16-byte audio pattern buffer from memory starting at address I (XO-CHIP)
+	case OpF002:
+		return cpu.execF002()
+	// FX07: Sets VX to the value of the delay timer
+	case OpFX07:
+		cpu.execFX07(x)
+	// FX0A: A key press is awaited, and then stored in VX. (Blocking Operation. All instruction halted until next key event)
+	case OpFX0A:
+		cpu.execFX0A(x)
+	// FX15: Sets the delay timer to VX
+	case OpFX15:
+		cpu.execFX15(x)
+	// FX18: Sets the sound timer to VX
+	case OpFX18:
+		cpu.execFX18(x)
+	// FX1E: Adds VX to I, VF is not affected
+	case OpFX1E:
+		cpu.execFX1E(x)
+	// FX29: Sets I to the location of the sprite for the character in VX. Characters 0-F (in hexadecimal) are represented by a 4x5 font
+	case OpFX29:
+		cpu.execFX29(x)
+	// FX30: Sets I to the location of the large sprite for the character in VX. Characters 0-F (in hexadecimal) are represented by a 8x10 font (SUPER-CHIP)
+	case OpFX30:
+		cpu.execFX30(x)
+	// FX33: Stores the binary-coded decimal representation of VX, with the most significant of three digits at the address in I, the middle digit at I plus 1, and the
+	//       least significant digit at I plus 2. (In other words, take the decimal representation of VX, place the hundreds digit in memory at location in I, the tens digit
+	//       at location I+1, and the ones digit at location I+2.)
+	case OpFX33:
+		return cpu.execFX33(x)
+	// FX3A: Sets the audio pattern playback pitch to VX (XO-CHIP)
+	case OpFX3A:
+		cpu.execFX3A(x)
+	// FX55: Stores V0 to VX (including VX) in memory starting at address I. The offset from I is increased by 1 for each value written, but I itself is left unmodified
+	case OpFX55:
+		return cpu.execFX55(x)
+	// FX65: Fills V0 to VX (including VX) with values from memory starting at address I. The offset from I is increased by 1 for each value written, but I itself is left unmodified
+	case OpFX65:
+		return cpu.execFX65(x)
+	// FX75: Stores V0 to VX (including VX) in the RPL user flags (SUPER-CHIP)
+	case OpFX75:
+		cpu.execFX75(x)
+	// FX85: Fills V0 to VX (including VX) with values from the RPL user flags (SUPER-CHIP)
+	case OpFX85:
+		cpu.execFX85(x)
 	default:
-		panic(fmt.Sprintf("Unknown opcode: %X", opcode))
-	}
-}
-
-func (cpu *CPU) Debug() {
-	fmt.Println("===== CPU Debug =====")
-	fmt.Printf("OpCode: %X\n", cpu.getOpCode())
-	fmt.Printf("PC: %d\n", cpu.Register.PC)
-	fmt.Printf("SP: %d\n", cpu.Register.SP)
-	fmt.Printf("I: %d\n", cpu.Register.I)
-	for i, value := range cpu.Register.V {
-		fmt.Printf("V%d: %d\n", i, value)
+		return ErrUnknownOpcode{PC: cpu.Register.PC, Opcode: opcode}
 	}
-	fmt.Println("=====================")
+	return nil
 }
@@ -1,7 +1,10 @@
 package chip8
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
 	"testing"
 )
 
@@ -18,13 +21,6 @@ func TestCPU_LoadROM(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
-func TestCPU_Debug(t *testing.T) {
-	cpu := NewCPU()
-	_ = cpu.LoadROM("../roms/PONG")
-	cpu.Run()
-	cpu.Debug()
-}
-
 func TestCPU_Cycle(t *testing.T) {
 	cpu := NewCPU()
 	_ = cpu.LoadROM("../roms/PONG")
@@ -35,6 +31,46 @@ func TestCPU_Cycle(t *testing.T) {
 	assert.Equal(t, byte(17), cpu.Register.DT)
 }
 
+func TestCPU_TickTimers(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Register.ST = 1
+	cpu.Register.DT = 2
+	cpu.TickTimers()
+	assert.Equal(t, byte(0), cpu.Register.ST)
+	assert.Equal(t, byte(1), cpu.Register.DT)
+	cpu.TickTimers()
+	assert.Equal(t, byte(0), cpu.Register.ST)
+	assert.Equal(t, byte(0), cpu.Register.DT)
+	assert.Equal(t, uint16(0x200), cpu.Register.PC)
+}
+
+func TestCPU_RunTimersIndependentOfClock(t *testing.T) {
+	// Waits for one second using the delay timer and then loops forever at 0x20A
+	rom := []byte{
+		0x6A, 0x3C, // V[A] = 60
+		0xFA, 0x15, // DT = V[A]
+		0xFB, 0x07, // V[B] = DT
+		0x3B, 0x00, // Skip next instruction if V[B] == 0
+		0x12, 0x04, // Jump to 0x204
+		0x12, 0x0A, // Jump to 0x20A
+	}
+	framesUntilDone := func(instructionsPerFrame int) int {
+		cpu := NewCPU()
+		cpu.InstructionsPerFrame = instructionsPerFrame
+		copy(cpu.Memory.Memory[0x200:], rom)
+		frames := 0
+		for cpu.Register.PC != 0x20A {
+			assert.Nil(t, cpu.Run())
+			frames++
+		}
+		return frames
+	}
+	assert.Equal(t, 61, framesUntilDone(5))
+	assert.Equal(t, 61, framesUntilDone(7))
+	assert.Equal(t, 61, framesUntilDone(50))
+	assert.Equal(t, 61, framesUntilDone(1000))
+}
+
 func TestCPU_Reset(t *testing.T) {
 	cpu := NewCPU()
 	_ = cpu.LoadROM("../roms/PONG")
@@ -65,11 +101,125 @@ func TestCPU_ClearDisplay(t *testing.T) {
 	assert.Equal(t, newCPU, cpu)
 }
 
-func TestGetOpCode(t *testing.T) {
+func TestFetch(t *testing.T) {
 	cpu := NewCPU()
 	cpu.Memory.Memory[0x0A] = 0x10
 	cpu.Memory.Memory[0x0B] = 0x18
 	cpu.Register.PC = 0x0A
-	opCode := cpu.getOpCode()
+	opCode, err := cpu.fetch(0)
+	assert.Nil(t, err)
 	assert.Equal(t, uint16(0x1018), opCode)
 }
+
+func TestCPU_CycleUnknownOpcode(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Memory.Memory[0x200] = 0xE0
+	cpu.Memory.Memory[0x201] = 0x00
+	err := cpu.Cycle()
+	var unknownOpcode ErrUnknownOpcode
+	assert.True(t, errors.As(err, &unknownOpcode))
+	assert.Equal(t, ErrUnknownOpcode{PC: 0x200, Opcode: 0xE000}, unknownOpcode)
+	assert.Equal(t, uint16(0x200), cpu.Register.PC)
+}
+
+func TestCPU_CycleStackFault(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Memory.Memory[0x200] = 0x00
+	cpu.Memory.Memory[0x201] = 0xEE
+	err := cpu.Cycle()
+	var stackUnderflow ErrStackUnderflow
+	assert.True(t, errors.As(err, &stackUnderflow))
+
+	assert.EqualError(t, err, "stack underflow at 200, return outside of a subroutine")
+
+	cpu = NewCPU()
+	cpu.Memory.Memory[0x200] = 0x22
+	cpu.Memory.Memory[0x201] = 0x00
+	for i := 0; i < DefaultStackDepth; i++ {
+		assert.Nil(t, cpu.Cycle())
+	}
+	err = cpu.Run()
+	var stackOverflow ErrStackOverflow
+	assert.True(t, errors.As(err, &stackOverflow))
+	assert.Equal(t, byte(DefaultStackDepth), cpu.Register.SP)
+	assert.Len(t, stackOverflow.Calls, DefaultStackDepth)
+	assert.EqualError(t, err, "stack overflow at 200 after 16 nested calls: 200 x16")
+}
+
+func TestInstructionsPerFrame(t *testing.T) {
+	assert.Equal(t, DefaultInstructionsPerFrame, InstructionsPerFrame(400))
+	assert.Equal(t, 8, InstructionsPerFrame(450))
+	assert.Equal(t, 1, InstructionsPerFrame(10))
+	assert.Equal(t, 1, InstructionsPerFrame(0))
+	assert.Equal(t, 1, InstructionsPerFrame(-60))
+}
+
+func TestCPU_StackDepth(t *testing.T) {
+	// main calls a, a calls b, b calls itself
+	program := []byte{
+		0x22, 0x04, // 0x200: call a
+		0x12, 0x00, // 0x202: jump 0x200
+		0x22, 0x08, // 0x204 a: call b
+		0x00, 0xEE, // 0x206: return
+		0x22, 0x08, // 0x208 b: call b
+	}
+	for _, quirks := range []Quirks{QuirksVIP, QuirksSCHIP, QuirksXOCHIP, {}} {
+		cpu := NewCPU()
+		cpu.Quirks = quirks
+		copy(cpu.Memory.Memory[0x200:], program)
+		var err error
+		for err == nil {
+			err = cpu.Cycle()
+		}
+		var stackOverflow ErrStackOverflow
+		assert.True(t, errors.As(err, &stackOverflow))
+		assert.Equal(t, cpu.StackDepth(), int(cpu.Register.SP))
+		assert.Equal(t, []uint16{0x200, 0x204}, stackOverflow.Calls[:2])
+		assert.Equal(t, uint16(0x208), stackOverflow.PC)
+	}
+	cpu := NewCPU()
+	assert.Equal(t, 16, cpu.StackDepth())
+	cpu.Quirks = QuirksVIP
+	assert.Equal(t, 12, cpu.StackDepth())
+	cpu.Quirks = QuirksXOCHIP
+	assert.Equal(t, MaxStackDepth, cpu.StackDepth())
+	cpu.Quirks.StackDepth = 255
+	assert.Equal(t, MaxStackDepth, cpu.StackDepth())
+
+	err := ErrStackOverflow{PC: 0x208, Calls: []uint16{0x200, 0x204, 0x208, 0x208, 0x208}}
+	assert.EqualError(t, err, "stack overflow at 208 after 5 nested calls: 200 > 204 > 208 x3")
+}
+
+func TestCPU_CycleMemoryOutOfBounds(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Register.PC = 0xFFFF
+	err := cpu.Cycle()
+	var outOfBounds ErrMemoryOutOfBounds
+	assert.True(t, errors.As(err, &outOfBounds))
+	assert.Equal(t, 0x10000, outOfBounds.Addr)
+
+	cpu = NewCPU()
+	cpu.Memory.Memory[0x200] = 0xFF
+	cpu.Memory.Memory[0x201] = 0x55
+	cpu.Register.I = 0xFFF8
+	err = cpu.Cycle()
+	assert.True(t, errors.As(err, &outOfBounds))
+	assert.Equal(t, 0x10000, outOfBounds.Addr)
+	assert.Equal(t, uint16(0x200), cpu.Register.PC)
+}
+
+func TestCPU_DisplayImage(t *testing.T) {
+	palette := color.Palette{color.Black, color.White, color.Gray{Y: 0x80}, color.Gray{Y: 0x40}}
+	cpu := NewCPU()
+	cpu.Display[1][2] = 0x01
+	cpu.Display[31][63] = 0x03
+	img := cpu.DisplayImage(palette)
+	assert.Equal(t, image.Rect(0, 0, DisplayWidth, DisplayHeight), img.Bounds())
+	assert.Equal(t, uint8(0x00), img.ColorIndexAt(0, 0))
+	assert.Equal(t, uint8(0x01), img.ColorIndexAt(2, 1))
+	assert.Equal(t, uint8(0x03), img.ColorIndexAt(63, 31))
+
+	cpu.HiRes = true
+	img = cpu.DisplayImage(palette)
+	assert.Equal(t, image.Rect(0, 0, HiResDisplayWidth, HiResDisplayHeight), img.Bounds())
+}
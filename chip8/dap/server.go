@@ -0,0 +1,684 @@
+// Package dap serves the Debug Adapter Protocol, so editors like VS Code can debug CHIP-8 ROMs.
+//
+// The launch request loads the ROM of its program argument into a chip8.CPU, with the symbol file
+// written by gochip8 asm -sym next to it when there is one. Breakpoints are set on ROM addresses with
+// setInstructionBreakpoints, or on source lines with setBreakpoints when the symbol file maps them to
+// addresses. The stack trace is built from the CPU stack, the variables are the registers, the timers
+// and memory at I and PC. There is a single thread with the id 1.
+package dap
+
+import (
+	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/asm"
+	"GoCHIP-8/chip8/debugger"
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// threadID is the id of the only thread
+const threadID = 1
+
+// Variable references of the scopes
+const (
+	registersReference = iota + 1
+	timersReference
+	memoryReference
+)
+
+// memoryWindow is the number of bytes shown by the memory variables
+const memoryWindow = 16
+
+// Server launches and debugs ROMs for one client at a time
+type Server struct {
+	// Logger logs faults, nil to disable logging. It must not write to the output of the protocol.
+	Logger *log.Logger
+	// FrameDuration paces continue at the speed of the CPU, 0 runs as fast as possible
+	FrameDuration time.Duration
+}
+
+// NewServer returns a server running continued execution at 60 frames per second
+func NewServer() *Server {
+	return &Server{FrameDuration: time.Second / chip8.TimerFrequency}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, args...)
+	}
+}
+
+// session is the state of a client connection
+type session struct {
+	*Server
+	w        io.Writer
+	seq      int
+	requests chan request
+	// Closed when the session ends, to stop the reader
+	quit chan struct{}
+	// Error that ended the reader
+	readErr error
+
+	cpu      *chip8.CPU
+	debugger *debugger.Debugger
+	symbols  asm.Symbols
+	// Set by the launch request
+	stopOnEntry bool
+	launched    bool
+	configured  bool
+	running     bool
+	// Set when execution resumes, so the breakpoint at PC is not hit again
+	resumed bool
+	// Breakpoints of setBreakpoints by source path and of setInstructionBreakpoints
+	sourceBreakpoints      map[string][]addrBreakpoint
+	instructionBreakpoints []addrBreakpoint
+}
+
+// addrBreakpoint is a breakpoint requested by the client, resolved to an address
+type addrBreakpoint struct {
+	addr      uint16
+	condition string
+}
+
+// Serve speaks the protocol over r and w until the client disconnects
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	sess := &session{
+		Server:            s,
+		w:                 w,
+		requests:          make(chan request, 16),
+		quit:              make(chan struct{}),
+		sourceBreakpoints: make(map[string][]addrBreakpoint),
+	}
+	defer close(sess.quit)
+	go sess.read(bufio.NewReader(r))
+
+	var tick <-chan time.Time
+	if s.FrameDuration > 0 {
+		ticker := time.NewTicker(s.FrameDuration)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		var req request
+		ok := true
+		if !sess.running {
+			req, ok = <-sess.requests
+		} else {
+			if err := sess.runFrame(); err != nil {
+				return err
+			}
+			if !sess.running {
+				continue
+			}
+			if tick == nil {
+				select {
+				case req, ok = <-sess.requests:
+				default:
+					continue
+				}
+			} else {
+				select {
+				case req, ok = <-sess.requests:
+				case <-tick:
+					continue
+				}
+			}
+		}
+		if !ok {
+			if sess.readErr == io.EOF {
+				return nil
+			}
+			return sess.readErr
+		}
+		done, err := sess.handle(req)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// read parses the messages of the client into requests until the connection fails
+func (sess *session) read(r *bufio.Reader) {
+	defer close(sess.requests)
+	for {
+		data, err := readMessage(r)
+		if err != nil {
+			sess.readErr = err
+			return
+		}
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			sess.readErr = fmt.Errorf("invalid message: %w", err)
+			return
+		}
+		if req.Type != "request" {
+			continue
+		}
+		select {
+		case sess.requests <- req:
+		case <-sess.quit:
+			return
+		}
+	}
+}
+
+func (sess *session) send(v interface{}) error {
+	return writeMessage(sess.w, v)
+}
+
+func (sess *session) nextSeq() int {
+	sess.seq++
+	return sess.seq
+}
+
+func (sess *session) respond(req request, body interface{}) error {
+	return sess.send(response{
+		Seq:        sess.nextSeq(),
+		Type:       "response",
+		RequestSeq: req.Seq,
+		Success:    true,
+		Command:    req.Command,
+		Body:       body,
+	})
+}
+
+func (sess *session) fail(req request, err error) error {
+	return sess.send(response{
+		Seq:        sess.nextSeq(),
+		Type:       "response",
+		RequestSeq: req.Seq,
+		Command:    req.Command,
+		Message:    err.Error(),
+	})
+}
+
+func (sess *session) event(name string, body interface{}) error {
+	return sess.send(event{Seq: sess.nextSeq(), Type: "event", Event: name, Body: body})
+}
+
+var errNotLaunched = errors.New("no program launched")
+
+// handle answers a request, done is set when the session ends
+func (sess *session) handle(req request) (done bool, err error) {
+	switch req.Command {
+	case "initialize":
+		return false, sess.respond(req, capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsConditionalBreakpoints:   true,
+			SupportsInstructionBreakpoints:   true,
+			SupportsReadMemoryRequest:        true,
+			SupportsTerminateRequest:         true,
+		})
+	case "launch":
+		var args launchArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return false, sess.fail(req, err)
+		}
+		if err := sess.launch(args); err != nil {
+			return false, sess.fail(req, err)
+		}
+		if err := sess.respond(req, nil); err != nil {
+			return false, err
+		}
+		// Breakpoints are resolved with the symbols of the program, so configuration starts after launch
+		if err := sess.event("initialized", nil); err != nil {
+			return false, err
+		}
+		return false, sess.start()
+	case "configurationDone":
+		sess.configured = true
+		if err := sess.respond(req, nil); err != nil {
+			return false, err
+		}
+		return false, sess.start()
+	case "disconnect":
+		return true, sess.respond(req, nil)
+	case "terminate":
+		sess.running = false
+		if err := sess.respond(req, nil); err != nil {
+			return false, err
+		}
+		return false, sess.event("terminated", nil)
+	case "threads":
+		return false, sess.respond(req, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": threadID, "name": "CHIP-8"}},
+		})
+	}
+
+	if sess.debugger == nil {
+		return false, sess.fail(req, errNotLaunched)
+	}
+	switch req.Command {
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return false, sess.fail(req, err)
+		}
+		return false, sess.respond(req, map[string]interface{}{"breakpoints": sess.setBreakpoints(args)})
+	case "setInstructionBreakpoints":
+		var args setInstructionBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return false, sess.fail(req, err)
+		}
+		return false, sess.respond(req, map[string]interface{}{"breakpoints": sess.setInstructionBreakpoints(args)})
+	case "stackTrace":
+		var args stackTraceArguments
+		_ = json.Unmarshal(req.Arguments, &args)
+		frames := sess.stackTrace()
+		total := len(frames)
+		if args.StartFrame < len(frames) {
+			frames = frames[args.StartFrame:]
+		} else {
+			frames = nil
+		}
+		if args.Levels > 0 && args.Levels < len(frames) {
+			frames = frames[:args.Levels]
+		}
+		return false, sess.respond(req, map[string]interface{}{"stackFrames": frames, "totalFrames": total})
+	case "scopes":
+		return false, sess.respond(req, map[string]interface{}{"scopes": []scope{
+			{"Registers", registersReference, false},
+			{"Timers", timersReference, false},
+			{"Memory", memoryReference, false},
+		}})
+	case "variables":
+		var args variablesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return false, sess.fail(req, err)
+		}
+		return false, sess.respond(req, map[string]interface{}{"variables": sess.variables(args.VariablesReference)})
+	case "readMemory":
+		var args readMemoryArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return false, sess.fail(req, err)
+		}
+		body, err := sess.readMemory(args)
+		if err != nil {
+			return false, sess.fail(req, err)
+		}
+		return false, sess.respond(req, body)
+	case "continue":
+		sess.resume()
+		return false, sess.respond(req, map[string]interface{}{"allThreadsContinued": true})
+	case "pause":
+		if err := sess.respond(req, nil); err != nil {
+			return false, err
+		}
+		if !sess.running {
+			return false, nil
+		}
+		sess.running = false
+		return false, sess.stopped("pause", "")
+	case "next", "stepIn":
+		if err := sess.respond(req, nil); err != nil {
+			return false, err
+		}
+		if req.Command == "next" {
+			return false, sess.report(sess.debugger.StepOver())
+		}
+		return false, sess.report(sess.debugger.Step())
+	case "stepOut":
+		stop, err := sess.debugger.StepOut()
+		if err != nil {
+			return false, sess.fail(req, err)
+		}
+		if err := sess.respond(req, nil); err != nil {
+			return false, err
+		}
+		return false, sess.report(stop)
+	}
+	return false, sess.fail(req, fmt.Errorf("unsupported request %q", req.Command))
+}
+
+// launch loads the program and its symbols
+func (sess *session) launch(args launchArguments) error {
+	if args.Program == "" {
+		return errors.New("launch requires a program")
+	}
+	cpu, err := chip8.Options{
+		Quirks:  args.Quirks,
+		Clock:   args.Clock,
+		Seed:    args.Seed,
+		Memory:  args.Memory,
+		Protect: args.Protect,
+		Log: func(err error) {
+			sess.logf("ignored memory fault: %s", err)
+		},
+	}.NewCPU(args.Program)
+	if err != nil {
+		return err
+	}
+	symbolsPath := args.Symbols
+	if symbolsPath == "" {
+		symbolsPath = asm.SymbolsPath(args.Program)
+	}
+	f, err := os.Open(symbolsPath)
+	switch {
+	case err == nil:
+		sess.symbols, err = asm.ReadSymbols(f)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", symbolsPath, err)
+		}
+	case args.Symbols != "" || !os.IsNotExist(err):
+		// A missing symbol file is only an error when it was requested
+		return err
+	}
+	sess.cpu = &cpu
+	sess.debugger = debugger.New(sess.cpu)
+	sess.debugger.Symbols = sess.symbols
+	sess.stopOnEntry = args.StopOnEntry
+	sess.launched = true
+	return nil
+}
+
+// start runs or stops on entry once the program is launched and configured
+func (sess *session) start() error {
+	if !sess.launched || !sess.configured {
+		return nil
+	}
+	if sess.stopOnEntry {
+		return sess.stopped("entry", "")
+	}
+	sess.resume()
+	return nil
+}
+
+// resume continues execution from the next frame run
+func (sess *session) resume() {
+	sess.running = true
+	sess.resumed = true
+}
+
+// runFrame runs one frame of continued execution and reports where it stops
+func (sess *session) runFrame() error {
+	var stop debugger.Stop
+	if sess.resumed {
+		stop = sess.debugger.Continue(1)
+		sess.resumed = false
+	} else {
+		stop = sess.debugger.Frame()
+	}
+	if stop.Reason == debugger.StopLimit || stop.Reason == debugger.StopFrame {
+		return nil
+	}
+	sess.running = false
+	return sess.report(stop)
+}
+
+// report sends the events of a stop
+func (sess *session) report(stop debugger.Stop) error {
+	switch stop.Reason {
+	case debugger.StopBreakpoint:
+		return sess.stopped("breakpoint", "")
+	case debugger.StopWatchpoint:
+		return sess.stopped("data breakpoint", stop.String())
+	case debugger.StopFault:
+		sess.logf("CPU fault: %s", stop.Err)
+		return sess.stopped("exception", stop.Err.Error())
+	case debugger.StopExited:
+		if err := sess.event("exited", map[string]interface{}{"exitCode": 0}); err != nil {
+			return err
+		}
+		return sess.event("terminated", nil)
+	case debugger.StopLimit:
+		// Stepping over or out of a subroutine that does not return in time
+		return sess.stopped("pause", stop.String())
+	}
+	return sess.stopped("step", "")
+}
+
+func (sess *session) stopped(reason, text string) error {
+	return sess.event("stopped", stoppedEvent{
+		Reason:            reason,
+		Text:              text,
+		ThreadID:          threadID,
+		AllThreadsStopped: true,
+	})
+}
+
+// setBreakpoints replaces the breakpoints of a source file, lines are moved to the next line with code
+func (sess *session) setBreakpoints(args setBreakpointsArguments) []breakpoint {
+	results := make([]breakpoint, len(args.Breakpoints))
+	var resolved []addrBreakpoint
+	for i, b := range args.Breakpoints {
+		result := &results[i]
+		line, ok := sess.lineAtOrAfter(args.Source.Path, b.Line)
+		if !ok {
+			result.Line = b.Line
+			if len(sess.symbols.Lines) == 0 {
+				result.Message = "no symbol file, assemble the ROM with gochip8 asm -sym"
+			} else {
+				result.Message = "no code at or after this line"
+			}
+			continue
+		}
+		if b.Condition != "" {
+			if _, err := debugger.ParseCondition(b.Condition); err != nil {
+				result.Line = b.Line
+				result.Message = err.Error()
+				continue
+			}
+		}
+		*result = breakpoint{
+			Verified:             true,
+			Source:               &source{Name: filepath.Base(args.Source.Path), Path: args.Source.Path},
+			Line:                 line.Line,
+			InstructionReference: addressReference(line.Addr),
+		}
+		resolved = append(resolved, addrBreakpoint{line.Addr, b.Condition})
+	}
+	sess.sourceBreakpoints[args.Source.Path] = resolved
+	sess.applyBreakpoints()
+	return results
+}
+
+// setInstructionBreakpoints replaces the breakpoints set on addresses
+func (sess *session) setInstructionBreakpoints(args setInstructionBreakpointsArguments) []breakpoint {
+	results := make([]breakpoint, len(args.Breakpoints))
+	sess.instructionBreakpoints = nil
+	for i, b := range args.Breakpoints {
+		result := &results[i]
+		addr, err := parseReference(b.InstructionReference)
+		if err == nil {
+			addr += b.Offset
+			if addr < 0 || addr >= len(sess.cpu.Memory.Memory) {
+				err = fmt.Errorf("address 0x%X out of memory", addr)
+			}
+		}
+		if err == nil && b.Condition != "" {
+			_, err = debugger.ParseCondition(b.Condition)
+		}
+		if err != nil {
+			result.Message = err.Error()
+			continue
+		}
+		result.Verified = true
+		result.InstructionReference = addressReference(uint16(addr))
+		sess.instructionBreakpoints = append(sess.instructionBreakpoints, addrBreakpoint{uint16(addr), b.Condition})
+	}
+	sess.applyBreakpoints()
+	return results
+}
+
+// applyBreakpoints sets the breakpoints of all sources and addresses in the debugger
+func (sess *session) applyBreakpoints() {
+	d := sess.debugger
+	for _, b := range d.Breakpoints() {
+		d.ClearBreakpoint(b.Addr)
+	}
+	set := func(breakpoints []addrBreakpoint) {
+		for _, b := range breakpoints {
+			// Conditions were checked when the breakpoints were requested
+			_, _ = d.SetBreakpoint(b.addr, b.condition)
+		}
+	}
+	paths := make([]string, 0, len(sess.sourceBreakpoints))
+	for path := range sess.sourceBreakpoints {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		set(sess.sourceBreakpoints[path])
+	}
+	set(sess.instructionBreakpoints)
+}
+
+// lineAtOrAfter returns the first line with code at or after line in the file at path
+func (sess *session) lineAtOrAfter(path string, line int) (asm.Line, bool) {
+	var best asm.Line
+	found := false
+	for _, l := range sess.symbols.Lines {
+		if l.Line < line || !samePath(l.File, path) {
+			continue
+		}
+		if !found || l.Line < best.Line || l.Line == best.Line && l.Addr < best.Addr {
+			best = l
+			found = true
+		}
+	}
+	return best, found
+}
+
+// lineOf returns the line of the instruction containing addr
+func (sess *session) lineOf(addr uint16) (asm.Line, bool) {
+	var best asm.Line
+	found := false
+	for _, l := range sess.symbols.Lines {
+		if l.Addr <= addr && (!found || l.Addr > best.Addr) {
+			best = l
+			found = true
+		}
+	}
+	// Instructions are at most 4 bytes, farther lines are data or code without symbols
+	return best, found && addr-best.Addr < 4
+}
+
+// samePath reports whether a file of the symbols, relative to where it was assembled, is the path of the client
+func samePath(file, path string) bool {
+	file = filepath.ToSlash(filepath.Clean(file))
+	path = filepath.ToSlash(filepath.Clean(path))
+	return file == path || !filepath.IsAbs(file) && strings.HasSuffix(path, "/"+file)
+}
+
+// label returns the name of addr relative to the nearest label before it, like main+0x4
+func (sess *session) label(addr uint16) string {
+	if name := sess.symbols.Symbol(addr); name != "" {
+		return name
+	}
+	return addressReference(addr)
+}
+
+// stackTrace returns the frame at PC followed by the calls on the stack
+func (sess *session) stackTrace() []stackFrame {
+	addrs := append([]uint16{sess.cpu.Register.PC}, sess.debugger.Backtrace()...)
+	frames := make([]stackFrame, len(addrs))
+	for i, addr := range addrs {
+		frames[i] = stackFrame{
+			ID:                          i + 1,
+			Name:                        sess.label(addr),
+			InstructionPointerReference: addressReference(addr),
+		}
+		if line, ok := sess.lineOf(addr); ok {
+			path := line.File
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			frames[i].Source = &source{Name: filepath.Base(path), Path: path}
+			frames[i].Line = line.Line
+			frames[i].Column = 1
+		}
+	}
+	return frames
+}
+
+// variables returns the variables of a scope
+func (sess *session) variables(reference int) []variable {
+	r := sess.cpu.Register
+	switch reference {
+	case registersReference:
+		vars := make([]variable, 0, 19)
+		for i, v := range r.V {
+			vars = append(vars, variable{Name: fmt.Sprintf("V%X", i), Value: fmt.Sprintf("0x%02X", v)})
+		}
+		return append(vars,
+			variable{Name: "I", Value: addressReference(r.I), MemoryReference: addressReference(r.I)},
+			variable{Name: "PC", Value: addressReference(r.PC), MemoryReference: addressReference(r.PC)},
+			variable{Name: "SP", Value: strconv.Itoa(int(r.SP))},
+		)
+	case timersReference:
+		return []variable{
+			{Name: "DT", Value: strconv.Itoa(int(r.DT))},
+			{Name: "ST", Value: strconv.Itoa(int(r.ST))},
+		}
+	case memoryReference:
+		return []variable{sess.memoryVariable("[I]", r.I), sess.memoryVariable("[PC]", r.PC)}
+	}
+	return []variable{}
+}
+
+// memoryVariable shows the bytes of memory from addr
+func (sess *session) memoryVariable(name string, addr uint16) variable {
+	memory := sess.cpu.Memory.Memory[:]
+	end := int(addr) + memoryWindow
+	if end > len(memory) {
+		end = len(memory)
+	}
+	var sb strings.Builder
+	sb.WriteString(addressReference(addr) + ":")
+	for _, b := range memory[addr:end] {
+		sb.WriteString(fmt.Sprintf(" %02X", b))
+	}
+	return variable{Name: name, Value: sb.String(), MemoryReference: addressReference(addr)}
+}
+
+// readMemory answers readMemory requests, reads past the end of memory are unreadable
+func (sess *session) readMemory(args readMemoryArguments) (map[string]interface{}, error) {
+	addr, err := parseReference(args.MemoryReference)
+	if err != nil {
+		return nil, err
+	}
+	if args.Count < 0 {
+		return nil, fmt.Errorf("invalid count %d", args.Count)
+	}
+	addr += args.Offset
+	memory := sess.cpu.Memory.Memory[:]
+	if args.Count > len(memory) {
+		args.Count = len(memory)
+	}
+	if addr < 0 || addr >= len(memory) {
+		return map[string]interface{}{"address": fmt.Sprintf("0x%X", addr), "unreadableBytes": args.Count}, nil
+	}
+	end := addr + args.Count
+	if end > len(memory) {
+		end = len(memory)
+	}
+	return map[string]interface{}{
+		"address":         addressReference(uint16(addr)),
+		"data":            base64.StdEncoding.EncodeToString(memory[addr:end]),
+		"unreadableBytes": args.Count - (end - addr),
+	}, nil
+}
+
+// addressReference formats an address as instruction and memory references, like 0x2A0
+func addressReference(addr uint16) string {
+	return fmt.Sprintf("0x%03X", addr)
+}
+
+func parseReference(reference string) (int, error) {
+	addr, err := strconv.ParseUint(reference, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", reference)
+	}
+	return int(addr), nil
+}
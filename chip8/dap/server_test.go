@@ -0,0 +1,292 @@
+package dap
+
+import (
+	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/asm"
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// client speaks the protocol to a server over pipes
+type client struct {
+	t   *testing.T
+	w   io.Writer
+	r   *bufio.Reader
+	seq int
+	// Error returned by Serve
+	done chan error
+}
+
+func newClient(t *testing.T) *client {
+	serverR, clientW := io.Pipe()
+	clientR, serverW := io.Pipe()
+	c := &client{t: t, w: clientW, r: bufio.NewReader(clientR), done: make(chan error, 1)}
+	go func() {
+		err := (&Server{}).Serve(serverR, serverW)
+		_ = serverW.Close()
+		c.done <- err
+	}()
+	return c
+}
+
+// message is a response or an event, decoded generically
+type message map[string]interface{}
+
+func (c *client) request(command string, arguments interface{}) {
+	c.seq++
+	req := map[string]interface{}{"seq": c.seq, "type": "request", "command": command}
+	if arguments != nil {
+		req["arguments"] = arguments
+	}
+	assert.Nil(c.t, writeMessage(c.w, req))
+}
+
+func (c *client) read() message {
+	data, err := readMessage(c.r)
+	if !assert.Nil(c.t, err) {
+		c.t.FailNow()
+	}
+	var m message
+	assert.Nil(c.t, json.Unmarshal(data, &m))
+	return m
+}
+
+// response reads the response to the last request and returns its body
+func (c *client) response(command string) map[string]interface{} {
+	m := c.read()
+	assert.Equal(c.t, "response", m["type"])
+	assert.Equal(c.t, command, m["command"])
+	assert.Equal(c.t, float64(c.seq), m["request_seq"])
+	assert.Equal(c.t, true, m["success"], m["message"])
+	body, _ := m["body"].(map[string]interface{})
+	return body
+}
+
+// failure reads the failed response to the last request and returns its message
+func (c *client) failure(command string) string {
+	m := c.read()
+	assert.Equal(c.t, command, m["command"])
+	assert.Equal(c.t, false, m["success"])
+	text, _ := m["message"].(string)
+	return text
+}
+
+func (c *client) event(name string) map[string]interface{} {
+	m := c.read()
+	assert.Equal(c.t, "event", m["type"])
+	assert.Equal(c.t, name, m["event"])
+	body, _ := m["body"].(map[string]interface{})
+	return body
+}
+
+func (c *client) stopped(reason string) {
+	assert.Equal(c.t, reason, c.event("stopped")["reason"])
+}
+
+// frames returns the names and lines of the stack frames
+func (c *client) frames() [][2]interface{} {
+	c.request("stackTrace", map[string]interface{}{"threadId": threadID})
+	var frames [][2]interface{}
+	for _, f := range c.response("stackTrace")["stackFrames"].([]interface{}) {
+		frame := f.(map[string]interface{})
+		frames = append(frames, [2]interface{}{frame["name"], frame["line"]})
+	}
+	return frames
+}
+
+func (c *client) close() {
+	c.request("disconnect", nil)
+	c.response("disconnect")
+	assert.Nil(c.t, <-c.done)
+}
+
+const program = `: main
+	v0 := 1
+	sub
+	v1 := 2
+: loop
+	jump loop
+: sub
+	v2 := 3
+	return
+`
+
+// assemble writes the program, its ROM and its symbol file to a temporary directory
+func assemble(t *testing.T) (src, rom string) {
+	dir, err := ioutil.TempDir("", "dap")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	src = filepath.Join(dir, "program.8o")
+	rom = filepath.Join(dir, "program.ch8")
+	assert.Nil(t, ioutil.WriteFile(src, []byte(program), 0644))
+	p, err := asm.AssembleFile(src)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(rom, p.ROM, 0644))
+	f, err := os.Create(asm.SymbolsPath(rom))
+	assert.Nil(t, err)
+	assert.Nil(t, asm.WriteSymbols(f, p.Symbols()))
+	assert.Nil(t, f.Close())
+	return src, rom
+}
+
+// launch initializes the session and launches rom stopped on entry
+func (c *client) launch(rom string) {
+	c.request("initialize", map[string]interface{}{"adapterID": "gochip8"})
+	assert.Equal(c.t, true, c.response("initialize")["supportsInstructionBreakpoints"])
+	c.request("launch", map[string]interface{}{"program": rom, "stopOnEntry": true})
+	c.response("launch")
+	c.event("initialized")
+}
+
+func TestSession(t *testing.T) {
+	src, rom := assemble(t)
+	c := newClient(t)
+	c.launch(rom)
+
+	// Line 7 is a label, the breakpoint moves to the instruction on line 8
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": src},
+		"breakpoints": []interface{}{map[string]interface{}{"line": 7}, map[string]interface{}{"line": 20}},
+	})
+	breakpoints := c.response("setBreakpoints")["breakpoints"].([]interface{})
+	assert.Equal(t, true, breakpoints[0].(map[string]interface{})["verified"])
+	assert.Equal(t, float64(8), breakpoints[0].(map[string]interface{})["line"])
+	assert.Equal(t, "0x208", breakpoints[0].(map[string]interface{})["instructionReference"])
+	assert.Equal(t, false, breakpoints[1].(map[string]interface{})["verified"])
+	c.request("configurationDone", nil)
+	c.response("configurationDone")
+	c.stopped("entry")
+
+	c.request("threads", nil)
+	assert.Len(t, c.response("threads")["threads"], 1)
+	assert.Equal(t, [][2]interface{}{{"main", float64(2)}}, c.frames())
+
+	c.request("continue", map[string]interface{}{"threadId": threadID})
+	c.response("continue")
+	c.stopped("breakpoint")
+	assert.Equal(t, [][2]interface{}{{"sub", float64(8)}, {"main+0x2", float64(3)}}, c.frames())
+
+	c.request("scopes", map[string]interface{}{"frameId": 1})
+	assert.Len(t, c.response("scopes")["scopes"], 3)
+	c.request("variables", map[string]interface{}{"variablesReference": registersReference})
+	vars := c.response("variables")["variables"].([]interface{})
+	assert.Len(t, vars, 19)
+	assert.Equal(t, map[string]interface{}{"name": "V0", "value": "0x01", "variablesReference": float64(0)}, vars[0])
+	assert.Equal(t, "0x208", vars[17].(map[string]interface{})["value"])
+	assert.Equal(t, "1", vars[18].(map[string]interface{})["value"])
+	c.request("variables", map[string]interface{}{"variablesReference": memoryReference})
+	vars = c.response("variables")["variables"].([]interface{})
+	assert.Equal(t, "0x208: 62 03 00 EE 00 00 00 00 00 00 00 00 00 00 00 00", vars[1].(map[string]interface{})["value"])
+	c.request("readMemory", map[string]interface{}{"memoryReference": "0x200", "count": 4})
+	body := c.response("readMemory")
+	assert.Equal(t, "0x200", body["address"])
+	assert.Equal(t, "YAEiCA==", body["data"])
+	c.request("readMemory", map[string]interface{}{"memoryReference": "0xFFFE", "count": 1 << 30})
+	body = c.response("readMemory")
+	assert.Equal(t, "AAA=", body["data"])
+	assert.Equal(t, float64(65536-2), body["unreadableBytes"])
+	c.request("readMemory", map[string]interface{}{"memoryReference": "0x200", "count": -4})
+	assert.Equal(t, "invalid count -4", c.failure("readMemory"))
+
+	c.request("stepIn", map[string]interface{}{"threadId": threadID})
+	c.response("stepIn")
+	c.stopped("step")
+	assert.Equal(t, [][2]interface{}{{"sub+0x2", float64(9)}, {"main+0x2", float64(3)}}, c.frames())
+	c.request("stepOut", map[string]interface{}{"threadId": threadID})
+	c.response("stepOut")
+	c.stopped("step")
+	assert.Equal(t, [][2]interface{}{{"main+0x4", float64(4)}}, c.frames())
+	c.request("stepOut", map[string]interface{}{"threadId": threadID})
+	assert.Equal(t, "not in a subroutine", c.failure("stepOut"))
+	c.request("next", map[string]interface{}{"threadId": threadID})
+	c.response("next")
+	c.stopped("step")
+	assert.Equal(t, [][2]interface{}{{"loop", float64(6)}}, c.frames())
+
+	c.request("continue", map[string]interface{}{"threadId": threadID})
+	c.response("continue")
+	c.request("pause", map[string]interface{}{"threadId": threadID})
+	c.response("pause")
+	c.stopped("pause")
+	c.close()
+}
+
+func TestInstructionBreakpoints(t *testing.T) {
+	_, rom := assemble(t)
+	assert.Nil(t, os.Remove(asm.SymbolsPath(rom)))
+	c := newClient(t)
+	c.launch(rom)
+
+	// Without symbols, only addresses can be used
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": "program.8o"},
+		"breakpoints": []interface{}{map[string]interface{}{"line": 2}},
+	})
+	breakpoint := c.response("setBreakpoints")["breakpoints"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, false, breakpoint["verified"])
+	assert.Contains(t, breakpoint["message"], "no symbol file")
+	c.request("setInstructionBreakpoints", map[string]interface{}{
+		"breakpoints": []interface{}{
+			map[string]interface{}{"instructionReference": "0x206", "condition": "V2 == 3"},
+			map[string]interface{}{"instructionReference": "0x200", "offset": 0x10000},
+			map[string]interface{}{"instructionReference": "0x204", "condition": "V9 ="},
+		},
+	})
+	breakpoints := c.response("setInstructionBreakpoints")["breakpoints"].([]interface{})
+	assert.Equal(t, true, breakpoints[0].(map[string]interface{})["verified"])
+	assert.Equal(t, false, breakpoints[1].(map[string]interface{})["verified"])
+	assert.Equal(t, false, breakpoints[2].(map[string]interface{})["verified"])
+	c.request("configurationDone", nil)
+	c.response("configurationDone")
+	c.stopped("entry")
+
+	c.request("continue", map[string]interface{}{"threadId": threadID})
+	c.response("continue")
+	c.stopped("breakpoint")
+	assert.Equal(t, [][2]interface{}{{"0x206", float64(0)}}, c.frames())
+
+	// Continuing from the breakpoint does not hit it again, until the loop comes back to it
+	c.request("continue", map[string]interface{}{"threadId": threadID})
+	c.response("continue")
+	c.stopped("breakpoint")
+	c.close()
+}
+
+func TestLaunchOptions(t *testing.T) {
+	sess := &session{Server: &Server{}}
+	assert.Nil(t, sess.launch(launchArguments{Program: "../../roms/PONG"}))
+	assert.Equal(t, chip8.QuirksOriginal, sess.cpu.Quirks)
+	assert.Equal(t, chip8.DefaultInstructionsPerFrame, sess.cpu.InstructionsPerFrame)
+
+	sess = &session{Server: &Server{}}
+	assert.Nil(t, sess.launch(launchArguments{Program: "../../roms/PONG", Quirks: "schip", Clock: 600, Seed: 3, Memory: "wrap", Protect: "rom"}))
+	assert.Equal(t, chip8.QuirksSCHIP, sess.cpu.Quirks)
+	assert.Equal(t, 10, sess.cpu.InstructionsPerFrame)
+	assert.Equal(t, int64(3), sess.cpu.Seed)
+	assert.Equal(t, chip8.AccessWrap, sess.cpu.Memory.Policy)
+	assert.True(t, sess.cpu.Memory.Protected(0x200))
+}
+
+func TestLaunchErrors(t *testing.T) {
+	c := newClient(t)
+	c.request("initialize", nil)
+	c.response("initialize")
+	c.request("stackTrace", nil)
+	assert.Equal(t, "no program launched", c.failure("stackTrace"))
+	c.request("launch", map[string]interface{}{})
+	assert.Equal(t, "launch requires a program", c.failure("launch"))
+	c.request("launch", map[string]interface{}{"program": "../../roms/PONG", "quirks": "nope"})
+	assert.Equal(t, "unknown quirks preset: nope", c.failure("launch"))
+	c.request("launch", map[string]interface{}{"program": "../../roms/PONG", "symbols": "missing.sym"})
+	assert.Contains(t, c.failure("launch"), "missing.sym")
+	c.request("evaluate", nil)
+	assert.Contains(t, c.failure("evaluate"), "no program launched")
+	c.close()
+}
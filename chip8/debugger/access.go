@@ -0,0 +1,31 @@
+package debugger
+
+import (
+	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/bus"
+)
+
+// access is a byte of memory read or written by an instruction, with the value read or written
+type access struct {
+	addr  int
+	value byte
+	write bool
+}
+
+// observe routes the memory accesses of cpu through an observer collecting them in accesses, besides the
+// instruction fetches, until restore is called. The bus of the CPU keeps carrying the accesses.
+func observe(cpu *chip8.CPU, accesses *[]access) (restore func()) {
+	original := cpu.Bus
+	inner := original
+	if inner == nil {
+		inner = &cpu.Memory
+	}
+	cpu.Bus = &bus.Observer{Bus: inner, Observe: func(addr uint16, value byte, kind chip8.AccessKind) {
+		if kind != chip8.FetchAccess {
+			*accesses = append(*accesses, access{int(addr), value, kind == chip8.WriteAccess})
+		}
+	}}
+	return func() {
+		cpu.Bus = original
+	}
+}
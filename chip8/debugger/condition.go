@@ -0,0 +1,139 @@
+package debugger
+
+import (
+	"GoCHIP-8/chip8"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// registerNames are the registers that can be watched and used in conditions
+var registerNames = []string{
+	"V0", "V1", "V2", "V3", "V4", "V5", "V6", "V7",
+	"V8", "V9", "VA", "VB", "VC", "VD", "VE", "VF",
+	"I", "PC", "SP", "DT", "ST",
+}
+
+// registerValue returns the value of a register by name, like V3 or DT
+func registerValue(cpu *chip8.CPU, name string) (int, bool) {
+	switch strings.ToUpper(name) {
+	case "I":
+		return int(cpu.Register.I), true
+	case "PC":
+		return int(cpu.Register.PC), true
+	case "SP":
+		return int(cpu.Register.SP), true
+	case "DT":
+		return int(cpu.Register.DT), true
+	case "ST":
+		return int(cpu.Register.ST), true
+	}
+	if x, ok := parseV(name); ok {
+		return int(cpu.Register.V[x]), true
+	}
+	return 0, false
+}
+
+func parseV(name string) (int, bool) {
+	if len(name) != 2 || name[0] != 'V' && name[0] != 'v' {
+		return 0, false
+	}
+	x, err := strconv.ParseUint(name[1:], 16, 8)
+	return int(x), err == nil
+}
+
+// operand of a condition: a register, a byte of memory like [0x300] or a number
+type operand struct {
+	register string
+	memory   bool
+	value    int
+}
+
+func parseOperand(text string) (operand, error) {
+	if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+		addr, err := parseAddress(text[1 : len(text)-1])
+		if err != nil {
+			return operand{}, err
+		}
+		return operand{memory: true, value: int(addr)}, nil
+	}
+	for _, name := range registerNames {
+		if strings.EqualFold(text, name) {
+			return operand{register: name}, nil
+		}
+	}
+	value, err := strconv.ParseInt(text, 0, 32)
+	if err != nil {
+		return operand{}, fmt.Errorf("invalid operand %q, expected a register, [address] or number", text)
+	}
+	return operand{value: int(value)}, nil
+}
+
+func (o operand) eval(cpu *chip8.CPU) int {
+	if o.register != "" {
+		value, _ := registerValue(cpu, o.register)
+		return value
+	}
+	if o.memory {
+		return int(cpu.Memory.Memory[o.value])
+	}
+	return o.value
+}
+
+// Condition compares two operands, like "V3 == 0x10", "I >= 0x300" or "[0x300] != 0"
+type Condition struct {
+	text        string
+	left, right operand
+	op          string
+}
+
+var conditionPattern = regexp.MustCompile(`^\s*(\S+?)\s*(==|!=|<=|>=|<|>)\s*(\S+)\s*$`)
+
+// ParseCondition parses a comparison of registers, memory bytes and numbers with ==, !=, <, <=, > or >=
+func ParseCondition(text string) (*Condition, error) {
+	match := conditionPattern.FindStringSubmatch(text)
+	if match == nil {
+		return nil, fmt.Errorf("invalid condition %q, expected like \"V3 == 0x10\"", text)
+	}
+	left, err := parseOperand(match[1])
+	if err != nil {
+		return nil, err
+	}
+	right, err := parseOperand(match[3])
+	if err != nil {
+		return nil, err
+	}
+	return &Condition{text: strings.TrimSpace(text), left: left, right: right, op: match[2]}, nil
+}
+
+// Eval reports whether the condition holds for the state of cpu
+func (c *Condition) Eval(cpu *chip8.CPU) bool {
+	left, right := c.left.eval(cpu), c.right.eval(cpu)
+	switch c.op {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	}
+	return left >= right
+}
+
+func (c *Condition) String() string {
+	return c.text
+}
+
+// parseAddress parses an address like 0x2A0, decimal numbers are accepted too
+func parseAddress(text string) (uint16, error) {
+	value, err := strconv.ParseUint(text, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", text)
+	}
+	return uint16(value), nil
+}
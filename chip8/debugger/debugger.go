@@ -0,0 +1,417 @@
+// Package debugger runs a chip8.CPU under control of breakpoints, watchpoints and stepping commands.
+package debugger
+
+import (
+	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/asm"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrOutermostFrame is returned by StepOut when no subroutine is running
+var ErrOutermostFrame = errors.New("not in a subroutine")
+
+// Breakpoint stops execution before the instruction at Addr, when Condition holds if it is set
+type Breakpoint struct {
+	Addr      uint16
+	Condition *Condition
+}
+
+func (b *Breakpoint) String() string {
+	if b.Condition != nil {
+		return fmt.Sprintf("0x%03X if %s", b.Addr, b.Condition)
+	}
+	return fmt.Sprintf("0x%03X", b.Addr)
+}
+
+// WatchKind selects what a watchpoint stops on
+type WatchKind int
+
+const (
+	// WatchWrite stops after an instruction writes to the watched memory
+	WatchWrite WatchKind = iota
+	// WatchRead stops after an instruction reads the watched memory
+	WatchRead
+	// WatchAccess stops after an instruction reads or writes the watched memory
+	WatchAccess
+	// WatchRegister stops after an instruction changes the watched register
+	WatchRegister
+)
+
+// Watchpoint stops execution after an instruction accesses Length bytes of memory at Addr,
+// or changes Register for register watchpoints
+type Watchpoint struct {
+	ID       int
+	Kind     WatchKind
+	Addr     uint16
+	Length   int
+	Register string
+}
+
+func (w *Watchpoint) String() string {
+	var what string
+	switch w.Kind {
+	case WatchRegister:
+		return fmt.Sprintf("%d: register %s", w.ID, w.Register)
+	case WatchRead:
+		what = "read"
+	case WatchWrite:
+		what = "write"
+	default:
+		what = "access"
+	}
+	if w.Length == 1 {
+		return fmt.Sprintf("%d: %s 0x%03X", w.ID, what, w.Addr)
+	}
+	return fmt.Sprintf("%d: %s 0x%03X-0x%03X", w.ID, what, w.Addr, int(w.Addr)+w.Length-1)
+}
+
+// StopReason tells why execution stopped
+type StopReason int
+
+const (
+	// StopStep is the end of a step, step over, step out or run to cursor
+	StopStep StopReason = iota
+	// StopFrame is the end of a frame run by Frame
+	StopFrame
+	// StopLimit is the end of the frames allowed by Continue or Debugger.Limit
+	StopLimit
+	// StopBreakpoint is a breakpoint hit before executing the instruction at PC
+	StopBreakpoint
+	// StopWatchpoint is a watchpoint hit by the last instruction executed
+	StopWatchpoint
+	// StopFault is a CPU fault, Stop.Err holds it
+	StopFault
+	// StopExited is the end of the program by 00FD
+	StopExited
+)
+
+// Stop describes where and why execution stopped
+type Stop struct {
+	Reason     StopReason
+	PC         uint16
+	Breakpoint *Breakpoint
+	Watchpoint *Watchpoint
+	// Address accessed and the values before and after the instruction for watchpoints,
+	// Old and New are register values for register watchpoints
+	Addr     int
+	Old, New int
+	Err      error
+}
+
+func (s Stop) String() string {
+	switch s.Reason {
+	case StopBreakpoint:
+		return fmt.Sprintf("breakpoint %s", s.Breakpoint)
+	case StopWatchpoint:
+		if s.Watchpoint.Kind == WatchRegister {
+			return fmt.Sprintf("watchpoint %s changed 0x%02X -> 0x%02X at 0x%03X", s.Watchpoint, s.Old, s.New, s.PC)
+		}
+		if s.Old != s.New {
+			return fmt.Sprintf("watchpoint %s, 0x%03X changed 0x%02X -> 0x%02X, now at 0x%03X", s.Watchpoint, s.Addr, s.Old, s.New, s.PC)
+		}
+		return fmt.Sprintf("watchpoint %s, 0x%03X = 0x%02X, now at 0x%03X", s.Watchpoint, s.Addr, s.New, s.PC)
+	case StopFault:
+		return fmt.Sprintf("fault: %s", s.Err)
+	case StopExited:
+		return "program exited"
+	case StopLimit:
+		return fmt.Sprintf("still running at 0x%03X", s.PC)
+	}
+	return fmt.Sprintf("stopped at 0x%03X", s.PC)
+}
+
+// Debugger controls the execution of a CPU. Timers are ticked after every CPU.InstructionsPerFrame
+// instructions, so they keep their pace relative to the instructions however execution is stepped.
+type Debugger struct {
+	CPU *chip8.CPU
+	// Cycle executes one instruction, it defaults to CPU.Cycle. Front ends wrap it to poll their input.
+	Cycle func() error
+	// Frames StepOver, StepOut and RunTo run at most before stopping with StopLimit, 0 for no limit
+	Limit int
+	// FrameEnd is called after the timers are ticked at the end of every frame when not nil.
+	// Front ends use it to update the input for the next frame.
+	FrameEnd func()
+	// Symbols name the addresses of the call stack, they are empty for ROMs without symbol file
+	Symbols asm.Symbols
+
+	breakpoints map[uint16]*Breakpoint
+	watchpoints []*Watchpoint
+	nextWatchID int
+	// Instructions executed in the current frame
+	frameCycles int
+	// Set when stopped at a breakpoint, so resuming does not stop at it again
+	atBreakpoint bool
+}
+
+// New returns a debugger controlling cpu
+func New(cpu *chip8.CPU) *Debugger {
+	return &Debugger{
+		CPU:         cpu,
+		Cycle:       cpu.Cycle,
+		Limit:       60 * chip8.TimerFrequency,
+		breakpoints: make(map[uint16]*Breakpoint),
+		nextWatchID: 1,
+	}
+}
+
+// SetBreakpoint sets a breakpoint at addr, condition is optional and parsed with ParseCondition.
+// A breakpoint already at addr is replaced.
+func (d *Debugger) SetBreakpoint(addr uint16, condition string) (*Breakpoint, error) {
+	breakpoint := &Breakpoint{Addr: addr}
+	if condition != "" {
+		c, err := ParseCondition(condition)
+		if err != nil {
+			return nil, err
+		}
+		breakpoint.Condition = c
+	}
+	d.breakpoints[addr] = breakpoint
+	return breakpoint, nil
+}
+
+// ClearBreakpoint removes the breakpoint at addr and reports whether there was one
+func (d *Debugger) ClearBreakpoint(addr uint16) bool {
+	_, ok := d.breakpoints[addr]
+	delete(d.breakpoints, addr)
+	return ok
+}
+
+// Breakpoints returns the breakpoints sorted by address
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	breakpoints := make([]*Breakpoint, 0, len(d.breakpoints))
+	for _, b := range d.breakpoints {
+		breakpoints = append(breakpoints, b)
+	}
+	sort.Slice(breakpoints, func(i, j int) bool { return breakpoints[i].Addr < breakpoints[j].Addr })
+	return breakpoints
+}
+
+// Watch adds a memory watchpoint of kind WatchRead, WatchWrite or WatchAccess on length bytes at addr
+func (d *Debugger) Watch(kind WatchKind, addr uint16, length int) (*Watchpoint, error) {
+	if kind == WatchRegister {
+		return nil, errors.New("use WatchRegister to watch a register")
+	}
+	if length < 1 || int(addr)+length > len(d.CPU.Memory.Memory) {
+		return nil, fmt.Errorf("invalid watch range 0x%03X length %d", addr, length)
+	}
+	return d.addWatchpoint(&Watchpoint{Kind: kind, Addr: addr, Length: length}), nil
+}
+
+// WatchRegister adds a watchpoint on a register: V0-VF, I, PC, SP, DT or ST
+func (d *Debugger) WatchRegister(name string) (*Watchpoint, error) {
+	for _, register := range registerNames {
+		if strings.EqualFold(name, register) {
+			return d.addWatchpoint(&Watchpoint{Kind: WatchRegister, Register: register}), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown register %s", name)
+}
+
+func (d *Debugger) addWatchpoint(w *Watchpoint) *Watchpoint {
+	w.ID = d.nextWatchID
+	d.nextWatchID++
+	d.watchpoints = append(d.watchpoints, w)
+	return w
+}
+
+// Unwatch removes the watchpoint with the given ID and reports whether there was one
+func (d *Debugger) Unwatch(id int) bool {
+	for i, w := range d.watchpoints {
+		if w.ID == id {
+			d.watchpoints = append(d.watchpoints[:i], d.watchpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Watchpoints returns the watchpoints in the order they were added
+func (d *Debugger) Watchpoints() []*Watchpoint {
+	return append([]*Watchpoint(nil), d.watchpoints...)
+}
+
+// Step executes one instruction
+func (d *Debugger) Step() Stop {
+	return d.run(true, 0, func() bool { return true })
+}
+
+// StepOver executes one instruction, a subroutine call is run until it returns
+func (d *Debugger) StepOver() Stop {
+	pc := d.CPU.Register.PC
+	if int(pc)+1 >= len(d.CPU.Memory.Memory) || d.CPU.Memory.Memory[pc]>>4 != 0x2 {
+		return d.Step()
+	}
+	sp := d.CPU.Register.SP
+	return d.run(true, d.Limit, func() bool {
+		return d.CPU.Register.SP == sp && d.CPU.Register.PC == pc+2
+	})
+}
+
+// StepOut runs until the current subroutine returns
+func (d *Debugger) StepOut() (Stop, error) {
+	sp := d.CPU.Register.SP
+	if sp == 0 {
+		return Stop{}, ErrOutermostFrame
+	}
+	return d.run(true, d.Limit, func() bool { return d.CPU.Register.SP < sp }), nil
+}
+
+// RunTo runs until PC reaches addr, like a temporary breakpoint
+func (d *Debugger) RunTo(addr uint16) Stop {
+	return d.run(true, d.Limit, func() bool { return d.CPU.Register.PC == addr })
+}
+
+// Continue runs until a breakpoint or watchpoint is hit, or at most frames frames
+func (d *Debugger) Continue(frames int) Stop {
+	return d.run(true, frames, func() bool { return false })
+}
+
+// Frame runs until the end of the current frame, when the timers are ticked. It is meant to be called
+// once per frame by front ends, and returns early when a breakpoint or watchpoint is hit.
+func (d *Debugger) Frame() Stop {
+	stop := d.run(d.atBreakpoint, 0, func() bool { return d.frameCycles == 0 })
+	if stop.Reason == StopStep {
+		stop.Reason = StopFrame
+	}
+	return stop
+}
+
+// run executes instructions until done reports true after an instruction, execution stops or frames frames
+// were run when it is not 0. The breakpoint at the current PC is ignored when skipBreakpoint is set.
+func (d *Debugger) run(skipBreakpoint bool, frames int, done func() bool) Stop {
+	cpu := d.CPU
+	for first := true; ; first = false {
+		if cpu.Exited {
+			return Stop{Reason: StopExited, PC: cpu.Register.PC}
+		}
+		if !first || !skipBreakpoint {
+			if b, ok := d.breakpoints[cpu.Register.PC]; ok && (b.Condition == nil || b.Condition.Eval(cpu)) {
+				d.atBreakpoint = true
+				return Stop{Reason: StopBreakpoint, PC: cpu.Register.PC, Breakpoint: b}
+			}
+		}
+		d.atBreakpoint = false
+		stop, stopped := d.step()
+		if stopped {
+			return stop
+		}
+		if done() {
+			return Stop{Reason: StopStep, PC: cpu.Register.PC}
+		}
+		if frames > 0 && d.frameCycles == 0 {
+			frames--
+			if frames == 0 {
+				return Stop{Reason: StopLimit, PC: cpu.Register.PC}
+			}
+		}
+	}
+}
+
+// step executes one instruction and checks the watchpoints
+func (d *Debugger) step() (Stop, bool) {
+	cpu := d.CPU
+	pc := cpu.Register.PC
+	var registers []int
+	var memory [][]byte
+	var accesses []access
+	watchesMemory := false
+	if len(d.watchpoints) > 0 {
+		registers = make([]int, len(d.watchpoints))
+		memory = make([][]byte, len(d.watchpoints))
+		for i, w := range d.watchpoints {
+			if w.Kind == WatchRegister {
+				registers[i], _ = registerValue(cpu, w.Register)
+			} else {
+				memory[i] = append([]byte(nil), cpu.Memory.Memory[w.Addr:int(w.Addr)+w.Length]...)
+				watchesMemory = true
+			}
+		}
+	}
+	err := func() error {
+		if watchesMemory {
+			defer observe(cpu, &accesses)()
+		}
+		return d.Cycle()
+	}()
+	if err != nil {
+		return Stop{Reason: StopFault, PC: pc, Err: err}, true
+	}
+	d.frameCycles++
+	if d.frameCycles >= cpu.InstructionsPerFrame {
+		cpu.TickTimers()
+		d.frameCycles = 0
+		if d.FrameEnd != nil {
+			d.FrameEnd()
+		}
+	}
+	for i, w := range d.watchpoints {
+		if w.Kind == WatchRegister {
+			value, _ := registerValue(cpu, w.Register)
+			// PC changes with every instruction, so it only stops on jumps
+			if w.Register == "PC" && (value == int(pc)+2 || value == int(pc)+4) {
+				continue
+			}
+			if value != registers[i] {
+				return Stop{Reason: StopWatchpoint, PC: cpu.Register.PC, Watchpoint: w, Old: registers[i], New: value}, true
+			}
+			continue
+		}
+		// Report the first watched byte accessed
+		for _, a := range accesses {
+			if a.write && w.Kind == WatchRead || !a.write && w.Kind == WatchWrite {
+				continue
+			}
+			if a.addr < int(w.Addr) || a.addr >= int(w.Addr)+w.Length {
+				continue
+			}
+			return Stop{
+				Reason:     StopWatchpoint,
+				PC:         cpu.Register.PC,
+				Watchpoint: w,
+				Addr:       a.addr,
+				Old:        int(memory[i][a.addr-int(w.Addr)]),
+				New:        int(a.value),
+			}, true
+		}
+	}
+	if cpu.Exited {
+		return Stop{Reason: StopExited, PC: cpu.Register.PC}, true
+	}
+	return Stop{}, false
+}
+
+// Backtrace returns the addresses of the calls on the stack, innermost first
+func (d *Debugger) Backtrace() []uint16 {
+	sp := int(d.CPU.Register.SP)
+	if sp > len(d.CPU.Stack) {
+		sp = len(d.CPU.Stack)
+	}
+	calls := make([]uint16, 0, sp)
+	for i := sp - 1; i >= 0; i-- {
+		calls = append(calls, d.CPU.Stack[i])
+	}
+	return calls
+}
+
+// Call is a subroutine call on the stack
+type Call struct {
+	// Addr is the address of the 2NNN instruction
+	Addr uint16
+	// Return is the address execution continues at after 00EE
+	Return uint16
+	// Symbol names Return with the labels of Symbols, it is empty when unknown
+	Symbol string
+}
+
+// CallStack returns the calls on the stack with their return addresses, innermost first
+func (d *Debugger) CallStack() []Call {
+	calls := d.Backtrace()
+	stack := make([]Call, len(calls))
+	for i, addr := range calls {
+		stack[i] = Call{Addr: addr, Return: addr + 2, Symbol: d.Symbols.Symbol(addr + 2)}
+	}
+	return stack
+}
@@ -0,0 +1,317 @@
+package debugger
+
+import (
+	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/asm"
+	"GoCHIP-8/chip8/bus"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// newTestCPU loads a program calling a subroutine that stores and loads registers at 0x300
+func newTestCPU() *chip8.CPU {
+	cpu := chip8.NewCPU()
+	copy(cpu.Memory.Memory[0x200:], []byte{
+		0x60, 0x03, // 0x200: v0 := 3
+		0x22, 0x08, // 0x202: call 0x208
+		0x70, 0x01, // 0x204: v0 += 1
+		0x12, 0x06, // 0x206: jump 0x206
+		0x61, 0x10, // 0x208: v1 := 0x10
+		0xA3, 0x00, // 0x20A: i := 0x300
+		0xF1, 0x55, // 0x20C: save v1
+		0xF0, 0x65, // 0x20E: load v0
+		0x00, 0xEE, // 0x210: return
+	})
+	return &cpu
+}
+
+func TestBreakpoint(t *testing.T) {
+	d := New(newTestCPU())
+	_, err := d.SetBreakpoint(0x204, "")
+	assert.Nil(t, err)
+	stop := d.Continue(10)
+	assert.Equal(t, StopBreakpoint, stop.Reason)
+	assert.Equal(t, uint16(0x204), stop.PC)
+	assert.Equal(t, uint16(0x204), d.CPU.Register.PC)
+	// Continuing runs past the breakpoint into the endless loop
+	stop = d.Continue(10)
+	assert.Equal(t, StopLimit, stop.Reason)
+	assert.Equal(t, uint16(0x206), stop.PC)
+
+	assert.True(t, d.ClearBreakpoint(0x204))
+	assert.False(t, d.ClearBreakpoint(0x204))
+	assert.Empty(t, d.Breakpoints())
+}
+
+func TestConditionalBreakpoint(t *testing.T) {
+	d := New(newTestCPU())
+	_, err := d.SetBreakpoint(0x20A, "V1 == 0x11")
+	assert.Nil(t, err)
+	assert.Equal(t, StopLimit, d.Continue(10).Reason)
+
+	d = New(newTestCPU())
+	b, err := d.SetBreakpoint(0x20A, "V1 == 0x10")
+	assert.Nil(t, err)
+	stop := d.Continue(10)
+	assert.Equal(t, StopBreakpoint, stop.Reason)
+	assert.Equal(t, b, stop.Breakpoint)
+	assert.Equal(t, "breakpoint 0x20A if V1 == 0x10", stop.String())
+
+	_, err = d.SetBreakpoint(0x20A, "V1 = 0x10")
+	assert.NotNil(t, err)
+}
+
+func TestFrameResumesFromBreakpoint(t *testing.T) {
+	cpu := newTestCPU()
+	cpu.InstructionsPerFrame = 2
+	d := New(cpu)
+	_, _ = d.SetBreakpoint(0x200, "")
+	// Frames stop at the breakpoint until resumed, even at the start of a frame
+	assert.Equal(t, StopBreakpoint, d.Frame().Reason)
+	stop := d.Frame()
+	assert.Equal(t, StopFrame, stop.Reason)
+	assert.Equal(t, uint16(0x208), stop.PC)
+}
+
+func TestWatchpoints(t *testing.T) {
+	d := New(newTestCPU())
+	w, err := d.Watch(WatchWrite, 0x301, 1)
+	assert.Nil(t, err)
+	stop := d.Continue(10)
+	assert.Equal(t, StopWatchpoint, stop.Reason)
+	assert.Equal(t, w, stop.Watchpoint)
+	assert.Equal(t, uint16(0x20E), stop.PC)
+	assert.Equal(t, 0x301, stop.Addr)
+	assert.Equal(t, 0x00, stop.Old)
+	assert.Equal(t, 0x10, stop.New)
+	assert.Equal(t, "watchpoint 1: write 0x301, 0x301 changed 0x00 -> 0x10, now at 0x20E", stop.String())
+
+	// The write to 0x300 does not trigger a read watchpoint
+	d = New(newTestCPU())
+	_, _ = d.Watch(WatchRead, 0x2FF, 2)
+	stop = d.Continue(10)
+	assert.Equal(t, StopWatchpoint, stop.Reason)
+	assert.Equal(t, uint16(0x210), stop.PC)
+	assert.Equal(t, 0x300, stop.Addr)
+	assert.Equal(t, 0x03, stop.New)
+
+	d = New(newTestCPU())
+	_, _ = d.Watch(WatchAccess, 0x300, 1)
+	assert.Equal(t, uint16(0x20E), d.Continue(10).PC)
+	assert.Equal(t, uint16(0x210), d.Continue(10).PC)
+
+	d = New(newTestCPU())
+	w, err = d.WatchRegister("v1")
+	assert.Nil(t, err)
+	assert.Equal(t, "V1", w.Register)
+	stop = d.Continue(10)
+	assert.Equal(t, StopWatchpoint, stop.Reason)
+	assert.Equal(t, uint16(0x20A), stop.PC)
+	assert.Equal(t, 0x00, stop.Old)
+	assert.Equal(t, 0x10, stop.New)
+
+	assert.True(t, d.Unwatch(w.ID))
+	assert.False(t, d.Unwatch(w.ID))
+	_, err = d.WatchRegister("VG")
+	assert.NotNil(t, err)
+	_, err = d.Watch(WatchWrite, 0xFFFF, 2)
+	assert.NotNil(t, err)
+}
+
+func TestWatchpointsObserveBus(t *testing.T) {
+	// Writes wrapping around the end of memory hit watchpoints at the addresses written
+	cpu := newTestCPU()
+	cpu.Quirks.AddressBits = 12
+	cpu.Memory.Policy = chip8.AccessWrap
+	copy(cpu.Memory.Memory[0x20A:], []byte{0xAF, 0xFF}) // 0x20A: i := 0xFFF
+	d := New(cpu)
+	_, _ = d.Watch(WatchWrite, 0x000, 1)
+	stop := d.Continue(10)
+	assert.Equal(t, StopWatchpoint, stop.Reason)
+	assert.Equal(t, 0x000, stop.Addr)
+	assert.Equal(t, 0xF0, stop.Old)
+	assert.Equal(t, 0x10, stop.New)
+
+	// The bus of the CPU still carries the accesses, and is restored after every instruction
+	cpu = newTestCPU()
+	var memory chip8.Memory
+	mapper := bus.NewMapper(&cpu.Memory)
+	mapper.Map(0x300, 2, &memory)
+	cpu.Bus = mapper
+	d = New(cpu)
+	_, _ = d.Watch(WatchWrite, 0x301, 1)
+	stop = d.Continue(10)
+	assert.Equal(t, StopWatchpoint, stop.Reason)
+	assert.Equal(t, 0x10, stop.New)
+	assert.Equal(t, byte(0x10), memory.Memory[0x301])
+	assert.Equal(t, byte(0x00), cpu.Memory.Memory[0x301])
+	assert.Equal(t, chip8.Bus(mapper), cpu.Bus)
+}
+
+func TestStepping(t *testing.T) {
+	d := New(newTestCPU())
+	assert.Equal(t, StopStep, d.Step().Reason)
+	assert.Equal(t, uint16(0x202), d.CPU.Register.PC)
+	_, err := d.StepOut()
+	assert.Equal(t, ErrOutermostFrame, err)
+
+	// Step over runs the whole subroutine
+	stop := d.StepOver()
+	assert.Equal(t, StopStep, stop.Reason)
+	assert.Equal(t, uint16(0x204), stop.PC)
+	assert.Equal(t, byte(0x03), d.CPU.Register.V[0])
+	assert.Equal(t, byte(0x10), d.CPU.Register.V[1])
+
+	d = New(newTestCPU())
+	d.Step()
+	d.Step()
+	assert.Equal(t, uint16(0x208), d.CPU.Register.PC)
+	assert.Equal(t, []uint16{0x202}, d.Backtrace())
+	stop, err = d.StepOut()
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(0x204), stop.PC)
+	assert.Empty(t, d.Backtrace())
+
+	// A breakpoint inside the subroutine stops step over
+	d = New(newTestCPU())
+	d.Step()
+	_, _ = d.SetBreakpoint(0x20C, "")
+	assert.Equal(t, StopBreakpoint, d.StepOver().Reason)
+
+	d = New(newTestCPU())
+	stop = d.RunTo(0x20E)
+	assert.Equal(t, StopStep, stop.Reason)
+	assert.Equal(t, uint16(0x20E), stop.PC)
+
+	// Run to cursor gives up on addresses never reached
+	d = New(newTestCPU())
+	d.Limit = 5
+	assert.Equal(t, StopLimit, d.RunTo(0x400).Reason)
+}
+
+func TestCallStack(t *testing.T) {
+	d := New(newTestCPU())
+	d.Symbols = asm.Symbols{Labels: map[string]uint16{"main": 0x200, "store": 0x208}}
+	assert.Empty(t, d.CallStack())
+	d.RunTo(0x20C)
+	assert.Equal(t, []Call{{Addr: 0x202, Return: 0x204, Symbol: "main+0x4"}}, d.CallStack())
+
+	var output strings.Builder
+	assert.Nil(t, d.REPL(strings.NewReader("bt\n"), &output))
+	assert.Equal(t, "(chip8) #0  0x20C  store+0x4\n#1  0x204  main+0x4\n(chip8) \n", output.String())
+}
+
+func TestTimersTickPerFrame(t *testing.T) {
+	cpu := newTestCPU()
+	cpu.InstructionsPerFrame = 3
+	cpu.Register.DT = 5
+	d := New(cpu)
+	d.Step()
+	d.Step()
+	assert.Equal(t, byte(5), cpu.Register.DT)
+	d.Step()
+	assert.Equal(t, byte(4), cpu.Register.DT)
+	assert.Equal(t, StopFrame, d.Frame().Reason)
+	assert.Equal(t, byte(3), cpu.Register.DT)
+
+	// FrameEnd is called after every timer tick, however the frame was run
+	var ends []byte
+	d.FrameEnd = func() { ends = append(ends, cpu.Register.DT) }
+	d.Step()
+	d.Step()
+	d.Step()
+	d.Frame()
+	assert.Equal(t, []byte{2, 1}, ends)
+}
+
+func TestFaultAndExit(t *testing.T) {
+	cpu := chip8.NewCPU()
+	copy(cpu.Memory.Memory[0x200:], []byte{0x00, 0xFD, 0xFF, 0xFF})
+	d := New(&cpu)
+	assert.Equal(t, StopExited, d.Step().Reason)
+	assert.Equal(t, StopExited, d.Continue(1).Reason)
+
+	cpu.Exited = false
+	cpu.Register.PC = 0x202
+	stop := d.Step()
+	assert.Equal(t, StopFault, stop.Reason)
+	assert.True(t, errors.As(stop.Err, &chip8.ErrUnknownOpcode{}))
+	assert.Equal(t, uint16(0x202), stop.PC)
+}
+
+func TestParseCondition(t *testing.T) {
+	cpu := newTestCPU()
+	cpu.Register.V[3] = 0x10
+	cpu.Register.I = 0x300
+	cpu.Memory.Memory[0x300] = 0x07
+	tests := []struct {
+		condition string
+		result    bool
+	}{
+		{"V3 == 0x10", true},
+		{"v3==16", true},
+		{"V3 != 0x10", false},
+		{"I >= 0x300", true},
+		{"I > 0x300", false},
+		{"[0x300] < 8", true},
+		{"[0x300] <= V3", true},
+		{"PC == 0x200", true},
+		{"V3 == VA", false},
+	}
+	for _, test := range tests {
+		c, err := ParseCondition(test.condition)
+		if assert.Nil(t, err, test.condition) {
+			assert.Equal(t, test.result, c.Eval(cpu), test.condition)
+		}
+	}
+	for _, condition := range []string{"", "V3", "V3 = 1", "VG == 1", "[0x10000] == 1", "V3 == 1 == 2"} {
+		_, err := ParseCondition(condition)
+		assert.NotNil(t, err, condition)
+	}
+}
+
+func TestREPL(t *testing.T) {
+	d := New(newTestCPU())
+	input := strings.Join([]string{
+		"b 0x20C if V1 == 0x10",
+		"c",
+		"r",
+		"watch 0x300 2",
+		"s",
+		"",
+		"info",
+		"bt",
+		"finish",
+		"x 0x300 2",
+		"l 0x200 2",
+		"key 5 down",
+		"jump",
+		"q",
+		"s",
+	}, "\n")
+	var output strings.Builder
+	assert.Nil(t, d.REPL(strings.NewReader(input), &output))
+	assert.Equal(t, `(chip8) breakpoint 0x20C if V1 == 0x10
+(chip8) breakpoint 0x20C if V1 == 0x10
+*> 0x20C  F155      save v1
+(chip8) V0=03 V1=10 V2=00 V3=00 V4=00 V5=00 V6=00 V7=00
+V8=00 V9=00 VA=00 VB=00 VC=00 VD=00 VE=00 VF=00
+I=300 PC=20C SP=1 DT=00 ST=00
+(chip8) watchpoint 1: write 0x300-0x301
+(chip8) watchpoint 1: write 0x300-0x301, 0x300 changed 0x00 -> 0x03, now at 0x20E
+ > 0x20E  F065      load v0
+(chip8)  > 0x210  00EE      return
+(chip8) breakpoint 0x20C if V1 == 0x10
+watchpoint 1: write 0x300-0x301
+(chip8) #0  0x210
+#1  0x204
+(chip8)  > 0x204  7001      v0 += 0x01
+(chip8) 0x300  03 10
+(chip8)    0x200  6003      v0 := 0x03
+   0x202  2208      :call 0x208
+(chip8) (chip8) error: unknown command "jump", try help
+(chip8) `, output.String())
+	assert.Equal(t, byte(0x01), d.CPU.KeyState[5])
+}
@@ -0,0 +1,313 @@
+package debugger
+
+import (
+	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/disasm"
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const replHelp = `Commands:
+  break, b <addr> [if <condition>]  Set a breakpoint, like "b 0x2A0 if V3 == 0x10"
+  delete, d <addr>                  Delete the breakpoint at addr
+  watch <addr> [length]             Stop after memory is written
+  rwatch <addr> [length]            Stop after memory is read
+  awatch <addr> [length]            Stop after memory is read or written
+  watch <register>                  Stop after a register changes, like "watch V3"
+  unwatch <id>                      Delete a watchpoint
+  info, i                           List breakpoints and watchpoints
+  step, s [count]                   Execute instructions, stepping into calls
+  next, n                           Execute an instruction, stepping over calls
+  finish, out                       Run until the current subroutine returns
+  until, u <addr>                   Run until PC reaches addr
+  continue, c [frames]              Run until a breakpoint or watchpoint is hit
+  registers, r                      Show the registers
+  x <addr> [length]                 Show memory
+  list, l [addr] [count]            Disassemble, from PC by default
+  backtrace, bt                     Show PC and the return addresses of the calls on the stack
+  key <key> down|up                 Press or release a CHIP-8 key
+  quit, q                           Quit
+An empty line repeats the last command.
+`
+
+// REPL reads commands from r and writes their results to w, until quit or the end of r
+func (d *Debugger) REPL(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	bw := bufio.NewWriter(w)
+	var last string
+	for {
+		_, _ = fmt.Fprint(bw, "(chip8) ")
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if !scanner.Scan() {
+			_, _ = fmt.Fprintln(bw)
+			_ = bw.Flush()
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			line = last
+		}
+		last = line
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" || fields[0] == "q" {
+			return bw.Flush()
+		}
+		if err := d.command(bw, fields); err != nil {
+			_, _ = fmt.Fprintf(bw, "error: %s\n", err)
+		}
+	}
+}
+
+func (d *Debugger) command(w io.Writer, fields []string) error {
+	args := fields[1:]
+	switch fields[0] {
+	case "help", "h":
+		_, _ = io.WriteString(w, replHelp)
+	case "break", "b":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: break <addr> [if <condition>]")
+		}
+		addr, err := parseAddress(args[0])
+		if err != nil {
+			return err
+		}
+		var condition string
+		if len(args) > 1 {
+			if args[1] != "if" || len(args) == 2 {
+				return fmt.Errorf("usage: break <addr> [if <condition>]")
+			}
+			condition = strings.Join(args[2:], " ")
+		}
+		b, err := d.SetBreakpoint(addr, condition)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "breakpoint %s\n", b)
+	case "delete", "d":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: delete <addr>")
+		}
+		addr, err := parseAddress(args[0])
+		if err != nil {
+			return err
+		}
+		if !d.ClearBreakpoint(addr) {
+			return fmt.Errorf("no breakpoint at 0x%03X", addr)
+		}
+	case "watch", "rwatch", "awatch":
+		if len(args) == 0 || len(args) > 2 {
+			return fmt.Errorf("usage: %s <addr> [length]", fields[0])
+		}
+		var watchpoint *Watchpoint
+		var err error
+		if _, ok := registerValue(d.CPU, args[0]); ok && fields[0] == "watch" && len(args) == 1 {
+			watchpoint, err = d.WatchRegister(args[0])
+		} else {
+			var addr uint16
+			addr, err = parseAddress(args[0])
+			if err != nil {
+				return err
+			}
+			length := 1
+			if len(args) == 2 {
+				if length, err = strconv.Atoi(args[1]); err != nil {
+					return fmt.Errorf("invalid length %q", args[1])
+				}
+			}
+			kind := map[string]WatchKind{"watch": WatchWrite, "rwatch": WatchRead, "awatch": WatchAccess}[fields[0]]
+			watchpoint, err = d.Watch(kind, addr, length)
+		}
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "watchpoint %s\n", watchpoint)
+	case "unwatch":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: unwatch <id>")
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil || !d.Unwatch(id) {
+			return fmt.Errorf("no watchpoint %s", args[0])
+		}
+	case "info", "i":
+		for _, b := range d.Breakpoints() {
+			_, _ = fmt.Fprintf(w, "breakpoint %s\n", b)
+		}
+		for _, watchpoint := range d.Watchpoints() {
+			_, _ = fmt.Fprintf(w, "watchpoint %s\n", watchpoint)
+		}
+	case "step", "s":
+		count := 1
+		if len(args) > 0 {
+			var err error
+			if count, err = strconv.Atoi(args[0]); err != nil || count < 1 {
+				return fmt.Errorf("invalid count %q", args[0])
+			}
+		}
+		stop := d.Step()
+		for i := 1; i < count && stop.Reason == StopStep; i++ {
+			stop = d.Step()
+		}
+		d.writeStop(w, stop)
+	case "next", "n":
+		d.writeStop(w, d.StepOver())
+	case "finish", "out":
+		stop, err := d.StepOut()
+		if err != nil {
+			return err
+		}
+		d.writeStop(w, stop)
+	case "until", "u":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: until <addr>")
+		}
+		addr, err := parseAddress(args[0])
+		if err != nil {
+			return err
+		}
+		d.writeStop(w, d.RunTo(addr))
+	case "continue", "c":
+		frames := d.Limit
+		if len(args) > 0 {
+			var err error
+			if frames, err = strconv.Atoi(args[0]); err != nil || frames < 1 {
+				return fmt.Errorf("invalid frame count %q", args[0])
+			}
+		}
+		d.writeStop(w, d.Continue(frames))
+	case "registers", "r":
+		_, _ = io.WriteString(w, d.Registers())
+	case "x":
+		if len(args) == 0 || len(args) > 2 {
+			return fmt.Errorf("usage: x <addr> [length]")
+		}
+		addr, err := parseAddress(args[0])
+		if err != nil {
+			return err
+		}
+		length := 16
+		if len(args) == 2 {
+			if length, err = strconv.Atoi(args[1]); err != nil || length < 1 {
+				return fmt.Errorf("invalid length %q", args[1])
+			}
+		}
+		d.writeMemory(w, int(addr), length)
+	case "list", "l":
+		addr, count := d.CPU.Register.PC, 10
+		var err error
+		if len(args) > 0 {
+			if addr, err = parseAddress(args[0]); err != nil {
+				return err
+			}
+		}
+		if len(args) > 1 {
+			if count, err = strconv.Atoi(args[1]); err != nil || count < 1 {
+				return fmt.Errorf("invalid count %q", args[1])
+			}
+		}
+		for i := 0; i < count && int(addr)+1 < len(d.CPU.Memory.Memory); i++ {
+			line, size := d.Disassemble(addr)
+			_, _ = fmt.Fprintln(w, line)
+			addr += size
+		}
+	case "backtrace", "bt":
+		d.writeFrame(w, 0, d.CPU.Register.PC, d.Symbols.Symbol(d.CPU.Register.PC))
+		for i, call := range d.CallStack() {
+			d.writeFrame(w, i+1, call.Return, call.Symbol)
+		}
+	case "key":
+		if len(args) != 2 || args[1] != "down" && args[1] != "up" {
+			return fmt.Errorf("usage: key <key> down|up")
+		}
+		key, err := strconv.ParseUint(args[0], 16, 8)
+		if err != nil || key > 0xF {
+			return fmt.Errorf("invalid key %q", args[0])
+		}
+		d.CPU.SetKey(byte(key), args[1] == "down")
+	default:
+		return fmt.Errorf("unknown command %q, try help", fields[0])
+	}
+	return nil
+}
+
+// writeStop prints why execution stopped and the next instruction
+func (d *Debugger) writeStop(w io.Writer, stop Stop) {
+	if stop.Reason != StopStep {
+		_, _ = fmt.Fprintln(w, stop)
+	}
+	if int(d.CPU.Register.PC)+1 < len(d.CPU.Memory.Memory) {
+		line, _ := d.Disassemble(d.CPU.Register.PC)
+		_, _ = fmt.Fprintln(w, line)
+	}
+}
+
+// writeFrame writes a line of the backtrace, followed by the symbol of addr when known
+func (d *Debugger) writeFrame(w io.Writer, n int, addr uint16, symbol string) {
+	if symbol == "" {
+		_, _ = fmt.Fprintf(w, "#%d  0x%03X\n", n, addr)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "#%d  0x%03X  %s\n", n, addr, symbol)
+}
+
+func (d *Debugger) writeMemory(w io.Writer, addr, length int) {
+	memory := d.CPU.Memory.Memory[:]
+	for row := addr; row < addr+length && row < len(memory); row += 16 {
+		end := row + 16
+		if end > addr+length {
+			end = addr + length
+		}
+		if end > len(memory) {
+			end = len(memory)
+		}
+		_, _ = fmt.Fprintf(w, "0x%03X  % X\n", row, memory[row:end])
+	}
+}
+
+// Registers returns the registers formatted over three lines
+func (d *Debugger) Registers() string {
+	r := d.CPU.Register
+	var sb strings.Builder
+	for i, v := range r.V {
+		sb.WriteString(fmt.Sprintf("V%X=%02X", i, v))
+		if i%8 == 7 {
+			sb.WriteByte('\n')
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+	sb.WriteString(fmt.Sprintf("I=%03X PC=%03X SP=%X DT=%02X ST=%02X\n", r.I, r.PC, r.SP, r.DT, r.ST))
+	return sb.String()
+}
+
+// Disassemble returns a listing line of the instruction at addr and its size. The line is marked
+// with > when addr is PC and with * when a breakpoint is set at addr.
+func (d *Debugger) Disassemble(addr uint16) (string, uint16) {
+	memory := d.CPU.Memory.Memory[:]
+	instruction := chip8.Decode(uint16(memory[addr])<<8 | uint16(memory[addr+1]))
+	size := instruction.Size()
+	var long uint16
+	if size == 4 && int(addr)+3 < len(memory) {
+		long = uint16(memory[addr+2])<<8 | uint16(memory[addr+3])
+	}
+	marker := []byte("  ")
+	if _, ok := d.breakpoints[addr]; ok {
+		marker[0] = '*'
+	}
+	if addr == d.CPU.Register.PC {
+		marker[1] = '>'
+	}
+	code := fmt.Sprintf("%02X%02X", memory[addr], memory[addr+1])
+	if size == 4 {
+		code += fmt.Sprintf("%04X", long)
+	}
+	return fmt.Sprintf("%s 0x%03X  %-8s  %s", marker, addr, code, disasm.Format(instruction, long, disasm.Octo)), size
+}
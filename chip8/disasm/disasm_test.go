@@ -0,0 +1,112 @@
+package disasm
+
+import (
+	"GoCHIP-8/chip8"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		opcode uint16
+		long   uint16
+		octo   string
+		cowgod string
+	}{
+		{0x00E0, 0, "clear", "CLS"},
+		{0x00C4, 0, "scroll-down 4", "SCD 4"},
+		{0x1234, 0, "jump 0x234", "JP 0x234"},
+		{0x2345, 0, ":call 0x345", "CALL 0x345"},
+		{0x3A12, 0, "if va != 0x12 then", "SE VA, 0x12"},
+		{0x5122, 0, "save v1 - v2", "SAVE V1, V2"},
+		{0x8AB6, 0, "va >>= vb", "SHR VA, VB"},
+		{0xB300, 0, "jump0 0x300", "JP V0, 0x300"},
+		{0xD125, 0, "sprite v1 v2 5", "DRW V1, V2, 5"},
+		{0xE3A1, 0, "if v3 key then", "SKNP V3"},
+		{0xF000, 0x1234, "i := long 0x1234", "LD I, LONG 0x1234"},
+		{0xF201, 0, "plane 2", "PLANE 2"},
+		{0xFF65, 0, "load vf", "LD VF, [I]"},
+		{0x5121, 0, "0x51 0x21", "DW 0x5121"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.octo, Format(chip8.Decode(test.opcode), test.long, Octo))
+		assert.Equal(t, test.cowgod, Format(chip8.Decode(test.opcode), test.long, Cowgod))
+	}
+}
+
+func TestFormatCoversDecoder(t *testing.T) {
+	// Every opcode the CPU can execute must have a mnemonic
+	for opcode := 0; opcode <= 0xFFFF; opcode++ {
+		instruction := chip8.Decode(uint16(opcode))
+		if instruction.Op == chip8.OpUnknown {
+			continue
+		}
+		assert.False(t, strings.HasPrefix(Format(instruction, 0, Octo), "0x"), "%04X", opcode)
+		assert.False(t, strings.HasPrefix(Format(instruction, 0, Cowgod), "DW"), "%04X", opcode)
+	}
+}
+
+func TestParseSyntax(t *testing.T) {
+	syntax, err := ParseSyntax("Cowgod")
+	assert.Nil(t, err)
+	assert.Equal(t, Cowgod, syntax)
+	_, err = ParseSyntax("null")
+	assert.NotNil(t, err)
+}
+
+func TestAnalyze(t *testing.T) {
+	rom := []byte{
+		0xA2, 0x0E, // 0x200: i := data_20E
+		0x22, 0x0A, // 0x202: call sub_20A
+		0x30, 0x00, // 0x204: skip next if v0 == 0
+		0xF0, 0x00, // 0x206: i := long 0x0210
+		0x02, 0x10,
+		0x12, 0x0A, // 0x20A: jump to itself, sub_20A
+		0x00, 0xEE, // 0x20C: unreachable
+		0xFF, 0x81, // 0x20E: sprite data
+		0x00, // 0x210: data
+	}
+	program := Analyze(rom, 0x200)
+	assert.Equal(t, []bool{
+		true, true, true, true, true, true, true, true, true, true, true, true,
+		false, false, false, false, false,
+	}, program.Code)
+	assert.Equal(t, map[uint16]string{
+		0x20A: "sub_20A",
+		0x20E: "data_20E",
+		0x210: "data_210",
+	}, program.Labels)
+
+	var buf bytes.Buffer
+	assert.Nil(t, program.Write(&buf, Octo))
+	assert.Equal(t, `	i := data_20E            # 0x200  A20E
+	sub_20A                  # 0x202  220A
+	if v0 != 0x00 then       # 0x204  3000
+	i := long data_210       # 0x206  F0000210
+: sub_20A
+	jump sub_20A             # 0x20A  120A
+	0x00                     # 0x20C  ........
+	0xEE                     # 0x20D  ###.###.
+: data_20E
+	0xFF                     # 0x20E  ########
+	0x81                     # 0x20F  #......#
+: data_210
+	0x00                     # 0x210  ........
+`, buf.String())
+
+	buf.Reset()
+	assert.Nil(t, program.Write(&buf, Cowgod))
+	assert.Contains(t, buf.String(), "sub_20A:\n\tJP sub_20A")
+	assert.Contains(t, buf.String(), "\tDB 0xFF                  ; 0x20E  ########\n")
+}
+
+func TestAnalyzeLabelInsideInstruction(t *testing.T) {
+	// I points into the middle of the jump, which has no line of its own to label
+	program := Analyze([]byte{0xA2, 0x03, 0x12, 0x02}, 0x200)
+	assert.Equal(t, map[uint16]string{0x202: "label_202"}, program.Labels)
+	var buf bytes.Buffer
+	assert.Nil(t, program.Write(&buf, Octo))
+	assert.Contains(t, buf.String(), "i := 0x203")
+}
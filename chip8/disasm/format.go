@@ -0,0 +1,266 @@
+// Package disasm decodes CHIP-8, SUPER-CHIP and XO-CHIP programs into assembly.
+// Opcodes are decoded with chip8.Decode, the same decoder CPU.Cycle uses.
+package disasm
+
+import (
+	"GoCHIP-8/chip8"
+	"fmt"
+	"strings"
+)
+
+// Syntax selects the assembly dialect of the output
+type Syntax int
+
+const (
+	// Octo is the syntax of the Octo assembler, like "v0 := 0x05"
+	Octo Syntax = iota
+	// Cowgod is the classic syntax of Cowgod's Chip-8 technical reference, like "LD V0, 0x05"
+	Cowgod
+)
+
+// ParseSyntax returns the syntax with the given name: octo or cowgod
+func ParseSyntax(name string) (Syntax, error) {
+	switch strings.ToLower(name) {
+	case "octo":
+		return Octo, nil
+	case "cowgod":
+		return Cowgod, nil
+	}
+	return Octo, fmt.Errorf("unknown syntax: %s", name)
+}
+
+// Format returns the mnemonic of an instruction, long is the address following F000
+func Format(instruction chip8.Instruction, long uint16, syntax Syntax) string {
+	return format(instruction, long, syntax, nil)
+}
+
+func format(instruction chip8.Instruction, long uint16, syntax Syntax, labels map[uint16]string) string {
+	address := func(addr uint16) string {
+		if label, ok := labels[addr]; ok {
+			return label
+		}
+		return fmt.Sprintf("0x%03X", addr)
+	}
+	if syntax == Cowgod {
+		return formatCowgod(instruction, long, address)
+	}
+	return formatOcto(instruction, long, address, labels)
+}
+
+func formatOcto(instruction chip8.Instruction, long uint16, address func(uint16) string, labels map[uint16]string) string {
+	x, y := instruction.X, instruction.Y
+	switch instruction.Op {
+	case chip8.Op00CN:
+		return fmt.Sprintf("scroll-down %d", instruction.N)
+	case chip8.Op00DN:
+		return fmt.Sprintf("scroll-up %d", instruction.N)
+	case chip8.Op00E0:
+		return "clear"
+	case chip8.Op00EE:
+		return "return"
+	case chip8.Op00FB:
+		return "scroll-right"
+	case chip8.Op00FC:
+		return "scroll-left"
+	case chip8.Op00FD:
+		return "exit"
+	case chip8.Op00FE:
+		return "lores"
+	case chip8.Op00FF:
+		return "hires"
+	case chip8.Op1NNN:
+		return "jump " + address(instruction.NNN)
+	case chip8.Op2NNN:
+		// Octo calls a subroutine by its bare label name
+		if label, ok := labels[instruction.NNN]; ok {
+			return label
+		}
+		return fmt.Sprintf(":call 0x%03X", instruction.NNN)
+	case chip8.Op3XNN:
+		return fmt.Sprintf("if v%x != 0x%02X then", x, instruction.NN)
+	case chip8.Op4XNN:
+		return fmt.Sprintf("if v%x == 0x%02X then", x, instruction.NN)
+	case chip8.Op5XY0:
+		return fmt.Sprintf("if v%x != v%x then", x, y)
+	case chip8.Op5XY2:
+		return fmt.Sprintf("save v%x - v%x", x, y)
+	case chip8.Op5XY3:
+		return fmt.Sprintf("load v%x - v%x", x, y)
+	case chip8.Op6XNN:
+		return fmt.Sprintf("v%x := 0x%02X", x, instruction.NN)
+	case chip8.Op7XNN:
+		return fmt.Sprintf("v%x += 0x%02X", x, instruction.NN)
+	case chip8.Op8XY0:
+		return fmt.Sprintf("v%x := v%x", x, y)
+	case chip8.Op8XY1:
+		return fmt.Sprintf("v%x |= v%x", x, y)
+	case chip8.Op8XY2:
+		return fmt.Sprintf("v%x &= v%x", x, y)
+	case chip8.Op8XY3:
+		return fmt.Sprintf("v%x ^= v%x", x, y)
+	case chip8.Op8XY4:
+		return fmt.Sprintf("v%x += v%x", x, y)
+	case chip8.Op8XY5:
+		return fmt.Sprintf("v%x -= v%x", x, y)
+	case chip8.Op8XY6:
+		return fmt.Sprintf("v%x >>= v%x", x, y)
+	case chip8.Op8XY7:
+		return fmt.Sprintf("v%x =- v%x", x, y)
+	case chip8.Op8XYE:
+		return fmt.Sprintf("v%x <<= v%x", x, y)
+	case chip8.Op9XY0:
+		return fmt.Sprintf("if v%x == v%x then", x, y)
+	case chip8.OpANNN:
+		return "i := " + address(instruction.NNN)
+	case chip8.OpBNNN:
+		return "jump0 " + address(instruction.NNN)
+	case chip8.OpCXNN:
+		return fmt.Sprintf("v%x := random 0x%02X", x, instruction.NN)
+	case chip8.OpDXYN:
+		return fmt.Sprintf("sprite v%x v%x %d", x, y, instruction.N)
+	case chip8.OpEX9E:
+		return fmt.Sprintf("if v%x -key then", x)
+	case chip8.OpEXA1:
+		return fmt.Sprintf("if v%x key then", x)
+	case chip8.OpF000:
+		return "i := long " + address(long)
+	case chip8.OpFN01:
+		return fmt.Sprintf("plane %d", x)
+	case chip8.OpF002:
+		return "audio"
+	case chip8.OpFX07:
+		return fmt.Sprintf("v%x := delay", x)
+	case chip8.OpFX0A:
+		return fmt.Sprintf("v%x := key", x)
+	case chip8.OpFX15:
+		return fmt.Sprintf("delay := v%x", x)
+	case chip8.OpFX18:
+		return fmt.Sprintf("buzzer := v%x", x)
+	case chip8.OpFX1E:
+		return fmt.Sprintf("i += v%x", x)
+	case chip8.OpFX29:
+		return fmt.Sprintf("i := hex v%x", x)
+	case chip8.OpFX30:
+		return fmt.Sprintf("i := bighex v%x", x)
+	case chip8.OpFX33:
+		return fmt.Sprintf("bcd v%x", x)
+	case chip8.OpFX3A:
+		return fmt.Sprintf("pitch := v%x", x)
+	case chip8.OpFX55:
+		return fmt.Sprintf("save v%x", x)
+	case chip8.OpFX65:
+		return fmt.Sprintf("load v%x", x)
+	case chip8.OpFX75:
+		return fmt.Sprintf("saveflags v%x", x)
+	case chip8.OpFX85:
+		return fmt.Sprintf("loadflags v%x", x)
+	}
+	return fmt.Sprintf("0x%02X 0x%02X", instruction.Opcode>>8, instruction.Opcode&0xFF)
+}
+
+func formatCowgod(instruction chip8.Instruction, long uint16, address func(uint16) string) string {
+	x, y := instruction.X, instruction.Y
+	switch instruction.Op {
+	case chip8.Op00CN:
+		return fmt.Sprintf("SCD %d", instruction.N)
+	case chip8.Op00DN:
+		return fmt.Sprintf("SCU %d", instruction.N)
+	case chip8.Op00E0:
+		return "CLS"
+	case chip8.Op00EE:
+		return "RET"
+	case chip8.Op00FB:
+		return "SCR"
+	case chip8.Op00FC:
+		return "SCL"
+	case chip8.Op00FD:
+		return "EXIT"
+	case chip8.Op00FE:
+		return "LOW"
+	case chip8.Op00FF:
+		return "HIGH"
+	case chip8.Op1NNN:
+		return "JP " + address(instruction.NNN)
+	case chip8.Op2NNN:
+		return "CALL " + address(instruction.NNN)
+	case chip8.Op3XNN:
+		return fmt.Sprintf("SE V%X, 0x%02X", x, instruction.NN)
+	case chip8.Op4XNN:
+		return fmt.Sprintf("SNE V%X, 0x%02X", x, instruction.NN)
+	case chip8.Op5XY0:
+		return fmt.Sprintf("SE V%X, V%X", x, y)
+	case chip8.Op5XY2:
+		return fmt.Sprintf("SAVE V%X, V%X", x, y)
+	case chip8.Op5XY3:
+		return fmt.Sprintf("LOAD V%X, V%X", x, y)
+	case chip8.Op6XNN:
+		return fmt.Sprintf("LD V%X, 0x%02X", x, instruction.NN)
+	case chip8.Op7XNN:
+		return fmt.Sprintf("ADD V%X, 0x%02X", x, instruction.NN)
+	case chip8.Op8XY0:
+		return fmt.Sprintf("LD V%X, V%X", x, y)
+	case chip8.Op8XY1:
+		return fmt.Sprintf("OR V%X, V%X", x, y)
+	case chip8.Op8XY2:
+		return fmt.Sprintf("AND V%X, V%X", x, y)
+	case chip8.Op8XY3:
+		return fmt.Sprintf("XOR V%X, V%X", x, y)
+	case chip8.Op8XY4:
+		return fmt.Sprintf("ADD V%X, V%X", x, y)
+	case chip8.Op8XY5:
+		return fmt.Sprintf("SUB V%X, V%X", x, y)
+	case chip8.Op8XY6:
+		return fmt.Sprintf("SHR V%X, V%X", x, y)
+	case chip8.Op8XY7:
+		return fmt.Sprintf("SUBN V%X, V%X", x, y)
+	case chip8.Op8XYE:
+		return fmt.Sprintf("SHL V%X, V%X", x, y)
+	case chip8.Op9XY0:
+		return fmt.Sprintf("SNE V%X, V%X", x, y)
+	case chip8.OpANNN:
+		return "LD I, " + address(instruction.NNN)
+	case chip8.OpBNNN:
+		return "JP V0, " + address(instruction.NNN)
+	case chip8.OpCXNN:
+		return fmt.Sprintf("RND V%X, 0x%02X", x, instruction.NN)
+	case chip8.OpDXYN:
+		return fmt.Sprintf("DRW V%X, V%X, %d", x, y, instruction.N)
+	case chip8.OpEX9E:
+		return fmt.Sprintf("SKP V%X", x)
+	case chip8.OpEXA1:
+		return fmt.Sprintf("SKNP V%X", x)
+	case chip8.OpF000:
+		return "LD I, LONG " + address(long)
+	case chip8.OpFN01:
+		return fmt.Sprintf("PLANE %d", x)
+	case chip8.OpF002:
+		return "AUDIO"
+	case chip8.OpFX07:
+		return fmt.Sprintf("LD V%X, DT", x)
+	case chip8.OpFX0A:
+		return fmt.Sprintf("LD V%X, K", x)
+	case chip8.OpFX15:
+		return fmt.Sprintf("LD DT, V%X", x)
+	case chip8.OpFX18:
+		return fmt.Sprintf("LD ST, V%X", x)
+	case chip8.OpFX1E:
+		return fmt.Sprintf("ADD I, V%X", x)
+	case chip8.OpFX29:
+		return fmt.Sprintf("LD F, V%X", x)
+	case chip8.OpFX30:
+		return fmt.Sprintf("LD HF, V%X", x)
+	case chip8.OpFX33:
+		return fmt.Sprintf("LD B, V%X", x)
+	case chip8.OpFX3A:
+		return fmt.Sprintf("LD PITCH, V%X", x)
+	case chip8.OpFX55:
+		return fmt.Sprintf("LD [I], V%X", x)
+	case chip8.OpFX65:
+		return fmt.Sprintf("LD V%X, [I]", x)
+	case chip8.OpFX75:
+		return fmt.Sprintf("LD R, V%X", x)
+	case chip8.OpFX85:
+		return fmt.Sprintf("LD V%X, R", x)
+	}
+	return fmt.Sprintf("DW 0x%04X", instruction.Opcode)
+}
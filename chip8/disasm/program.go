@@ -0,0 +1,178 @@
+package disasm
+
+import (
+	"GoCHIP-8/chip8"
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Program is a ROM split into code and data by recursive descent from its entry point
+type Program struct {
+	Origin uint16
+	ROM    []byte
+	// Code reports for each byte of the ROM whether it belongs to a reachable instruction
+	Code []bool
+	// Labels of jump targets, subroutines and data addressed by I
+	Labels map[uint16]string
+}
+
+// Analyze follows the control flow of a ROM loaded at origin, starting at origin.
+// Jumps, calls and skips are followed, while return, exit and the computed jump BNNN end a path.
+func Analyze(rom []byte, origin uint16) *Program {
+	program := &Program{
+		Origin: origin,
+		ROM:    rom,
+		Code:   make([]bool, len(rom)),
+		Labels: make(map[uint16]string),
+	}
+	var dataRefs []uint16
+	queue := []uint16{origin}
+	for len(queue) > 0 {
+		addr := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		for {
+			instruction, long, ok := program.decode(addr)
+			if !ok || program.Code[addr-origin] {
+				break
+			}
+			size := instruction.Size()
+			for i := uint16(0); i < size; i++ {
+				program.Code[addr-origin+i] = true
+			}
+			next := addr + size
+			stop := false
+			switch instruction.Op {
+			case chip8.Op1NNN:
+				program.label(instruction.NNN, "label")
+				queue = append(queue, instruction.NNN)
+				stop = true
+			case chip8.Op2NNN:
+				program.Labels[instruction.NNN] = fmt.Sprintf("sub_%03X", instruction.NNN)
+				queue = append(queue, instruction.NNN)
+			case chip8.Op3XNN, chip8.Op4XNN, chip8.Op5XY0, chip8.Op9XY0, chip8.OpEX9E, chip8.OpEXA1:
+				// The skipped instruction may be the 4-byte F000 NNNN
+				if skipped, _, ok := program.decode(next); ok {
+					queue = append(queue, next+skipped.Size())
+				} else {
+					queue = append(queue, next+2)
+				}
+			case chip8.Op00EE, chip8.Op00FD, chip8.OpBNNN:
+				stop = true
+			case chip8.OpANNN:
+				dataRefs = append(dataRefs, instruction.NNN)
+			case chip8.OpF000:
+				dataRefs = append(dataRefs, long)
+			}
+			if stop {
+				break
+			}
+			addr = next
+		}
+	}
+	for _, addr := range dataRefs {
+		if program.contains(addr) {
+			program.label(addr, "data")
+		}
+	}
+	// A label can only be written in front of a line, addresses inside an instruction stay numeric
+	for addr := range program.Labels {
+		if !program.lineStart(addr) {
+			delete(program.Labels, addr)
+		}
+	}
+	return program
+}
+
+// lineStart reports whether addr is the start of an instruction or a data byte in the listing
+func (program *Program) lineStart(addr uint16) bool {
+	for offset := 0; offset < len(program.ROM); {
+		at := program.Origin + uint16(offset)
+		if at >= addr {
+			return at == addr
+		}
+		if program.Code[offset] {
+			instruction, _, _ := program.decode(at)
+			offset += int(instruction.Size())
+			continue
+		}
+		offset++
+	}
+	return false
+}
+
+// label names addr unless it already has a label
+func (program *Program) label(addr uint16, prefix string) {
+	if _, ok := program.Labels[addr]; !ok {
+		program.Labels[addr] = fmt.Sprintf("%s_%03X", prefix, addr)
+	}
+}
+
+func (program *Program) contains(addr uint16) bool {
+	return addr >= program.Origin && int(addr-program.Origin) < len(program.ROM)
+}
+
+// decode returns the instruction at addr, ok is false when it is outside of the ROM or unknown
+func (program *Program) decode(addr uint16) (instruction chip8.Instruction, long uint16, ok bool) {
+	if !program.contains(addr) || !program.contains(addr+1) {
+		return instruction, 0, false
+	}
+	offset := addr - program.Origin
+	instruction = chip8.Decode(uint16(program.ROM[offset])<<8 | uint16(program.ROM[offset+1]))
+	if instruction.Op == chip8.OpUnknown {
+		return instruction, 0, false
+	}
+	if instruction.Size() == 4 {
+		if !program.contains(addr + 3) {
+			return instruction, 0, false
+		}
+		long = uint16(program.ROM[offset+2])<<8 | uint16(program.ROM[offset+3])
+	}
+	return instruction, long, true
+}
+
+// Write prints the program listing, code as instructions and data as bytes with their bits drawn as sprite art
+func (program *Program) Write(w io.Writer, syntax Syntax) error {
+	comment := "#"
+	labelFormat := ": %s\n"
+	dataFormat := "\t0x%02X"
+	if syntax == Cowgod {
+		comment = ";"
+		labelFormat = "%s:\n"
+		dataFormat = "\tDB 0x%02X"
+	}
+	bw := bufio.NewWriter(w)
+	for offset := 0; offset < len(program.ROM); {
+		addr := program.Origin + uint16(offset)
+		if label, ok := program.Labels[addr]; ok {
+			_, _ = fmt.Fprintf(bw, labelFormat, label)
+		}
+		if program.Code[offset] {
+			instruction, long, _ := program.decode(addr)
+			size := int(instruction.Size())
+			text := format(instruction, long, syntax, program.Labels)
+			_, _ = fmt.Fprintf(bw, "\t%-24s %s 0x%03X  %X\n", text, comment, addr, program.ROM[offset:offset+size])
+			offset += size
+			continue
+		}
+		value := program.ROM[offset]
+		line := fmt.Sprintf(dataFormat, value)
+		_, _ = fmt.Fprintf(bw, "%-25s %s 0x%03X  %s\n", line, comment, addr, spriteArt(value))
+		offset++
+	}
+	return bw.Flush()
+}
+
+// spriteArt draws the bits of a sprite row, # for set and . for clear
+func spriteArt(value byte) string {
+	var sb strings.Builder
+	for bit := 7; bit >= 0; bit-- {
+		if value>>uint(bit)&0x01 == 0x01 {
+			sb.WriteByte('#')
+		} else {
+			sb.WriteByte('.')
+		}
+	}
+	return sb.String()
+}
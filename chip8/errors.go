@@ -0,0 +1,73 @@
+package chip8
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownOpcode is returned when the CPU fetches an opcode it cannot decode
+type ErrUnknownOpcode struct {
+	PC     uint16
+	Opcode uint16
+}
+
+func (e ErrUnknownOpcode) Error() string {
+	return fmt.Sprintf("unknown opcode %04X at %03X", e.Opcode, e.PC)
+}
+
+// ErrStackOverflow is returned when 2NNN is executed with a full stack
+type ErrStackOverflow struct {
+	PC uint16
+	// Calls are the addresses of the 2NNN instructions on the stack, outermost first
+	Calls []uint16
+}
+
+func (e ErrStackOverflow) Error() string {
+	return fmt.Sprintf("stack overflow at %03X after %d nested calls: %s", e.PC, len(e.Calls), callChain(e.Calls))
+}
+
+// callChain formats calls like "202 > 2A0 > 2B4 x13", repeated recursive calls are counted
+func callChain(calls []uint16) string {
+	var parts []string
+	for i := 0; i < len(calls); {
+		n := 1
+		for i+n < len(calls) && calls[i+n] == calls[i] {
+			n++
+		}
+		if n > 1 {
+			parts = append(parts, fmt.Sprintf("%03X x%d", calls[i], n))
+		} else {
+			parts = append(parts, fmt.Sprintf("%03X", calls[i]))
+		}
+		i += n
+	}
+	return strings.Join(parts, " > ")
+}
+
+// ErrStackUnderflow is returned when 00EE is executed with an empty stack
+type ErrStackUnderflow struct {
+	PC uint16
+}
+
+func (e ErrStackUnderflow) Error() string {
+	return fmt.Sprintf("stack underflow at %03X, return outside of a subroutine", e.PC)
+}
+
+// ErrMemoryOutOfBounds is returned when an instruction accesses an address outside of memory
+type ErrMemoryOutOfBounds struct {
+	Addr int
+}
+
+func (e ErrMemoryOutOfBounds) Error() string {
+	return fmt.Sprintf("memory access out of bounds at %X", e.Addr)
+}
+
+// ErrWriteProtected is returned when an instruction writes to memory protected by Memory.Protect
+type ErrWriteProtected struct {
+	PC   uint16
+	Addr int
+}
+
+func (e ErrWriteProtected) Error() string {
+	return fmt.Sprintf("write to protected memory at %X by the instruction at %03X", e.Addr, e.PC)
+}
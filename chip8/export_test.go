@@ -0,0 +1,6 @@
+package chip8
+
+// DropCache forgets the instructions cached by RunFrames, so the CPU equals one that only ran Run
+func DropCache(cpu *CPU) {
+	cpu.cache = nil
+}
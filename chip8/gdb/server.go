@@ -0,0 +1,498 @@
+// Package gdb serves the GDB remote serial protocol, so gdb or any other RSP client can debug a chip8.CPU.
+//
+// The registers are V0-VF, I, PC, SP, DT and ST in this order, described by a target description
+// the client reads with qXfer:features:read. Values are big-endian like CHIP-8 words in memory.
+// Software breakpoints (Z0), write, read and access watchpoints (Z2, Z3, Z4), memory reads and writes
+// (m, M), single-step (s), continue (c) and interrupts are supported.
+package gdb
+
+import (
+	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/debugger"
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signals reported in stop replies
+const (
+	sigint  = 2
+	sigill  = 4
+	sigtrap = 5
+	sigsegv = 11
+)
+
+// Server debugs a CPU for one client at a time
+type Server struct {
+	Debugger *debugger.Debugger
+	// Logger logs connections and faults, nil to disable logging
+	Logger *log.Logger
+	// FrameDuration paces continue at the speed of the CPU, 0 runs as fast as possible
+	FrameDuration time.Duration
+}
+
+// NewServer returns a server debugging cpu, continued execution runs at 60 frames per second
+func NewServer(cpu *chip8.CPU) *Server {
+	d := debugger.New(cpu)
+	// A client interrupts execution when it wants to stop, the debugger should not
+	d.Limit = 0
+	return &Server{
+		Debugger:      d,
+		FrameDuration: time.Second / chip8.TimerFrequency,
+	}
+}
+
+// ListenAndServe listens on a TCP address like ":1234" and serves clients one after another
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	s.logf("Listening on %s", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		s.logf("Client connected from %s", conn.RemoteAddr())
+		err = s.Serve(conn)
+		_ = conn.Close()
+		if err != nil {
+			s.logf("Client error: %s", err)
+		} else {
+			s.logf("Client disconnected")
+		}
+	}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, args...)
+	}
+}
+
+// event is a packet or an interrupt read from the client
+type event struct {
+	data      string
+	interrupt bool
+	// Set when the client requests the last packet again
+	nack bool
+	// Set when the checksum of the packet is wrong
+	corrupt bool
+}
+
+// session is the state of a client connection
+type session struct {
+	*Server
+	w      io.Writer
+	events chan event
+	// Closed when the session ends, to stop the reader
+	quit  chan struct{}
+	noAck bool
+	last  string
+	// Error that ended the reader
+	readErr error
+}
+
+// Serve speaks the protocol over conn until the client detaches, kills or disconnects
+func (s *Server) Serve(conn io.ReadWriter) error {
+	sess := &session{Server: s, w: conn, events: make(chan event, 16), quit: make(chan struct{})}
+	defer close(sess.quit)
+	go sess.read(bufio.NewReader(conn))
+	for e := range sess.events {
+		if e.interrupt {
+			// Execution is already stopped
+			continue
+		}
+		if err := sess.ack(e); err != nil {
+			return err
+		}
+		if e.corrupt || e.nack {
+			continue
+		}
+		done, err := sess.handle(e.data)
+		if err != nil || done {
+			return err
+		}
+	}
+	if sess.readErr == io.EOF {
+		return nil
+	}
+	return sess.readErr
+}
+
+// read parses the bytes of the client into events until the connection fails
+func (sess *session) read(r *bufio.Reader) {
+	defer close(sess.events)
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			sess.readErr = err
+			return
+		}
+		var e event
+		switch c {
+		case 0x03:
+			e = event{interrupt: true}
+		case '-':
+			e = event{nack: true}
+		case '$':
+			data, err := r.ReadString('#')
+			if err != nil {
+				sess.readErr = err
+				return
+			}
+			data = data[:len(data)-1]
+			var sum [2]byte
+			if _, err := io.ReadFull(r, sum[:]); err != nil {
+				sess.readErr = err
+				return
+			}
+			expected, err := strconv.ParseUint(string(sum[:]), 16, 8)
+			e = event{data: data, corrupt: err != nil || byte(expected) != checksum(data)}
+		default:
+			// Acknowledgements are not needed as packets are only sent again on request
+			continue
+		}
+		select {
+		case sess.events <- e:
+		case <-sess.quit:
+			return
+		}
+	}
+}
+
+func checksum(data string) byte {
+	var sum byte
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	return sum
+}
+
+// ack acknowledges a packet, or sends the last packet again when the client requests it with -
+func (sess *session) ack(e event) error {
+	if e.nack {
+		if sess.last != "" {
+			_, err := io.WriteString(sess.w, sess.last)
+			return err
+		}
+		return nil
+	}
+	if sess.noAck {
+		return nil
+	}
+	reply := "+"
+	if e.corrupt {
+		reply = "-"
+	}
+	_, err := io.WriteString(sess.w, reply)
+	return err
+}
+
+func (sess *session) send(data string) error {
+	var sb strings.Builder
+	sb.WriteByte('$')
+	for i := 0; i < len(data); i++ {
+		// Escape the characters with a meaning in packets
+		switch c := data[i]; c {
+		case '#', '$', '}', '*':
+			sb.WriteByte('}')
+			sb.WriteByte(c ^ 0x20)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	escaped := sb.String()[1:]
+	sb.WriteString(fmt.Sprintf("#%02x", checksum(escaped)))
+	sess.last = sb.String()
+	_, err := io.WriteString(sess.w, sess.last)
+	return err
+}
+
+// handle answers a packet, done is set when the session ends
+func (sess *session) handle(data string) (done bool, err error) {
+	cpu := sess.Debugger.CPU
+	switch {
+	case strings.HasPrefix(data, "qSupported"):
+		return false, sess.send("PacketSize=1000;qXfer:features:read+;QStartNoAckMode+;swbreak+")
+	case data == "QStartNoAckMode":
+		err := sess.send("OK")
+		sess.noAck = true
+		return false, err
+	case strings.HasPrefix(data, "qXfer:features:read:target.xml:"):
+		return false, sess.send(xfer(targetXML, strings.TrimPrefix(data, "qXfer:features:read:target.xml:")))
+	case data == "qAttached":
+		return false, sess.send("1")
+	case data == "qC":
+		return false, sess.send("QC1")
+	case data == "qfThreadInfo":
+		return false, sess.send("m1")
+	case data == "qsThreadInfo":
+		return false, sess.send("l")
+	case strings.HasPrefix(data, "H"):
+		return false, sess.send("OK")
+	case data == "?":
+		return false, sess.send(fmt.Sprintf("S%02x", sigtrap))
+	case data == "g":
+		var sb strings.Builder
+		for n := range registers {
+			sb.WriteString(encodeRegister(cpu, n))
+		}
+		return false, sess.send(sb.String())
+	case strings.HasPrefix(data, "G"):
+		return false, sess.send(writeRegisters(cpu, data[1:]))
+	case strings.HasPrefix(data, "p"):
+		n, err := strconv.ParseUint(data[1:], 16, 8)
+		if err != nil || int(n) >= len(registers) {
+			return false, sess.send("E00")
+		}
+		return false, sess.send(encodeRegister(cpu, int(n)))
+	case strings.HasPrefix(data, "P"):
+		return false, sess.send(writeOneRegister(cpu, data[1:]))
+	case strings.HasPrefix(data, "m"):
+		return false, sess.send(readMemory(cpu, data[1:]))
+	case strings.HasPrefix(data, "M"):
+		return false, sess.send(writeMemory(cpu, data[1:]))
+	case strings.HasPrefix(data, "Z"), strings.HasPrefix(data, "z"):
+		return false, sess.send(sess.setPoint(data))
+	case strings.HasPrefix(data, "s"), strings.HasPrefix(data, "c"):
+		if len(data) > 1 {
+			addr, err := strconv.ParseUint(data[1:], 16, 16)
+			if err != nil {
+				return false, sess.send("E00")
+			}
+			cpu.Register.PC = uint16(addr)
+		}
+		if data[0] == 's' {
+			return false, sess.send(stopReply(sess.Debugger.Step()))
+		}
+		reply, err := sess.resume()
+		if err != nil {
+			return false, err
+		}
+		return false, sess.send(reply)
+	case data == "D":
+		return true, sess.send("OK")
+	case data == "k":
+		return true, nil
+	}
+	// Empty replies tell the client the packet is not supported
+	return false, sess.send("")
+}
+
+// resume continues execution until it stops or the client interrupts it
+func (sess *session) resume() (string, error) {
+	var tick <-chan time.Time
+	if sess.FrameDuration > 0 {
+		ticker := time.NewTicker(sess.FrameDuration)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		stop := sess.Debugger.Continue(1)
+		if stop.Reason != debugger.StopLimit {
+			if stop.Reason == debugger.StopFault {
+				sess.logf("CPU fault: %s", stop.Err)
+			}
+			return stopReply(stop), nil
+		}
+		var e event
+		ok := true
+		if tick == nil {
+			select {
+			case e, ok = <-sess.events:
+			default:
+				continue
+			}
+		} else {
+			select {
+			case e, ok = <-sess.events:
+			case <-tick:
+				continue
+			}
+		}
+		if !ok {
+			return "", fmt.Errorf("connection lost while running: %v", sess.readErr)
+		}
+		// Other packets are not expected while running
+		if e.interrupt {
+			return fmt.Sprintf("S%02x", sigint), nil
+		}
+	}
+}
+
+func stopReply(stop debugger.Stop) string {
+	switch stop.Reason {
+	case debugger.StopBreakpoint:
+		return fmt.Sprintf("T%02xswbreak:;", sigtrap)
+	case debugger.StopWatchpoint:
+		kind := map[debugger.WatchKind]string{
+			debugger.WatchWrite:  "watch",
+			debugger.WatchRead:   "rwatch",
+			debugger.WatchAccess: "awatch",
+		}[stop.Watchpoint.Kind]
+		return fmt.Sprintf("T%02x%s:%x;", sigtrap, kind, stop.Addr)
+	case debugger.StopExited:
+		return "W00"
+	case debugger.StopFault:
+		var unknown chip8.ErrUnknownOpcode
+		if errors.As(stop.Err, &unknown) {
+			return fmt.Sprintf("S%02x", sigill)
+		}
+		return fmt.Sprintf("S%02x", sigsegv)
+	}
+	return fmt.Sprintf("S%02x", sigtrap)
+}
+
+// setPoint handles Z and z packets, like Z0,2a0,2
+func (sess *session) setPoint(data string) string {
+	fields := strings.Split(data[1:], ",")
+	if len(fields) < 3 {
+		return "E00"
+	}
+	addr, err := strconv.ParseUint(fields[1], 16, 16)
+	if err != nil {
+		return "E00"
+	}
+	length, err := strconv.ParseUint(fields[2], 16, 16)
+	if err != nil {
+		return "E00"
+	}
+	d := sess.Debugger
+	insert := data[0] == 'Z'
+	switch fields[0] {
+	case "0", "1":
+		if insert {
+			_, _ = d.SetBreakpoint(uint16(addr), "")
+		} else {
+			d.ClearBreakpoint(uint16(addr))
+		}
+		return "OK"
+	case "2", "3", "4":
+		kind := map[string]debugger.WatchKind{
+			"2": debugger.WatchWrite,
+			"3": debugger.WatchRead,
+			"4": debugger.WatchAccess,
+		}[fields[0]]
+		if insert {
+			if _, err := d.Watch(kind, uint16(addr), int(length)); err != nil {
+				return "E01"
+			}
+			return "OK"
+		}
+		for _, w := range d.Watchpoints() {
+			if w.Kind == kind && w.Addr == uint16(addr) && w.Length == int(length) {
+				d.Unwatch(w.ID)
+				break
+			}
+		}
+		return "OK"
+	}
+	return ""
+}
+
+// xfer returns the part of an object requested by "offset,length"
+func xfer(object, request string) string {
+	fields := strings.Split(request, ",")
+	if len(fields) != 2 {
+		return "E00"
+	}
+	offset, err1 := strconv.ParseUint(fields[0], 16, 32)
+	length, err2 := strconv.ParseUint(fields[1], 16, 32)
+	if err1 != nil || err2 != nil {
+		return "E00"
+	}
+	if int(offset) >= len(object) {
+		return "l"
+	}
+	end := int(offset + length)
+	if end >= len(object) {
+		return "l" + object[offset:]
+	}
+	return "m" + object[offset:end]
+}
+
+// parseRange parses "addr,length" of m and M packets
+func parseRange(text string) (addr, length int, ok bool) {
+	fields := strings.Split(text, ",")
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	a, err1 := strconv.ParseUint(fields[0], 16, 32)
+	l, err2 := strconv.ParseUint(fields[1], 16, 32)
+	return int(a), int(l), err1 == nil && err2 == nil
+}
+
+func readMemory(cpu *chip8.CPU, request string) string {
+	addr, length, ok := parseRange(request)
+	memory := cpu.Memory.Memory[:]
+	if !ok || addr >= len(memory) {
+		return "E01"
+	}
+	// Reads past the end of memory are truncated
+	if addr+length > len(memory) {
+		length = len(memory) - addr
+	}
+	return hex.EncodeToString(memory[addr : addr+length])
+}
+
+func writeMemory(cpu *chip8.CPU, request string) string {
+	parts := strings.SplitN(request, ":", 2)
+	if len(parts) != 2 {
+		return "E00"
+	}
+	addr, length, ok := parseRange(parts[0])
+	data, err := hex.DecodeString(parts[1])
+	if !ok || err != nil || len(data) != length {
+		return "E00"
+	}
+	if addr+length > len(cpu.Memory.Memory) {
+		return "E01"
+	}
+	copy(cpu.Memory.Memory[addr:], data)
+	cpu.InvalidateCache()
+	return "OK"
+}
+
+func writeRegisters(cpu *chip8.CPU, values string) string {
+	for n, r := range registers {
+		digits := r.bitsize / 4
+		if len(values) < digits {
+			return "E00"
+		}
+		value, err := strconv.ParseUint(values[:digits], 16, 16)
+		if err != nil {
+			return "E00"
+		}
+		writeRegister(cpu, n, uint16(value))
+		values = values[digits:]
+	}
+	return "OK"
+}
+
+// writeOneRegister handles "n=value" of P packets
+func writeOneRegister(cpu *chip8.CPU, request string) string {
+	parts := strings.SplitN(request, "=", 2)
+	if len(parts) != 2 {
+		return "E00"
+	}
+	n, err := strconv.ParseUint(parts[0], 16, 8)
+	if err != nil || int(n) >= len(registers) {
+		return "E00"
+	}
+	value, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil || len(parts[1]) != registers[n].bitsize/4 {
+		return "E00"
+	}
+	writeRegister(cpu, int(n), uint16(value))
+	return "OK"
+}
@@ -0,0 +1,199 @@
+package gdb
+
+import (
+	"GoCHIP-8/chip8"
+	"bufio"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// client speaks the protocol to a server over a pipe
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+	done chan error
+}
+
+func newClient(t *testing.T, cpu *chip8.CPU) *client {
+	server := NewServer(cpu)
+	server.FrameDuration = 0
+	serverConn, clientConn := net.Pipe()
+	c := &client{t: t, conn: clientConn, r: bufio.NewReader(clientConn), done: make(chan error, 1)}
+	go func() {
+		c.done <- server.Serve(serverConn)
+		_ = serverConn.Close()
+	}()
+	return c
+}
+
+// send sends a packet and reads its acknowledgement
+func (c *client) send(data string) {
+	_, err := fmt.Fprintf(c.conn, "$%s#%02x", data, checksum(data))
+	assert.Nil(c.t, err)
+	ack, err := c.r.ReadByte()
+	assert.Nil(c.t, err)
+	assert.Equal(c.t, byte('+'), ack, data)
+}
+
+// request sends a packet and returns the data of the reply
+func (c *client) request(data string) string {
+	c.send(data)
+	return c.reply()
+}
+
+func (c *client) reply() string {
+	start, err := c.r.ReadByte()
+	assert.Nil(c.t, err)
+	assert.Equal(c.t, byte('$'), start)
+	packet, err := c.r.ReadString('#')
+	assert.Nil(c.t, err)
+	packet = packet[:len(packet)-1]
+	sum := make([]byte, 2)
+	_, err = io.ReadFull(c.r, sum)
+	assert.Nil(c.t, err)
+	assert.Equal(c.t, fmt.Sprintf("%02x", checksum(packet)), string(sum))
+	return packet
+}
+
+func newTestCPU() *chip8.CPU {
+	cpu := chip8.NewCPU()
+	copy(cpu.Memory.Memory[0x200:], []byte{
+		0x60, 0x03, // 0x200: v0 := 3
+		0xA3, 0x00, // 0x202: i := 0x300
+		0xF0, 0x55, // 0x204: save v0
+		0xF0, 0x65, // 0x206: load v0
+		0x12, 0x08, // 0x208: jump 0x208
+	})
+	return &cpu
+}
+
+func TestHandshake(t *testing.T) {
+	c := newClient(t, newTestCPU())
+	assert.Contains(t, c.request("qSupported:multiprocess+;swbreak+"), "qXfer:features:read+")
+	assert.Equal(t, "S05", c.request("?"))
+
+	// The target description is read in chunks
+	var xml strings.Builder
+	for offset := 0; ; offset += 0x80 {
+		chunk := c.request(fmt.Sprintf("qXfer:features:read:target.xml:%x,80", offset))
+		xml.WriteString(chunk[1:])
+		if chunk[0] == 'l' {
+			break
+		}
+		assert.Equal(t, byte('m'), chunk[0])
+	}
+	assert.Equal(t, targetXML, xml.String())
+	assert.Contains(t, targetXML, `<reg name="v0" bitsize="8" type="uint8" regnum="0"/>`)
+	assert.Contains(t, targetXML, `<reg name="pc" bitsize="16" type="code_ptr" regnum="17"/>`)
+	assert.Contains(t, targetXML, `<reg name="st" bitsize="8" type="uint8" regnum="20"/>`)
+
+	assert.Equal(t, "", c.request("vMustReplyEmpty"))
+	assert.Equal(t, "OK", c.request("D"))
+	assert.Nil(t, <-c.done)
+}
+
+func TestRegistersAndMemory(t *testing.T) {
+	cpu := newTestCPU()
+	cpu.Register.V[0xF] = 0x01
+	cpu.Register.I = 0x123
+	cpu.Register.DT = 0x3C
+	c := newClient(t, cpu)
+	assert.Equal(t, "00000000000000000000000000000001"+"0123"+"0200"+"00"+"3c"+"00", c.request("g"))
+	assert.Equal(t, "0200", c.request("p11"))
+	assert.Equal(t, "OK", c.request("P11=0202"))
+	assert.Equal(t, "OK", c.request("P3=7f"))
+	assert.Equal(t, "E00", c.request("P3=7f00"))
+	assert.Equal(t, "E00", c.request("p15"))
+	assert.Equal(t, uint16(0x202), cpu.Register.PC)
+	assert.Equal(t, byte(0x7F), cpu.Register.V[3])
+	assert.Equal(t, "OK", c.request("G"+"0102030405060708090a0b0c0d0e0f10"+"0300"+"0204"+"01"+"02"+"03"))
+	assert.Equal(t, byte(0x10), cpu.Register.V[0xF])
+	assert.Equal(t, uint16(0x204), cpu.Register.PC)
+	assert.Equal(t, byte(0x03), cpu.Register.ST)
+
+	assert.Equal(t, "6003a300", c.request("m200,4"))
+	assert.Equal(t, "OK", c.request("M300,2:beef"))
+	assert.Equal(t, []byte{0xBE, 0xEF}, cpu.Memory.Memory[0x300:0x302])
+	assert.Equal(t, "00", c.request("mffff,10"))
+	assert.Equal(t, "E01", c.request("m10000,1"))
+	assert.Equal(t, "E00", c.request("M300,2:be"))
+	c.conn.Close()
+	assert.Nil(t, <-c.done)
+}
+
+func TestStepAndBreakpoints(t *testing.T) {
+	cpu := newTestCPU()
+	c := newClient(t, cpu)
+	assert.Equal(t, "S05", c.request("s"))
+	assert.Equal(t, uint16(0x202), cpu.Register.PC)
+
+	assert.Equal(t, "OK", c.request("Z0,206,2"))
+	assert.Equal(t, "T05swbreak:;", c.request("c"))
+	assert.Equal(t, uint16(0x206), cpu.Register.PC)
+	assert.Equal(t, "OK", c.request("z0,206,2"))
+
+	// Continuing into the endless loop runs until interrupted
+	c.send("c")
+	_, err := c.conn.Write([]byte{0x03})
+	assert.Nil(t, err)
+	assert.Equal(t, "S02", c.reply())
+	assert.Equal(t, uint16(0x208), cpu.Register.PC)
+
+	// Continue and step can resume at another address
+	assert.Equal(t, "S05", c.request("s200"))
+	assert.Equal(t, uint16(0x202), cpu.Register.PC)
+	c.send("k")
+	assert.Nil(t, <-c.done)
+}
+
+func TestWatchpoints(t *testing.T) {
+	cpu := newTestCPU()
+	c := newClient(t, cpu)
+	assert.Equal(t, "OK", c.request("Z2,300,1"))
+	assert.Equal(t, "T05watch:300;", c.request("c"))
+	assert.Equal(t, uint16(0x206), cpu.Register.PC)
+	assert.Equal(t, "OK", c.request("z2,300,1"))
+	assert.Equal(t, "OK", c.request("Z3,300,1"))
+	assert.Equal(t, "T05rwatch:300;", c.request("c"))
+	assert.Equal(t, uint16(0x208), cpu.Register.PC)
+	assert.Equal(t, "OK", c.request("z3,300,1"))
+	assert.Empty(t, NewServer(cpu).Debugger.Watchpoints())
+	assert.Equal(t, "E01", c.request("Z2,ffff,2"))
+	c.send("k")
+	assert.Nil(t, <-c.done)
+}
+
+func TestStopReplies(t *testing.T) {
+	cpu := chip8.NewCPU()
+	copy(cpu.Memory.Memory[0x200:], []byte{0x00, 0xFD, 0xFF, 0xFF, 0x00, 0xEE})
+	c := newClient(t, &cpu)
+	assert.Equal(t, "W00", c.request("c"))
+	cpu.Exited = false
+	assert.Equal(t, "S04", c.request("c202"))
+	assert.Equal(t, "S0b", c.request("s204"))
+	c.send("k")
+	assert.Nil(t, <-c.done)
+}
+
+func TestNoAckModeAndRetransmit(t *testing.T) {
+	c := newClient(t, newTestCPU())
+	// A corrupt packet is rejected
+	_, _ = fmt.Fprint(c.conn, "$g#00")
+	ack, _ := c.r.ReadByte()
+	assert.Equal(t, byte('-'), ack)
+	registers := c.request("g")
+	// The client asks for the last packet again
+	_, _ = fmt.Fprint(c.conn, "-")
+	assert.Equal(t, registers, c.reply())
+
+	assert.Equal(t, "OK", c.request("QStartNoAckMode"))
+	_, _ = fmt.Fprintf(c.conn, "$?#%02x", checksum("?"))
+	assert.Equal(t, "S05", c.reply())
+	c.conn.Close()
+	assert.Nil(t, <-c.done)
+}
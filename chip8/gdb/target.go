@@ -0,0 +1,90 @@
+package gdb
+
+import (
+	"GoCHIP-8/chip8"
+	"fmt"
+	"strings"
+)
+
+// register of the target description, values are sent big-endian like CHIP-8 stores words in memory
+type register struct {
+	name    string
+	bitsize int
+	kind    string
+}
+
+// registers in the order of the g packet and their register numbers
+var registers = func() []register {
+	var regs []register
+	for i := 0; i < 16; i++ {
+		regs = append(regs, register{fmt.Sprintf("v%x", i), 8, "uint8"})
+	}
+	return append(regs,
+		register{"i", 16, "data_ptr"},
+		register{"pc", 16, "code_ptr"},
+		register{"sp", 8, "uint8"},
+		register{"dt", 8, "uint8"},
+		register{"st", 8, "uint8"},
+	)
+}()
+
+const pcRegister = 17
+
+// targetXML is the target description sent for qXfer:features:read:target.xml
+var targetXML = func() string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <feature name="org.gochip8.chip8">
+`)
+	for i, r := range registers {
+		sb.WriteString(fmt.Sprintf("    <reg name=\"%s\" bitsize=\"%d\" type=\"%s\" regnum=\"%d\"/>\n", r.name, r.bitsize, r.kind, i))
+	}
+	sb.WriteString("  </feature>\n</target>\n")
+	return sb.String()
+}()
+
+// readRegister returns register n of cpu
+func readRegister(cpu *chip8.CPU, n int) uint16 {
+	switch {
+	case n < 16:
+		return uint16(cpu.Register.V[n])
+	case n == 16:
+		return cpu.Register.I
+	case n == pcRegister:
+		return cpu.Register.PC
+	case n == 18:
+		return uint16(cpu.Register.SP)
+	case n == 19:
+		return uint16(cpu.Register.DT)
+	}
+	return uint16(cpu.Register.ST)
+}
+
+// writeRegister sets register n of cpu
+func writeRegister(cpu *chip8.CPU, n int, value uint16) {
+	switch {
+	case n < 16:
+		cpu.Register.V[n] = byte(value)
+	case n == 16:
+		cpu.Register.I = value
+	case n == pcRegister:
+		cpu.Register.PC = value
+	case n == 18:
+		cpu.Register.SP = byte(value)
+	case n == 19:
+		cpu.Register.DT = byte(value)
+	default:
+		cpu.Register.ST = byte(value)
+	}
+}
+
+// encodeRegister returns the hex encoding of register n
+func encodeRegister(cpu *chip8.CPU, n int) string {
+	value := readRegister(cpu, n)
+	if registers[n].bitsize == 16 {
+		return fmt.Sprintf("%04x", value)
+	}
+	return fmt.Sprintf("%02x", value)
+}
@@ -0,0 +1,19 @@
+package chip8
+
+import (
+	"image"
+	"image/color"
+)
+
+// DisplayImage renders the display in the current resolution with one image pixel per display pixel.
+// palette holds the colors of the background, plane 1, plane 2 and both planes.
+func (cpu *CPU) DisplayImage(palette color.Palette) *image.Paletted {
+	width, height := cpu.DisplaySize()
+	img := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			img.SetColorIndex(j, i, cpu.Display[i][j]&0x03)
+		}
+	}
+	return img
+}
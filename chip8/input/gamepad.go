@@ -0,0 +1,192 @@
+package input
+
+import (
+	"GoCHIP-8/chip8/keymap"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Gamepad is a connected gamepad, buttons and axes out of its range are released and centered
+type Gamepad interface {
+	Button(index int) bool
+	// Axis returns the position of an axis from -1 to 1
+	Axis(index int) float64
+}
+
+// Control is a gamepad button, or an axis pushed in one direction
+type Control struct {
+	Axis  bool
+	Index int
+	// Direction of the axis, -1 or 1
+	Direction float64
+}
+
+// Pressed reports whether the control is held on pad, axes must be pushed past the deadzone
+func (c Control) Pressed(pad Gamepad, deadzone float64) bool {
+	if c.Axis {
+		return pad.Axis(c.Index)*c.Direction > deadzone
+	}
+	return pad.Button(c.Index)
+}
+
+// controlNames follow the layout reported for XInput gamepads, where the D-pad comes after the buttons.
+// Other gamepads can use ButtonN and AxisN+ or AxisN-.
+var controlNames = map[string]Control{
+	"a":               {Index: 0},
+	"b":               {Index: 1},
+	"x":               {Index: 2},
+	"y":               {Index: 3},
+	"lb":              {Index: 4},
+	"rb":              {Index: 5},
+	"back":            {Index: 6},
+	"start":           {Index: 7},
+	"leftstick":       {Index: 9},
+	"rightstick":      {Index: 10},
+	"dpadup":          {Index: 11},
+	"dpadright":       {Index: 12},
+	"dpaddown":        {Index: 13},
+	"dpadleft":        {Index: 14},
+	"leftstickleft":   {Axis: true, Index: 0, Direction: -1},
+	"leftstickright":  {Axis: true, Index: 0, Direction: 1},
+	"leftstickup":     {Axis: true, Index: 1, Direction: -1},
+	"leftstickdown":   {Axis: true, Index: 1, Direction: 1},
+	"rightstickleft":  {Axis: true, Index: 2, Direction: -1},
+	"rightstickright": {Axis: true, Index: 2, Direction: 1},
+	"rightstickup":    {Axis: true, Index: 3, Direction: -1},
+	"rightstickdown":  {Axis: true, Index: 3, Direction: 1},
+	"lt":              {Axis: true, Index: 4, Direction: 1},
+	"rt":              {Axis: true, Index: 5, Direction: 1},
+}
+
+// ParseControl parses the name of a control, like A, DpadUp, LeftStickDown, Button15 or Axis6-
+func ParseControl(name string) (Control, error) {
+	lower := strings.ToLower(name)
+	if c, ok := controlNames[lower]; ok {
+		return c, nil
+	}
+	if strings.HasPrefix(lower, "button") {
+		if index, err := strconv.ParseUint(lower[len("button"):], 10, 8); err == nil {
+			return Control{Index: int(index)}, nil
+		}
+	}
+	if strings.HasPrefix(lower, "axis") && len(lower) > len("axis")+1 {
+		direction := 1.0
+		switch lower[len(lower)-1] {
+		case '-':
+			direction = -1
+		case '+':
+		default:
+			return Control{}, fmt.Errorf("unknown gamepad control %q, axes end with + or -", name)
+		}
+		if index, err := strconv.ParseUint(lower[len("axis"):len(lower)-1], 10, 8); err == nil {
+			return Control{Axis: true, Index: int(index), Direction: direction}, nil
+		}
+	}
+	return Control{}, fmt.Errorf("unknown gamepad control %q", name)
+}
+
+// KnownControl reports whether a control name is valid, to check gamepad profiles with keymap.Keymap.Check
+func KnownControl(name string) bool {
+	_, err := ParseControl(name)
+	return err == nil
+}
+
+// GamepadSource is a Source pressing CHIP-8 keys with the controls of every connected gamepad
+type GamepadSource struct {
+	// Deadzone is how far from 0 to 1 a stick must be pushed to press a key
+	Deadzone float64
+	// Gamepads returns the gamepads connected, it is called on every poll so they can be plugged at any time
+	Gamepads func() []Gamepad
+	controls [16][]Control
+}
+
+// NewGamepadSource maps the controls named by a profile to CHIP-8 keys
+func NewGamepadSource(profile keymap.Keymap, gamepads func() []Gamepad) (*GamepadSource, error) {
+	s := &GamepadSource{Deadzone: 0.5, Gamepads: gamepads}
+	for key, names := range profile {
+		for _, name := range names {
+			c, err := ParseControl(name)
+			if err != nil {
+				return nil, err
+			}
+			s.controls[key] = append(s.controls[key], c)
+		}
+	}
+	return s, nil
+}
+
+// Poll marks the keys whose controls are held on any gamepad
+func (s *GamepadSource) Poll(keys *[16]bool) {
+	for _, pad := range s.Gamepads() {
+		for key, controls := range s.controls {
+			for _, c := range controls {
+				if c.Pressed(pad, s.Deadzone) {
+					keys[key] = true
+					break
+				}
+			}
+		}
+	}
+}
+
+// directions maps the D-pad and the left stick to four CHIP-8 keys
+func directions(up, down, left, right byte) map[byte][]string {
+	return map[byte][]string{
+		up:    {"DpadUp", "LeftStickUp"},
+		down:  {"DpadDown", "LeftStickDown"},
+		left:  {"DpadLeft", "LeftStickLeft"},
+		right: {"DpadRight", "LeftStickRight"},
+	}
+}
+
+// vertical maps up and down of the D-pad and the left stick to two CHIP-8 keys
+func vertical(up, down byte) map[byte][]string {
+	return map[byte][]string{
+		up:   {"DpadUp", "LeftStickUp"},
+		down: {"DpadDown", "LeftStickDown"},
+	}
+}
+
+// horizontal maps left and right of the D-pad and the left stick to two CHIP-8 keys
+func horizontal(left, right byte) map[byte][]string {
+	return map[byte][]string{
+		left:  {"DpadLeft", "LeftStickLeft"},
+		right: {"DpadRight", "LeftStickRight"},
+	}
+}
+
+// with adds controls to a profile
+func with(profile map[byte][]string, key byte, names ...string) map[byte][]string {
+	profile[key] = append(profile[key], names...)
+	return profile
+}
+
+// profiles are the default gamepad profiles of the games in roms/ by file name
+var profiles = map[string]map[byte][]string{
+	"BLITZ":    {0x5: {"A"}},
+	"BRIX":     horizontal(0x4, 0x6),
+	"INVADERS": with(horizontal(0x4, 0x6), 0x5, "A"),
+	"MISSILE":  {0x8: {"A"}},
+	"PONG":     vertical(0x1, 0x4),
+	"PONG2":    with(with(vertical(0x1, 0x4), 0xC, "RightStickUp"), 0xD, "RightStickDown"),
+	"TANK":     with(directions(0x2, 0x8, 0x4, 0x6), 0x5, "A"),
+	"UFO":      {0x4: {"X", "DpadLeft"}, 0x5: {"Y", "DpadUp"}, 0x6: {"B", "DpadRight"}},
+	"VBRIX":    with(vertical(0x1, 0x4), 0x7, "Start", "A"),
+	"WIPEOFF":  horizontal(0x4, 0x6),
+}
+
+// Profile returns the default gamepad profile of a ROM by its file name. ROMs without a profile
+// get the directions on 2, 8, 4 and 6 like most games, and A on 5.
+func Profile(romPath string) keymap.Keymap {
+	controls, ok := profiles[strings.ToUpper(filepath.Base(romPath))]
+	if !ok {
+		controls = with(directions(0x2, 0x8, 0x4, 0x6), 0x5, "A")
+	}
+	var profile keymap.Keymap
+	for key, names := range controls {
+		profile[key] = append([]string{}, names...)
+	}
+	return profile
+}
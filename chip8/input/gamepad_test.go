@@ -0,0 +1,100 @@
+package input
+
+import (
+	"GoCHIP-8/chip8/keymap"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeGamepad struct {
+	buttons map[int]bool
+	axes    map[int]float64
+}
+
+func (g fakeGamepad) Button(index int) bool {
+	return g.buttons[index]
+}
+
+func (g fakeGamepad) Axis(index int) float64 {
+	return g.axes[index]
+}
+
+func TestParseControl(t *testing.T) {
+	for name, control := range map[string]Control{
+		"A":              {Index: 0},
+		"dpadleft":       {Index: 14},
+		"LeftStickUp":    {Axis: true, Index: 1, Direction: -1},
+		"Button20":       {Index: 20},
+		"Axis6-":         {Axis: true, Index: 6, Direction: -1},
+		"axis7+":         {Axis: true, Index: 7, Direction: 1},
+		"RightStickDown": {Axis: true, Index: 3, Direction: 1},
+	} {
+		c, err := ParseControl(name)
+		assert.Nil(t, err, name)
+		assert.Equal(t, control, c, name)
+	}
+	for _, name := range []string{"", "Z", "Button", "Button-1", "Axis", "Axis1", "Axisx+"} {
+		_, err := ParseControl(name)
+		assert.NotNil(t, err, name)
+	}
+	assert.False(t, KnownControl("Up"))
+}
+
+func TestGamepadSource(t *testing.T) {
+	var pads []Gamepad
+	profile, err := keymap.Parse(strings.NewReader(`{"1": ["DpadUp", "LeftStickUp"], "4": ["DpadDown", "LeftStickDown"], "5": ["A"]}`))
+	assert.Nil(t, err)
+	source, err := NewGamepadSource(profile, func() []Gamepad { return pads })
+	assert.Nil(t, err)
+
+	poll := func() [16]bool {
+		var keys [16]bool
+		source.Poll(&keys)
+		return keys
+	}
+	// No gamepad connected yet
+	assert.Equal(t, [16]bool{}, poll())
+
+	// The stick must be pushed past the deadzone
+	pads = []Gamepad{fakeGamepad{axes: map[int]float64{1: -0.3}}}
+	assert.Equal(t, [16]bool{}, poll())
+	pads = []Gamepad{fakeGamepad{axes: map[int]float64{1: -0.8}}}
+	assert.Equal(t, [16]bool{0x1: true}, poll())
+	pads = []Gamepad{fakeGamepad{axes: map[int]float64{1: 0.8}}}
+	assert.Equal(t, [16]bool{0x4: true}, poll())
+	source.Deadzone = 0.9
+	assert.Equal(t, [16]bool{}, poll())
+
+	// Every connected gamepad presses keys
+	pads = []Gamepad{
+		fakeGamepad{buttons: map[int]bool{13: true}},
+		fakeGamepad{buttons: map[int]bool{0: true}},
+	}
+	assert.Equal(t, [16]bool{0x4: true, 0x5: true}, poll())
+
+	profile[0x2] = []string{"Trigger"}
+	_, err = NewGamepadSource(profile, nil)
+	assert.EqualError(t, err, `unknown gamepad control "Trigger"`)
+}
+
+func TestProfile(t *testing.T) {
+	pong := Profile("roms/PONG")
+	assert.Equal(t, []string{"DpadUp", "LeftStickUp"}, pong[0x1])
+	assert.Equal(t, []string{"DpadDown", "LeftStickDown"}, pong[0x4])
+	brix := Profile("roms/brix")
+	assert.Equal(t, []string{"DpadLeft", "LeftStickLeft"}, brix[0x4])
+	assert.Equal(t, []string{"DpadRight", "LeftStickRight"}, brix[0x6])
+	other := Profile("game.ch8")
+	assert.Equal(t, []string{"DpadUp", "LeftStickUp"}, other[0x2])
+	assert.Equal(t, []string{"A"}, other[0x5])
+
+	// The default profiles are valid keymaps
+	for name := range profiles {
+		profile := Profile(name)
+		assert.Nil(t, profile.Check(KnownControl, nil), name)
+		_, err := NewGamepadSource(profile, nil)
+		assert.Nil(t, err, name)
+	}
+}
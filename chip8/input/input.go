@@ -0,0 +1,20 @@
+// Package input abstracts where the CHIP-8 keys are pressed from. The keyboard, gamepads and input scripts
+// are all Sources, polled once per frame to set the key state of the CPU.
+package input
+
+import "GoCHIP-8/chip8"
+
+// Source is a device or a script pressing CHIP-8 keys
+type Source interface {
+	// Poll marks the keys held down on the source, it never clears keys marked by other sources
+	Poll(keys *[16]bool)
+}
+
+// Poll sets the key state of cpu, a key is down when it is held on any of the sources
+func Poll(cpu *chip8.CPU, sources ...Source) {
+	var keys [16]bool
+	for _, source := range sources {
+		source.Poll(&keys)
+	}
+	cpu.SetKeys(keys)
+}
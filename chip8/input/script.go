@@ -0,0 +1,87 @@
+package input
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Event presses or releases a CHIP-8 key before the given frame is executed
+type Event struct {
+	Frame   int
+	Key     byte
+	Pressed bool
+}
+
+// Script is a Source replaying events, each poll is a frame
+type Script struct {
+	Events []Event
+	frame  int
+	next   int
+	keys   [16]bool
+}
+
+// NewScript returns a script of events sorted by frame
+func NewScript(events []Event) *Script {
+	return &Script{Events: events}
+}
+
+// Poll applies the events of the next frame and marks the keys held down
+func (s *Script) Poll(keys *[16]bool) {
+	for ; s.next < len(s.Events) && s.Events[s.next].Frame <= s.frame; s.next++ {
+		s.keys[s.Events[s.next].Key&0xF] = s.Events[s.next].Pressed
+	}
+	s.frame++
+	for key, down := range s.keys {
+		if down {
+			keys[key] = true
+		}
+	}
+}
+
+// ParseScript parses lines of "<frame> <key> down|up", key is a hex digit and # starts a comment
+func ParseScript(r io.Reader) ([]Event, error) {
+	var events []Event
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%d: expected \"<frame> <key> down|up\"", line)
+		}
+		frame, err := strconv.Atoi(fields[0])
+		if err != nil || frame < 0 {
+			return nil, fmt.Errorf("%d: invalid frame %q", line, fields[0])
+		}
+		key, err := strconv.ParseUint(fields[1], 16, 8)
+		if err != nil || key > 0xF {
+			return nil, fmt.Errorf("%d: invalid key %q", line, fields[1])
+		}
+		var pressed bool
+		switch fields[2] {
+		case "down":
+			pressed = true
+		case "up":
+			pressed = false
+		default:
+			return nil, fmt.Errorf("%d: invalid key state %q", line, fields[2])
+		}
+		events = append(events, Event{Frame: frame, Key: byte(key), Pressed: pressed})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Frame < events[j].Frame
+	})
+	return events, nil
+}
@@ -0,0 +1,64 @@
+package input
+
+import (
+	"GoCHIP-8/chip8"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScript(t *testing.T) {
+	events, err := ParseScript(strings.NewReader(`# Move the paddle
+20 1 down
+10 c down # comment
+30 1 up
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, []Event{
+		{Frame: 10, Key: 0xC, Pressed: true},
+		{Frame: 20, Key: 0x1, Pressed: true},
+		{Frame: 30, Key: 0x1, Pressed: false},
+	}, events)
+
+	_, err = ParseScript(strings.NewReader("10 1 down\n10 10 down\n"))
+	assert.EqualError(t, err, `2: invalid key "10"`)
+	_, err = ParseScript(strings.NewReader("-1 1 down\n"))
+	assert.EqualError(t, err, `1: invalid frame "-1"`)
+	_, err = ParseScript(strings.NewReader("1 1 pressed\n"))
+	assert.EqualError(t, err, `1: invalid key state "pressed"`)
+	_, err = ParseScript(strings.NewReader("1 1\n"))
+	assert.NotNil(t, err)
+}
+
+// held is a Source holding fixed keys
+type held []byte
+
+func (h held) Poll(keys *[16]bool) {
+	for _, key := range h {
+		keys[key] = true
+	}
+}
+
+func TestPoll(t *testing.T) {
+	cpu := chip8.NewCPU()
+	script := NewScript([]Event{
+		{Frame: 1, Key: 0x5, Pressed: true},
+		{Frame: 2, Key: 0x1, Pressed: true},
+		{Frame: 3, Key: 0x5, Pressed: false},
+	})
+	var states [][16]byte
+	for frame := 0; frame < 5; frame++ {
+		Poll(&cpu, script, held{0x1})
+		states = append(states, cpu.KeyState)
+	}
+	// Key 1 is held by the other source all along, releasing it on the script does not release it
+	assert.Equal(t, [16]byte{0x1: 1}, states[0])
+	assert.Equal(t, [16]byte{0x1: 1, 0x5: 1}, states[1])
+	assert.Equal(t, [16]byte{0x1: 1, 0x5: 1}, states[2])
+	assert.Equal(t, [16]byte{0x1: 1}, states[3])
+	assert.Equal(t, [16]byte{0x1: 1}, states[4])
+
+	Poll(&cpu)
+	assert.Equal(t, [16]byte{}, cpu.KeyState)
+}
@@ -1,30 +1,78 @@
 package chip8
 
-import "math/rand"
+func (cpu *CPU) exec00CN(n uint16) {
+	cpu.scrollDisplay(0, int(n))
+	cpu.Register.PC += 2
+}
+
+func (cpu *CPU) exec00DN(n uint16) {
+	cpu.scrollDisplay(0, -int(n))
+	cpu.Register.PC += 2
+}
 
 func (cpu *CPU) exec00E0() {
-	cpu.ClearDisplay()
+	for i := 0; i < HiResDisplayHeight; i++ {
+		for j := 0; j < HiResDisplayWidth; j++ {
+			cpu.Display[i][j] &^= cpu.Plane
+		}
+	}
 	cpu.Register.PC += 2
 }
 
-func (cpu *CPU) exec00EE() {
+func (cpu *CPU) exec00EE() error {
+	if cpu.Register.SP == 0 {
+		return ErrStackUnderflow{PC: cpu.Register.PC}
+	}
 	cpu.Register.SP--
 	cpu.Register.PC = cpu.Stack[cpu.Register.SP] + 2
+	return nil
+}
+
+func (cpu *CPU) exec00FB() {
+	cpu.scrollDisplay(4, 0)
+	cpu.Register.PC += 2
+}
+
+func (cpu *CPU) exec00FC() {
+	cpu.scrollDisplay(-4, 0)
+	cpu.Register.PC += 2
+}
+
+func (cpu *CPU) exec00FD() {
+	cpu.Exited = true
+}
+
+func (cpu *CPU) exec00FE() {
+	cpu.HiRes = false
+	cpu.ClearDisplay()
+	cpu.NeedDraw = true
+	cpu.Register.PC += 2
+}
+
+func (cpu *CPU) exec00FF() {
+	cpu.HiRes = true
+	cpu.ClearDisplay()
+	cpu.NeedDraw = true
+	cpu.Register.PC += 2
 }
 
 func (cpu *CPU) exec1NNN(nnn uint16) {
 	cpu.Register.PC = nnn
 }
 
-func (cpu *CPU) exec2NNN(nnn uint16) {
+func (cpu *CPU) exec2NNN(nnn uint16) error {
+	if int(cpu.Register.SP) >= cpu.StackDepth() {
+		return ErrStackOverflow{PC: cpu.Register.PC, Calls: append([]uint16{}, cpu.Stack[:cpu.Register.SP]...)}
+	}
 	cpu.Stack[cpu.Register.SP] = cpu.Register.PC
 	cpu.Register.SP++
 	cpu.Register.PC = nnn
+	return nil
 }
 
 func (cpu *CPU) exec3XNN(x uint16, nn byte) {
 	if cpu.Register.V[x] == nn {
-		cpu.Register.PC += 4
+		cpu.skipNextInstruction()
 	} else {
 		cpu.Register.PC += 2
 	}
@@ -32,7 +80,7 @@ func (cpu *CPU) exec3XNN(x uint16, nn byte) {
 
 func (cpu *CPU) exec4XNN(x uint16, nn byte) {
 	if cpu.Register.V[x] != nn {
-		cpu.Register.PC += 4
+		cpu.skipNextInstruction()
 	} else {
 		cpu.Register.PC += 2
 	}
@@ -40,12 +88,50 @@ func (cpu *CPU) exec4XNN(x uint16, nn byte) {
 
 func (cpu *CPU) exec5XY0(x, y uint16) {
 	if cpu.Register.V[x] == cpu.Register.V[y] {
-		cpu.Register.PC += 4
+		cpu.skipNextInstruction()
 	} else {
 		cpu.Register.PC += 2
 	}
 }
 
+func (cpu *CPU) exec5XY2(x, y uint16) error {
+	for i, r := 0, x; ; i++ {
+		if err := cpu.writeMemory(int(cpu.Register.I)+i, cpu.Register.V[r]); err != nil {
+			return err
+		}
+		if r == y {
+			break
+		}
+		if x < y {
+			r++
+		} else {
+			r--
+		}
+	}
+	cpu.Register.PC += 2
+	return nil
+}
+
+func (cpu *CPU) exec5XY3(x, y uint16) error {
+	for i, r := 0, x; ; i++ {
+		value, err := cpu.readMemory(int(cpu.Register.I) + i)
+		if err != nil {
+			return err
+		}
+		cpu.Register.V[r] = value
+		if r == y {
+			break
+		}
+		if x < y {
+			r++
+		} else {
+			r--
+		}
+	}
+	cpu.Register.PC += 2
+	return nil
+}
+
 func (cpu *CPU) exec6XNN(x uint16, nn byte) {
 	cpu.Register.V[x] = nn
 	cpu.Register.PC += 2
@@ -63,16 +149,25 @@ func (cpu *CPU) exec8XY0(x, y uint16) {
 
 func (cpu *CPU) exec8XY1(x, y uint16) {
 	cpu.Register.V[x] |= cpu.Register.V[y]
+	if cpu.Quirks.LogicResetsVF {
+		cpu.Register.V[0xF] = 0
+	}
 	cpu.Register.PC += 2
 }
 
 func (cpu *CPU) exec8XY2(x, y uint16) {
 	cpu.Register.V[x] &= cpu.Register.V[y]
+	if cpu.Quirks.LogicResetsVF {
+		cpu.Register.V[0xF] = 0
+	}
 	cpu.Register.PC += 2
 }
 
 func (cpu *CPU) exec8XY3(x, y uint16) {
 	cpu.Register.V[x] ^= cpu.Register.V[y]
+	if cpu.Quirks.LogicResetsVF {
+		cpu.Register.V[0xF] = 0
+	}
 	cpu.Register.PC += 2
 }
 
@@ -96,7 +191,10 @@ func (cpu *CPU) exec8XY5(x, y uint16) {
 	cpu.Register.PC += 2
 }
 
-func (cpu *CPU) exec8XY6(x uint16) {
+func (cpu *CPU) exec8XY6(x, y uint16) {
+	if cpu.Quirks.ShiftUsesVY {
+		cpu.Register.V[x] = cpu.Register.V[y]
+	}
 	cpu.Register.V[0xF] = cpu.Register.V[x] & 0x01
 	cpu.Register.V[x] >>= 1
 	cpu.Register.PC += 2
@@ -112,7 +210,10 @@ func (cpu *CPU) exec8XY7(x, y uint16) {
 	cpu.Register.PC += 2
 }
 
-func (cpu *CPU) exec8XYE(x uint16) {
+func (cpu *CPU) exec8XYE(x, y uint16) {
+	if cpu.Quirks.ShiftUsesVY {
+		cpu.Register.V[x] = cpu.Register.V[y]
+	}
 	cpu.Register.V[0xF] = cpu.Register.V[x] >> 7
 	cpu.Register.V[x] <<= 1
 	cpu.Register.PC += 2
@@ -120,7 +221,7 @@ func (cpu *CPU) exec8XYE(x uint16) {
 
 func (cpu *CPU) exec9XY0(x, y uint16) {
 	if cpu.Register.V[x] != cpu.Register.V[y] {
-		cpu.Register.PC += 4
+		cpu.skipNextInstruction()
 	} else {
 		cpu.Register.PC += 2
 	}
@@ -132,71 +233,132 @@ func (cpu *CPU) execANNN(nnn uint16) {
 }
 
 func (cpu *CPU) execBNNN(nnn uint16) {
+	if cpu.Quirks.JumpUsesVX {
+		cpu.Register.PC = uint16(cpu.Register.V[(nnn&0x0F00)>>8]) + nnn
+		return
+	}
 	cpu.Register.PC = uint16(cpu.Register.V[0]) + nnn
 }
 
 func (cpu *CPU) execCXNN(x uint16, nn byte) {
-	cpu.Register.V[x] = byte(rand.Float32()*255) & nn
+	cpu.Register.V[x] = cpu.Random.Byte() & nn
 	cpu.Register.PC += 2
 }
 
-func (cpu *CPU) execDXYN(opcode uint16) {
+func (cpu *CPU) execDXYN(opcode uint16) error {
 	x := (opcode & 0x0F00) >> 8
 	y := (opcode & 0x00F0) >> 4
-	xValue := cpu.Register.V[x]
-	yValue := cpu.Register.V[y]
-	height := byte(opcode & 0x000F)
+	displayWidth, displayHeight := cpu.DisplaySize()
+	xValue := int(cpu.Register.V[x]) % displayWidth
+	yValue := int(cpu.Register.V[y]) % displayHeight
+	width, height := 8, int(opcode&0x000F)
+	if height == 0 {
+		width, height = 16, 16
+	}
 	cpu.Register.V[0xF] = 0x00
-	for i := yValue; i < yValue+height; i++ {
-		for j := xValue; j < xValue+8; j++ {
-			bit := (cpu.Memory.Memory[cpu.Register.I+uint16(i-yValue)] >> (7 - j + cpu.Register.V[x])) & 0x01
-			xIndex, yIndex := j, i
-			if j >= DisplayWidth {
-				xIndex = j % DisplayWidth
+	// Each selected plane is drawn with its own copy of the sprite data, one after another
+	addr := int(cpu.Register.I)
+	for plane := byte(0x01); plane <= 0x02; plane <<= 1 {
+		if cpu.Plane&plane == 0 {
+			continue
+		}
+		for i := 0; i < height; i++ {
+			yIndex := yValue + i
+			if yIndex >= displayHeight {
+				if cpu.Quirks.ClipSprites {
+					break
+				}
+				yIndex %= displayHeight
 			}
-			if i >= DisplayHeight {
-				yIndex = i % DisplayHeight
+			// Sprite row left aligned in 16 bits
+			var row uint16
+			for k := 0; k < width/8; k++ {
+				value, err := cpu.readMemory(addr + i*width/8 + k)
+				if err != nil {
+					return err
+				}
+				row |= uint16(value) << (8 - 8*k)
 			}
-			if bit == 0x01 && cpu.Display[yIndex][xIndex] == 0x01 {
-				cpu.Register.V[0xF] = 0x01
+			for j := 0; j < width; j++ {
+				xIndex := xValue + j
+				if xIndex >= displayWidth {
+					if cpu.Quirks.ClipSprites {
+						break
+					}
+					xIndex %= displayWidth
+				}
+				if (row>>(15-j))&0x01 == 0 {
+					continue
+				}
+				if cpu.Display[yIndex][xIndex]&plane != 0 {
+					cpu.Register.V[0xF] = 0x01
+				}
+				cpu.Display[yIndex][xIndex] ^= plane
 			}
-			cpu.Display[yIndex][xIndex] ^= bit
 		}
+		addr += height * width / 8
 	}
 	cpu.NeedDraw = true
 	cpu.Register.PC += 2
+	return nil
 }
 
 func (cpu *CPU) execEX9E(x uint16) {
-	if cpu.KeyState[cpu.Register.V[x]] == 0x01 {
-		cpu.Register.PC += 4
+	if cpu.KeyDown(cpu.Register.V[x]) {
+		cpu.skipNextInstruction()
 	} else {
 		cpu.Register.PC += 2
 	}
 }
 
 func (cpu *CPU) execEXA1(x uint16) {
-	if cpu.KeyState[cpu.Register.V[x]] == 0x00 {
-		cpu.Register.PC += 4
+	if !cpu.KeyDown(cpu.Register.V[x]) {
+		cpu.skipNextInstruction()
 	} else {
 		cpu.Register.PC += 2
 	}
 }
 
+func (cpu *CPU) execF000(nnnn uint16) {
+	cpu.Register.I = nnnn
+	cpu.Register.PC += 4
+}
+
+func (cpu *CPU) execFN01(n uint16) {
+	cpu.Plane = byte(n)
+	cpu.Register.PC += 2
+}
+
+func (cpu *CPU) execF002() error {
+	for i := 0; i < len(cpu.AudioPattern); i++ {
+		value, err := cpu.readMemory(int(cpu.Register.I) + i)
+		if err != nil {
+			return err
+		}
+		cpu.AudioPattern[i] = value
+	}
+	cpu.PatternLoaded = true
+	cpu.Register.PC += 2
+	return nil
+}
+
 func (cpu *CPU) execFX07(x uint16) {
 	cpu.Register.V[x] = cpu.Register.DT
 	cpu.Register.PC += 2
 }
 
 func (cpu *CPU) execFX0A(x uint16) {
-	cpu.WaitInput = true
-	for i, k := range cpu.KeyState {
-		if k != 0 {
-			cpu.Register.V[x] = byte(i)
-			cpu.WaitInput = false
-			cpu.Register.PC += 2
-			break
-		}
+	if !cpu.WaitInput {
+		// Only the keys pressed and released from now on count
+		cpu.WaitInput = true
+		cpu.KeyPresses = 0
+		cpu.KeyReleases = 0
+	}
+	// PC stays on FX0A until a key completes the wait
+	if key, ok := cpu.waitKey(); ok {
+		cpu.Register.V[x] = key
+		cpu.WaitInput = false
+		cpu.Register.PC += 2
 	}
 }
 
@@ -220,23 +382,65 @@ func (cpu *CPU) execFX29(x uint16) {
 	cpu.Register.PC += 2
 }
 
-func (cpu *CPU) execFX33(x uint16) {
-	cpu.Memory.Memory[cpu.Register.I] = cpu.Register.V[x] / 100
-	cpu.Memory.Memory[cpu.Register.I+1] = (cpu.Register.V[x] / 10) % 10
-	cpu.Memory.Memory[cpu.Register.I+2] = (cpu.Register.V[x] % 100) % 10
+func (cpu *CPU) execFX30(x uint16) {
+	cpu.Register.I = uint16(bigFontAddr) + uint16(cpu.Register.V[x])*10
+	cpu.Register.PC += 2
+}
+
+func (cpu *CPU) execFX33(x uint16) error {
+	digits := [3]byte{cpu.Register.V[x] / 100, (cpu.Register.V[x] / 10) % 10, (cpu.Register.V[x] % 100) % 10}
+	for i, digit := range digits {
+		if err := cpu.writeMemory(int(cpu.Register.I)+i, digit); err != nil {
+			return err
+		}
+	}
+	cpu.Register.PC += 2
+	return nil
+}
+
+func (cpu *CPU) execFX55(x uint16) error {
+	for i := uint16(0); i <= x; i++ {
+		if err := cpu.writeMemory(int(cpu.Register.I)+int(i), cpu.Register.V[i]); err != nil {
+			return err
+		}
+	}
+	if cpu.Quirks.LoadStoreIncrementsI {
+		cpu.Register.I += x + 1
+	}
+	cpu.Register.PC += 2
+	return nil
+}
+
+func (cpu *CPU) execFX65(x uint16) error {
+	for i := uint16(0); i <= x; i++ {
+		value, err := cpu.readMemory(int(cpu.Register.I) + int(i))
+		if err != nil {
+			return err
+		}
+		cpu.Register.V[i] = value
+	}
+	if cpu.Quirks.LoadStoreIncrementsI {
+		cpu.Register.I += x + 1
+	}
+	cpu.Register.PC += 2
+	return nil
+}
+
+func (cpu *CPU) execFX3A(x uint16) {
+	cpu.Pitch = cpu.Register.V[x]
 	cpu.Register.PC += 2
 }
 
-func (cpu *CPU) execFX55(x uint16) {
+func (cpu *CPU) execFX75(x uint16) {
 	for i := uint16(0); i <= x; i++ {
-		cpu.Memory.Memory[cpu.Register.I+i] = cpu.Register.V[i]
+		cpu.Flags[i] = cpu.Register.V[i]
 	}
 	cpu.Register.PC += 2
 }
 
-func (cpu *CPU) execFX65(x uint16) {
+func (cpu *CPU) execFX85(x uint16) {
 	for i := uint16(0); i <= x; i++ {
-		cpu.Register.V[i] = cpu.Memory.Memory[cpu.Register.I+i]
+		cpu.Register.V[i] = cpu.Flags[i]
 	}
 	cpu.Register.PC += 2
 }
@@ -8,16 +8,31 @@ import (
 func TestExec00E0(t *testing.T) {
 	cpu := NewCPU()
 	cpu.Display[0][0] = 0x1
-	cpu.Display[0][1] = 0x2
-	cpu.Display[5][2] = 0x3
-	cpu.Display[10][18] = 0x4
-	cpu.Display[15][12] = 0xF
+	cpu.Display[0][1] = 0x1
+	cpu.Display[5][2] = 0x1
+	cpu.Display[10][18] = 0x1
+	cpu.Display[15][12] = 0x1
 	cpu.exec00E0()
 	for i := 0; i < DisplayHeight; i++ {
 		for j := 0; j < DisplayWidth; j++ {
 			assert.Equal(t, byte(0), cpu.Display[i][j])
 		}
 	}
+
+	// Only the selected planes are cleared
+	cpu = NewCPU()
+	cpu.Display[0][0] = 0x1
+	cpu.Display[0][1] = 0x2
+	cpu.Display[5][2] = 0x3
+	cpu.Plane = 0x2
+	cpu.exec00E0()
+	assert.Equal(t, byte(0x1), cpu.Display[0][0])
+	assert.Equal(t, byte(0x0), cpu.Display[0][1])
+	assert.Equal(t, byte(0x1), cpu.Display[5][2])
+	cpu.Plane = 0x3
+	cpu.exec00E0()
+	assert.Equal(t, byte(0x0), cpu.Display[0][0])
+	assert.Equal(t, byte(0x0), cpu.Display[5][2])
 }
 
 func TestExec00EE(t *testing.T) {
@@ -213,7 +228,7 @@ func TestExec8XY5(t *testing.T) {
 func TestExec8XY6(t *testing.T) {
 	cpu := NewCPU()
 	cpu.Register.V[0xA] = 0b10101010
-	cpu.exec8XY6(0xA)
+	cpu.exec8XY6(0xA, 0xB)
 	newCPU := NewCPU()
 	newCPU.Register.V[0xA] = 0b01010101
 	newCPU.Register.V[0xF] = 0
@@ -221,7 +236,7 @@ func TestExec8XY6(t *testing.T) {
 	assert.Equal(t, newCPU, cpu)
 
 	cpu.Register.V[0xA] = 0b01010101
-	cpu.exec8XY6(0xA)
+	cpu.exec8XY6(0xA, 0xB)
 	newCPU.Register.V[0xA] = 0b00101010
 	newCPU.Register.PC = 0x204
 	newCPU.Register.V[0xF] = 1
@@ -253,7 +268,7 @@ func TestExec8XY7(t *testing.T) {
 func TestExec8XYE(t *testing.T) {
 	cpu := NewCPU()
 	cpu.Register.V[0xA] = 0b10101010
-	cpu.exec8XYE(0xA)
+	cpu.exec8XYE(0xA, 0xB)
 	newCPU := NewCPU()
 	newCPU.Register.V[0xA] = 0b01010100
 	newCPU.Register.V[0xF] = 0b00000001
@@ -304,10 +319,23 @@ func TestExecCXNN(t *testing.T) {
 	newCPU := NewCPU()
 	newCPU.Register.V[0xA] = 0x00
 	newCPU.Register.PC = 0x202
+	newCPU.Random.Byte()
 	assert.Equal(t, newCPU, cpu)
 	cpu.execCXNN(0xA, 0xFF)
 	assert.LessOrEqual(t, cpu.Register.V[0xA], byte(0xFF))
 	assert.GreaterOrEqual(t, cpu.Register.V[0xA], byte(0x00))
+
+	// The random number is masked by NN
+	cpu.Random = fixedRandom(0xFF)
+	cpu.execCXNN(0xA, 0x3C)
+	assert.Equal(t, byte(0x3C), cpu.Register.V[0xA])
+}
+
+// fixedRandom always returns the same number
+type fixedRandom byte
+
+func (r fixedRandom) Byte() byte {
+	return byte(r)
 }
 
 func TestExecDXYN(t *testing.T) {
@@ -443,6 +471,81 @@ func TestExecFX0A(t *testing.T) {
 	assert.Equal(t, newCPU, cpu)
 }
 
+func TestExecFX0AWaitsForRelease(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Quirks.KeyWaitsForRelease = true
+	// A key held before FX0A starts waiting must be pressed again
+	cpu.SetKey(0x2, true)
+	cpu.execFX0A(0xB)
+	assert.True(t, cpu.WaitInput)
+	cpu.SetKey(0x2, false)
+	cpu.execFX0A(0xB)
+	assert.True(t, cpu.WaitInput)
+	assert.Equal(t, uint16(0x200), cpu.Register.PC)
+
+	// Pressing is not enough, the key is stored once released
+	cpu.SetKey(0x7, true)
+	cpu.execFX0A(0xB)
+	assert.True(t, cpu.WaitInput)
+	cpu.SetKey(0x5, true)
+	cpu.SetKey(0x7, false)
+	cpu.execFX0A(0xB)
+	assert.False(t, cpu.WaitInput)
+	assert.Equal(t, byte(0x7), cpu.Register.V[0xB])
+	assert.Equal(t, uint16(0x202), cpu.Register.PC)
+
+	// One press is read once: 5 was pressed during the previous wait and is still held
+	cpu.execFX0A(0xC)
+	cpu.SetKey(0x5, false)
+	cpu.execFX0A(0xC)
+	assert.True(t, cpu.WaitInput)
+	cpu.SetKey(0x5, true)
+	cpu.execFX0A(0xC)
+	cpu.SetKey(0x5, false)
+	cpu.execFX0A(0xC)
+	assert.False(t, cpu.WaitInput)
+	assert.Equal(t, byte(0x5), cpu.Register.V[0xC])
+	assert.Equal(t, uint16(0x204), cpu.Register.PC)
+}
+
+func TestExecFX0AKeyDown(t *testing.T) {
+	// Without the quirk a held key completes the wait at once, again and again
+	cpu := NewCPU()
+	cpu.SetKey(0x2, true)
+	for i := 0; i < 3; i++ {
+		cpu.execFX0A(0xB)
+		assert.False(t, cpu.WaitInput)
+	}
+	assert.Equal(t, byte(0x2), cpu.Register.V[0xB])
+	assert.Equal(t, uint16(0x206), cpu.Register.PC)
+}
+
+func TestExecEX9EAndEXA1KeySequence(t *testing.T) {
+	cpu := NewCPU()
+	// Only the low nibble of VX selects the key
+	cpu.Register.V[0x1] = 0x3A
+	skips := func() (bool, bool) {
+		pc := cpu.Register.PC
+		cpu.execEX9E(0x1)
+		pressedSkip := cpu.Register.PC-pc == 4
+		pc = cpu.Register.PC
+		cpu.execEXA1(0x1)
+		releasedSkip := cpu.Register.PC-pc == 4
+		return pressedSkip, releasedSkip
+	}
+	for _, step := range []struct {
+		key  byte
+		down bool
+	}{{0xA, true}, {0x3, true}, {0xA, false}, {0x3, false}, {0xA, true}} {
+		cpu.SetKey(step.key, step.down)
+		pressed, released := skips()
+		assert.Equal(t, cpu.KeyDown(0xA), pressed)
+		assert.Equal(t, !cpu.KeyDown(0xA), released)
+	}
+	assert.True(t, cpu.KeyDown(0xA))
+	assert.False(t, cpu.KeyDown(0x3))
+}
+
 func TestExecFX15(t *testing.T) {
 	cpu := NewCPU()
 	cpu.Register.V[0xB] = 0x6C
@@ -540,3 +643,410 @@ func TestExecFX65(t *testing.T) {
 	newCPU.Register.PC = 0x202
 	assert.Equal(t, newCPU, cpu)
 }
+
+func TestQuirkShiftUsesVY(t *testing.T) {
+	tests := []struct {
+		name    string
+		quirks  Quirks
+		opcode  uint16
+		wantVX  byte
+		wantVF  byte
+		execute func(cpu *CPU, x, y uint16)
+	}{
+		{"8XY6 in place", Quirks{}, 0x8AB6, 0b01010101, 0, func(cpu *CPU, x, y uint16) { cpu.exec8XY6(x, y) }},
+		{"8XY6 uses VY", Quirks{ShiftUsesVY: true}, 0x8AB6, 0b00001111, 1, func(cpu *CPU, x, y uint16) { cpu.exec8XY6(x, y) }},
+		{"8XYE in place", Quirks{}, 0x8ABE, 0b01010100, 1, func(cpu *CPU, x, y uint16) { cpu.exec8XYE(x, y) }},
+		{"8XYE uses VY", Quirks{ShiftUsesVY: true}, 0x8ABE, 0b00111110, 0, func(cpu *CPU, x, y uint16) { cpu.exec8XYE(x, y) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu := NewCPU()
+			cpu.Quirks = test.quirks
+			cpu.Register.V[0xA] = 0b10101010
+			cpu.Register.V[0xB] = 0b00011111
+			test.execute(&cpu, 0xA, 0xB)
+			assert.Equal(t, test.wantVX, cpu.Register.V[0xA])
+			assert.Equal(t, test.wantVF, cpu.Register.V[0xF])
+			assert.Equal(t, byte(0b00011111), cpu.Register.V[0xB])
+			assert.Equal(t, uint16(0x202), cpu.Register.PC)
+		})
+	}
+}
+
+func TestQuirkLoadStoreIncrementsI(t *testing.T) {
+	tests := []struct {
+		name    string
+		quirks  Quirks
+		wantI   uint16
+		execute func(cpu *CPU) error
+	}{
+		{"FX55 leaves I", Quirks{}, 0x300, func(cpu *CPU) error { return cpu.execFX55(0x2) }},
+		{"FX55 increments I", Quirks{LoadStoreIncrementsI: true}, 0x303, func(cpu *CPU) error { return cpu.execFX55(0x2) }},
+		{"FX65 leaves I", Quirks{}, 0x300, func(cpu *CPU) error { return cpu.execFX65(0x2) }},
+		{"FX65 increments I", Quirks{LoadStoreIncrementsI: true}, 0x303, func(cpu *CPU) error { return cpu.execFX65(0x2) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu := NewCPU()
+			cpu.Quirks = test.quirks
+			cpu.Register.I = 0x300
+			assert.Nil(t, test.execute(&cpu))
+			assert.Equal(t, test.wantI, cpu.Register.I)
+			assert.Equal(t, uint16(0x202), cpu.Register.PC)
+		})
+	}
+}
+
+func TestQuirkJumpUsesVX(t *testing.T) {
+	tests := []struct {
+		name   string
+		quirks Quirks
+		wantPC uint16
+	}{
+		{"BNNN uses V0", Quirks{}, 0x345 + 0x01},
+		{"BXNN uses VX", Quirks{JumpUsesVX: true}, 0x345 + 0x03},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu := NewCPU()
+			cpu.Quirks = test.quirks
+			cpu.Register.V[0x0] = 0x01
+			cpu.Register.V[0x3] = 0x03
+			cpu.execBNNN(0x345)
+			assert.Equal(t, test.wantPC, cpu.Register.PC)
+		})
+	}
+}
+
+func TestQuirkLogicResetsVF(t *testing.T) {
+	tests := []struct {
+		name    string
+		quirks  Quirks
+		wantVF  byte
+		execute func(cpu *CPU)
+	}{
+		{"8XY1 keeps VF", Quirks{}, 0x5, func(cpu *CPU) { cpu.exec8XY1(0xA, 0xB) }},
+		{"8XY1 resets VF", Quirks{LogicResetsVF: true}, 0x0, func(cpu *CPU) { cpu.exec8XY1(0xA, 0xB) }},
+		{"8XY2 keeps VF", Quirks{}, 0x5, func(cpu *CPU) { cpu.exec8XY2(0xA, 0xB) }},
+		{"8XY2 resets VF", Quirks{LogicResetsVF: true}, 0x0, func(cpu *CPU) { cpu.exec8XY2(0xA, 0xB) }},
+		{"8XY3 keeps VF", Quirks{}, 0x5, func(cpu *CPU) { cpu.exec8XY3(0xA, 0xB) }},
+		{"8XY3 resets VF", Quirks{LogicResetsVF: true}, 0x0, func(cpu *CPU) { cpu.exec8XY3(0xA, 0xB) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu := NewCPU()
+			cpu.Quirks = test.quirks
+			cpu.Register.V[0xF] = 0x5
+			test.execute(&cpu)
+			assert.Equal(t, test.wantVF, cpu.Register.V[0xF])
+		})
+	}
+}
+
+func TestQuirkClipSprites(t *testing.T) {
+	tests := []struct {
+		name        string
+		quirks      Quirks
+		wantWrapped byte
+	}{
+		{"DXYN wraps", Quirks{}, 0x01},
+		{"DXYN clips", Quirks{ClipSprites: true}, 0x00},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu := NewCPU()
+			cpu.Quirks = test.quirks
+			cpu.Register.I = 0x300
+			cpu.Memory.Memory[0x300] = 0x10
+			cpu.Memory.Memory[0x301] = 0x18
+			cpu.Register.V[0x3] = 63
+			cpu.Register.V[0xD] = 31
+			assert.Nil(t, cpu.execDXYN(0xD3D2))
+			assert.Equal(t, byte(0x00), cpu.Display[31][63])
+			assert.Equal(t, test.wantWrapped, cpu.Display[31][2])
+			assert.Equal(t, test.wantWrapped, cpu.Display[0][2])
+			assert.Equal(t, test.wantWrapped, cpu.Display[0][3])
+		})
+	}
+
+	// The starting position always wraps, even when clipping
+	cpu := NewCPU()
+	cpu.Quirks = Quirks{ClipSprites: true}
+	cpu.Register.I = 0x300
+	cpu.Memory.Memory[0x300] = 0x80
+	cpu.Register.V[0x3] = 64 + 5
+	cpu.Register.V[0xD] = 32 + 7
+	assert.Nil(t, cpu.execDXYN(0xD3D1))
+	assert.Equal(t, byte(0x01), cpu.Display[7][5])
+}
+
+func TestParseQuirks(t *testing.T) {
+	quirks, err := ParseQuirks("VIP")
+	assert.Nil(t, err)
+	assert.Equal(t, QuirksVIP, quirks)
+	quirks, err = ParseQuirks("xochip")
+	assert.Nil(t, err)
+	assert.Equal(t, QuirksXOCHIP, quirks)
+	quirks, err = ParseQuirks(DefaultQuirksPreset)
+	assert.Nil(t, err)
+	assert.Equal(t, QuirksOriginal, quirks)
+	cpu := NewCPU()
+	cpu.Quirks = quirks
+	assert.Equal(t, DefaultStackDepth, cpu.StackDepth())
+	assert.Equal(t, 4096, cpu.MemorySize())
+	_, err = ParseQuirks("null")
+	assert.NotNil(t, err)
+}
+
+func TestExec00CN(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Display[0][5] = 0x01
+	cpu.Display[30][5] = 0x01
+	cpu.exec00CN(0x2)
+	newCPU := NewCPU()
+	newCPU.Display[2][5] = 0x01
+	newCPU.NeedDraw = true
+	newCPU.Register.PC = 0x202
+	assert.Equal(t, newCPU, cpu)
+
+	cpu = NewCPU()
+	cpu.HiRes = true
+	cpu.Display[60][100] = 0x01
+	cpu.exec00CN(0x3)
+	newCPU = NewCPU()
+	newCPU.HiRes = true
+	newCPU.Display[63][100] = 0x01
+	newCPU.NeedDraw = true
+	newCPU.Register.PC = 0x202
+	assert.Equal(t, newCPU, cpu)
+}
+
+func TestExec00FB(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Display[3][0] = 0x01
+	cpu.Display[3][62] = 0x01
+	cpu.exec00FB()
+	newCPU := NewCPU()
+	newCPU.Display[3][4] = 0x01
+	newCPU.NeedDraw = true
+	newCPU.Register.PC = 0x202
+	assert.Equal(t, newCPU, cpu)
+}
+
+func TestExec00FC(t *testing.T) {
+	cpu := NewCPU()
+	cpu.HiRes = true
+	cpu.Display[3][2] = 0x01
+	cpu.Display[3][127] = 0x01
+	cpu.exec00FC()
+	newCPU := NewCPU()
+	newCPU.HiRes = true
+	newCPU.Display[3][123] = 0x01
+	newCPU.NeedDraw = true
+	newCPU.Register.PC = 0x202
+	assert.Equal(t, newCPU, cpu)
+}
+
+func TestExec00FD(t *testing.T) {
+	cpu := NewCPU()
+	cpu.exec00FD()
+	newCPU := NewCPU()
+	newCPU.Exited = true
+	assert.Equal(t, newCPU, cpu)
+}
+
+func TestExec00FEAnd00FF(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Display[1][1] = 0x01
+	cpu.exec00FF()
+	newCPU := NewCPU()
+	newCPU.HiRes = true
+	newCPU.NeedDraw = true
+	newCPU.Register.PC = 0x202
+	assert.Equal(t, newCPU, cpu)
+	width, height := cpu.DisplaySize()
+	assert.Equal(t, HiResDisplayWidth, width)
+	assert.Equal(t, HiResDisplayHeight, height)
+
+	cpu.Display[40][100] = 0x01
+	cpu.exec00FE()
+	newCPU.HiRes = false
+	newCPU.Register.PC = 0x204
+	assert.Equal(t, newCPU, cpu)
+	width, height = cpu.DisplaySize()
+	assert.Equal(t, DisplayWidth, width)
+	assert.Equal(t, DisplayHeight, height)
+}
+
+func TestExecDXY0(t *testing.T) {
+	cpu := NewCPU()
+	cpu.HiRes = true
+	cpu.Register.I = 0x300
+	cpu.Register.V[0x1] = 120
+	cpu.Register.V[0x2] = 10
+	cpu.Memory.Memory[0x300] = 0x80
+	cpu.Memory.Memory[0x301] = 0x01
+	cpu.Memory.Memory[0x31F] = 0x01
+	cpu.Display[10][120] = 0x01
+	assert.Nil(t, cpu.execDXYN(0xD120))
+	assert.Equal(t, byte(0x00), cpu.Display[10][120])
+	assert.Equal(t, byte(0x01), cpu.Display[10][7])
+	assert.Equal(t, byte(0x01), cpu.Display[25][7])
+	assert.Equal(t, byte(0x01), cpu.Register.V[0xF])
+	assert.Equal(t, uint16(0x202), cpu.Register.PC)
+}
+
+func TestExecFX30(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Register.V[0x3] = 0x9
+	cpu.execFX30(0x3)
+	newCPU := NewCPU()
+	newCPU.Register.I = 0x00AA
+	newCPU.Register.V[0x3] = 0x9
+	newCPU.Register.PC = 0x202
+	assert.Equal(t, newCPU, cpu)
+	assert.Equal(t, byte(0xFF), cpu.Memory.Memory[cpu.Register.I])
+}
+
+func TestExecFX75AndFX85(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Register.V[0x0] = 0x10
+	cpu.Register.V[0x1] = 0x18
+	cpu.Register.V[0x2] = 0xAB
+	cpu.execFX75(0x1)
+	newCPU := NewCPU()
+	newCPU.Register.V[0x0] = 0x10
+	newCPU.Register.V[0x1] = 0x18
+	newCPU.Register.V[0x2] = 0xAB
+	newCPU.Flags[0x0] = 0x10
+	newCPU.Flags[0x1] = 0x18
+	newCPU.Register.PC = 0x202
+	assert.Equal(t, newCPU, cpu)
+
+	cpu.Register.V[0x0] = 0x00
+	cpu.Register.V[0x1] = 0x00
+	cpu.execFX85(0x1)
+	newCPU.Register.PC = 0x204
+	assert.Equal(t, newCPU, cpu)
+}
+
+func TestExec00DN(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Display[0][5] = 0x01
+	cpu.Display[3][5] = 0x03
+	cpu.Plane = 0x01
+	cpu.exec00DN(0x2)
+	newCPU := NewCPU()
+	newCPU.Display[1][5] = 0x01
+	newCPU.Display[3][5] = 0x02
+	newCPU.NeedDraw = true
+	newCPU.Register.PC = 0x202
+	assert.Equal(t, newCPU, cpu)
+}
+
+func TestExec5XY2(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Register.I = 0x300
+	cpu.Register.V[0x2] = 0x10
+	cpu.Register.V[0x3] = 0x18
+	cpu.Register.V[0x4] = 0xAB
+	assert.Nil(t, cpu.exec5XY2(0x2, 0x4))
+	assert.Equal(t, []byte{0x10, 0x18, 0xAB, 0x00}, cpu.Memory.Memory[0x300:0x304])
+	assert.Equal(t, uint16(0x300), cpu.Register.I)
+	assert.Equal(t, uint16(0x202), cpu.Register.PC)
+
+	// Registers are stored in reverse order when X is greater than Y
+	assert.Nil(t, cpu.exec5XY2(0x4, 0x2))
+	assert.Equal(t, []byte{0xAB, 0x18, 0x10, 0x00}, cpu.Memory.Memory[0x300:0x304])
+	assert.Equal(t, uint16(0x204), cpu.Register.PC)
+}
+
+func TestExec5XY3(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Register.I = 0x300
+	cpu.Memory.Memory[0x300] = 0x10
+	cpu.Memory.Memory[0x301] = 0x18
+	cpu.Memory.Memory[0x302] = 0xAB
+	assert.Nil(t, cpu.exec5XY3(0x2, 0x4))
+	assert.Equal(t, [3]byte{0x10, 0x18, 0xAB}, [3]byte{cpu.Register.V[0x2], cpu.Register.V[0x3], cpu.Register.V[0x4]})
+	assert.Equal(t, uint16(0x300), cpu.Register.I)
+	assert.Equal(t, uint16(0x202), cpu.Register.PC)
+
+	assert.Nil(t, cpu.exec5XY3(0x4, 0x2))
+	assert.Equal(t, [3]byte{0xAB, 0x18, 0x10}, [3]byte{cpu.Register.V[0x2], cpu.Register.V[0x3], cpu.Register.V[0x4]})
+	assert.Equal(t, byte(0x00), cpu.Register.V[0x5])
+}
+
+func TestExecF000(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Memory.Memory[0x200] = 0xF0
+	cpu.Memory.Memory[0x201] = 0x00
+	cpu.Memory.Memory[0x202] = 0xAB
+	cpu.Memory.Memory[0x203] = 0xCD
+	assert.Nil(t, cpu.Cycle())
+	assert.Equal(t, uint16(0xABCD), cpu.Register.I)
+	assert.Equal(t, uint16(0x204), cpu.Register.PC)
+
+	// Skip instructions step over the whole 4-byte instruction
+	cpu = NewCPU()
+	cpu.Memory.Memory[0x202] = 0xF0
+	cpu.Memory.Memory[0x203] = 0x00
+	cpu.exec3XNN(0x0, 0x00)
+	assert.Equal(t, uint16(0x206), cpu.Register.PC)
+}
+
+func TestExecFN01(t *testing.T) {
+	cpu := NewCPU()
+	cpu.execFN01(0x3)
+	newCPU := NewCPU()
+	newCPU.Plane = 0x3
+	newCPU.Register.PC = 0x202
+	assert.Equal(t, newCPU, cpu)
+}
+
+func TestExecDXYNPlanes(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Plane = 0x3
+	cpu.Register.I = 0x300
+	cpu.Memory.Memory[0x300] = 0xC0
+	cpu.Memory.Memory[0x301] = 0xA0
+	cpu.Display[0][1] = 0x02
+	cpu.Display[0][2] = 0x02
+	assert.Nil(t, cpu.execDXYN(0xD001))
+	assert.Equal(t, byte(0x03), cpu.Display[0][0])
+	assert.Equal(t, byte(0x03), cpu.Display[0][1])
+	assert.Equal(t, byte(0x00), cpu.Display[0][2])
+	assert.Equal(t, byte(0x01), cpu.Register.V[0xF])
+
+	cpu = NewCPU()
+	cpu.Plane = 0x2
+	cpu.Register.I = 0x300
+	cpu.Memory.Memory[0x300] = 0x80
+	cpu.Display[0][0] = 0x01
+	assert.Nil(t, cpu.execDXYN(0xD001))
+	assert.Equal(t, byte(0x03), cpu.Display[0][0])
+	assert.Equal(t, byte(0x00), cpu.Register.V[0xF])
+}
+
+func TestExecF002(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Register.I = 0x300
+	for i := 0; i < 16; i++ {
+		cpu.Memory.Memory[0x300+i] = byte(i)
+	}
+	assert.Nil(t, cpu.execF002())
+	assert.Equal(t, [16]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, cpu.AudioPattern)
+	assert.Equal(t, uint16(0x300), cpu.Register.I)
+	assert.Equal(t, uint16(0x202), cpu.Register.PC)
+}
+
+func TestExecFX3A(t *testing.T) {
+	cpu := NewCPU()
+	cpu.Register.V[0x3] = 0x70
+	cpu.execFX3A(0x3)
+	newCPU := NewCPU()
+	newCPU.Register.V[0x3] = 0x70
+	newCPU.Pitch = 0x70
+	newCPU.Register.PC = 0x202
+	assert.Equal(t, newCPU, cpu)
+}
@@ -0,0 +1,71 @@
+// Package romtest runs the ROMs bundled in roms/ for the tests and benchmarks of the chip8 packages.
+package romtest
+
+import (
+	"GoCHIP-8/chip8"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// Paths returns the ROMs bundled in roms/, from the test of any package
+func Paths(tb testing.TB) []string {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		tb.Fatal("romtest: no source path")
+	}
+	dir := filepath.Join(filepath.Dir(file), "..", "..", "..", "roms")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == "" {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return paths
+}
+
+// NewCPU returns a CPU running the ROM at path with the VIP quirks and a fixed seed
+func NewCPU(tb testing.TB, path string) chip8.CPU {
+	cpu := chip8.NewCPU()
+	cpu.Quirks = chip8.QuirksVIP
+	cpu.SetSeed(7)
+	if err := cpu.LoadROM(path); err != nil {
+		tb.Fatal(err)
+	}
+	return cpu
+}
+
+// Benchmark runs b.N frames of every ROM with frame, on a CPU passed to setup first when it is not nil.
+// It reports the instructions executed per second and how many times faster than real time the frames ran,
+// the benchmark fails when a ROM runs less than minRealTime times faster than real time.
+func Benchmark(b *testing.B, minRealTime float64, setup func(cpu *chip8.CPU), frame func(cpu *chip8.CPU) error) {
+	for _, path := range Paths(b) {
+		b.Run(filepath.Base(path), func(b *testing.B) {
+			cpu := NewCPU(b, path)
+			if setup != nil {
+				setup(&cpu)
+			}
+			b.ResetTimer()
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				if err := frame(&cpu); err != nil {
+					b.Fatal(err)
+				}
+			}
+			seconds := time.Since(start).Seconds()
+			realTime := float64(b.N) / chip8.TimerFrequency / seconds
+			b.ReportMetric(float64(b.N*cpu.InstructionsPerFrame)/seconds, "instructions/s")
+			b.ReportMetric(realTime, "x-realtime")
+			// The first runs with few frames only size b.N
+			if b.N >= chip8.TimerFrequency && realTime < minRealTime {
+				b.Errorf("%s ran %.1f times faster than real time, expected %.0f", filepath.Base(path), realTime, minRealTime)
+			}
+		})
+	}
+}
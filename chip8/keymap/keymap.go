@@ -0,0 +1,193 @@
+// Package keymap maps host keys, by name, to the 16 keys of the CHIP-8 keypad.
+//
+// A keymap file is a JSON object from CHIP-8 keys as hex digits to lists of host key names,
+// like {"1": ["1", "Up"], "4": ["Q", "Down"]}. Several host keys can press the same CHIP-8 key,
+// while a host key presses at most one CHIP-8 key. Key names are compared case-insensitively.
+package keymap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Keypad lists the CHIP-8 keys in the order of the keypad, row by row
+var Keypad = []byte{0x1, 0x2, 0x3, 0xC, 0x4, 0x5, 0x6, 0xD, 0x7, 0x8, 0x9, 0xE, 0xA, 0x0, 0xB, 0xF}
+
+// Keymap holds the host key names of every CHIP-8 key
+type Keymap [16][]string
+
+// Default maps the left of a QWERTY keyboard to the keypad:
+//
+//	       Chip-8                       Keyboard
+//	+────+────+────+────+       +────+────+────+────+
+//	| 1  | 2  | 3  | C  |       | 1  | 2  | 3  | 4  |
+//	+────+────+────+────+       +────+────+────+────+
+//	| 4  | 5  | 6  | D  |       | Q  | W  | E  | R  |
+//	+────+────+────+────+  <=>  +────+────+────+────+
+//	| 7  | 8  | 9  | E  |       | A  | S  | D  | F  |
+//	+────+────+────+────+       +────+────+────+────+
+//	| A  | 0  | B  | F  |       | Z  | X  | C  | V  |
+//	+────+────+────+────+       +────+────+────+────+
+func Default() Keymap {
+	var k Keymap
+	for i, name := range []string{"1", "2", "3", "4", "Q", "W", "E", "R", "A", "S", "D", "F", "Z", "X", "C", "V"} {
+		k[Keypad[i]] = []string{name}
+	}
+	return k
+}
+
+// Parse reads a keymap file, CHIP-8 keys missing from it have no host key
+func Parse(r io.Reader) (Keymap, error) {
+	var entries map[string][]string
+	decoder := json.NewDecoder(r)
+	if err := decoder.Decode(&entries); err != nil {
+		return Keymap{}, fmt.Errorf("invalid keymap, expected an object like {\"1\": [\"1\", \"Up\"]}: %w", err)
+	}
+	var k Keymap
+	// Sort the entries so the same file always reports the same error
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := strconv.ParseUint(key, 16, 4)
+		if err != nil {
+			return Keymap{}, fmt.Errorf("invalid CHIP-8 key %q, expected a hex digit 0-F", key)
+		}
+		if k[value] != nil {
+			return Keymap{}, fmt.Errorf("CHIP-8 key %X is mapped twice", value)
+		}
+		k[value] = []string{}
+		for _, name := range entries[key] {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return Keymap{}, fmt.Errorf("empty host key name for CHIP-8 key %X", value)
+			}
+			k[value] = append(k[value], name)
+		}
+	}
+	return k, k.checkDuplicates()
+}
+
+// Load reads the keymap file at path
+func Load(path string) (Keymap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Keymap{}, err
+	}
+	defer f.Close()
+	k, err := Parse(f)
+	if err != nil {
+		return Keymap{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return k, nil
+}
+
+// checkDuplicates reports a host key mapped to two CHIP-8 keys
+func (k Keymap) checkDuplicates() error {
+	seen := make(map[string]byte)
+	for key, names := range k {
+		for _, name := range names {
+			if other, ok := seen[strings.ToLower(name)]; ok {
+				if other == byte(key) {
+					return fmt.Errorf("host key %s is listed twice for CHIP-8 key %X", name, key)
+				}
+				return fmt.Errorf("host key %s is mapped to both CHIP-8 keys %X and %X", name, other, key)
+			}
+			seen[strings.ToLower(name)] = byte(key)
+		}
+	}
+	return nil
+}
+
+// Merge returns k with the CHIP-8 keys mapped by override replaced. Host keys of override are removed
+// from the other CHIP-8 keys of k, so a host key still presses a single CHIP-8 key.
+func (k Keymap) Merge(override Keymap) Keymap {
+	var merged Keymap
+	for key := range k {
+		if override[key] != nil {
+			merged[key] = append([]string{}, override[key]...)
+			continue
+		}
+		for _, name := range k[key] {
+			if _, ok := override.Lookup(name); !ok {
+				merged[key] = append(merged[key], name)
+			}
+		}
+	}
+	return merged
+}
+
+// Lookup returns the CHIP-8 key a host key is mapped to
+func (k Keymap) Lookup(name string) (byte, bool) {
+	for key, names := range k {
+		for _, n := range names {
+			if strings.EqualFold(n, name) {
+				return byte(key), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Bind adds a host key to a CHIP-8 key, the host key is removed from the other CHIP-8 keys
+func (k *Keymap) Bind(key byte, name string) {
+	for i := range k {
+		kept := k[i][:0:0]
+		for _, n := range k[i] {
+			if !strings.EqualFold(n, name) {
+				kept = append(kept, n)
+			}
+		}
+		k[i] = kept
+	}
+	k[key&0xF] = append(k[key&0xF], name)
+}
+
+// Check verifies every host key name with known, and that no name is reserved. Reserved maps
+// lowercase names of the keys used by the emulator itself to what they do.
+func (k Keymap) Check(known func(name string) bool, reserved map[string]string) error {
+	for key, names := range k {
+		for _, name := range names {
+			if !known(name) {
+				return fmt.Errorf("unknown host key %q for CHIP-8 key %X", name, key)
+			}
+			if action, ok := reserved[strings.ToLower(name)]; ok {
+				return fmt.Errorf("host key %s for CHIP-8 key %X is reserved to %s", name, key, action)
+			}
+		}
+	}
+	return nil
+}
+
+// Write writes the keymap as JSON, CHIP-8 keys without host key are left out
+func (k Keymap) Write(w io.Writer) error {
+	entries := make(map[string][]string)
+	for key, names := range k {
+		if len(names) > 0 {
+			entries[fmt.Sprintf("%X", key)] = names
+		}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// Save writes the keymap to a file at path
+func (k Keymap) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := k.Write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
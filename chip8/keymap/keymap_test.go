@@ -0,0 +1,86 @@
+package keymap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefault(t *testing.T) {
+	k := Default()
+	key, ok := k.Lookup("q")
+	assert.True(t, ok)
+	assert.Equal(t, byte(0x4), key)
+	key, _ = k.Lookup("V")
+	assert.Equal(t, byte(0xF), key)
+	_, ok = k.Lookup("P")
+	assert.False(t, ok)
+}
+
+func TestParse(t *testing.T) {
+	k, err := Parse(strings.NewReader(`{"1": ["Up", "w"], "c": ["Space"]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Up", "w"}, k[0x1])
+	assert.Equal(t, []string{"Space"}, k[0xC])
+	assert.Nil(t, k[0x4])
+
+	for text, message := range map[string]string{
+		`["1"]`:                         "invalid keymap",
+		`{"G": ["A"]}`:                  `invalid CHIP-8 key "G", expected a hex digit 0-F`,
+		`{"10": ["A"]}`:                 `invalid CHIP-8 key "10"`,
+		`{"a": ["A"], "A": ["B"]}`:      "CHIP-8 key A is mapped twice",
+		`{"1": ["W"], "5": ["w"]}`:      "host key w is mapped to both CHIP-8 keys 1 and 5",
+		`{"1": ["W", "W"]}`:             "host key W is listed twice for CHIP-8 key 1",
+		`{"1": [" "]}`:                  "empty host key name for CHIP-8 key 1",
+		`{"1": ["W"], "2": "not list"}`: "invalid keymap",
+	} {
+		_, err := Parse(strings.NewReader(text))
+		if assert.NotNil(t, err, text) {
+			assert.Contains(t, err.Error(), message)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	// PONG plays with the arrows and W/S, W is taken from CHIP-8 key 5
+	rom, err := Parse(strings.NewReader(`{"1": ["W", "Up"], "4": ["S", "Down"]}`))
+	assert.Nil(t, err)
+	merged := Default().Merge(rom)
+	assert.Equal(t, []string{"W", "Up"}, merged[0x1])
+	assert.Equal(t, []string{"S", "Down"}, merged[0x4])
+	assert.Nil(t, merged[0x5])
+	assert.Nil(t, merged[0x8])
+	assert.Equal(t, []string{"E"}, merged[0x6])
+	assert.Nil(t, merged.checkDuplicates())
+}
+
+func TestBindAndWrite(t *testing.T) {
+	k := Default()
+	k.Bind(0x5, "Up")
+	k.Bind(0x8, "q")
+	assert.Equal(t, []string{"W", "Up"}, k[0x5])
+	assert.Equal(t, []string{"S", "q"}, k[0x8])
+	assert.Empty(t, k[0x4])
+
+	var buf bytes.Buffer
+	assert.Nil(t, k.Write(&buf))
+	read, err := Parse(&buf)
+	assert.Nil(t, err)
+	k[0x4] = nil
+	assert.Equal(t, k, read)
+}
+
+func TestCheck(t *testing.T) {
+	known := func(name string) bool { return len(name) == 1 || name == "Up" }
+	reserved := map[string]string{"p": "pause"}
+	assert.Nil(t, Default().Check(known, reserved))
+
+	k := Default()
+	k[0x1] = nil
+	k.Bind(0x1, "Upp")
+	assert.EqualError(t, k.Check(known, reserved), `unknown host key "Upp" for CHIP-8 key 1`)
+	k[0x1] = []string{"P"}
+	assert.EqualError(t, k.Check(known, reserved), "host key P for CHIP-8 key 1 is reserved to pause")
+}
@@ -0,0 +1,48 @@
+package chip8
+
+// SetKey presses or releases a key. Hosts set the keys through SetKey or SetKeys so that the presses and
+// releases are seen by FX0A, writing KeyState directly only changes what EX9E and EXA1 see.
+func (cpu *CPU) SetKey(key byte, down bool) {
+	key &= 0xF
+	bit := uint16(1) << key
+	if down && cpu.KeyState[key] == 0 {
+		// Only a release following the press completes FX0A
+		cpu.KeyPresses |= bit
+		cpu.KeyReleases &^= bit
+	} else if !down && cpu.KeyState[key] != 0 {
+		cpu.KeyReleases |= bit
+	}
+	if down {
+		cpu.KeyState[key] = 0x01
+	} else {
+		cpu.KeyState[key] = 0x00
+	}
+}
+
+// SetKeys sets the state of all the keys at once
+func (cpu *CPU) SetKeys(keys [16]bool) {
+	for key, down := range keys {
+		cpu.SetKey(byte(key), down)
+	}
+}
+
+// KeyDown reports whether a key is held, only the low nibble of key is used like on the COSMAC VIP
+func (cpu *CPU) KeyDown(key byte) bool {
+	return cpu.KeyState[key&0xF] != 0
+}
+
+// waitKey returns the key completing FX0A: the first key held, or with the KeyWaitsForRelease quirk
+// the first key pressed and then released since FX0A started waiting
+func (cpu *CPU) waitKey() (byte, bool) {
+	for key := range cpu.KeyState {
+		bit := uint16(1) << key
+		if cpu.Quirks.KeyWaitsForRelease {
+			if cpu.KeyPresses&cpu.KeyReleases&bit != 0 {
+				return byte(key), true
+			}
+		} else if cpu.KeyState[key] != 0 {
+			return byte(key), true
+		}
+	}
+	return 0, false
+}
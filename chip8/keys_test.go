@@ -0,0 +1,62 @@
+package chip8
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetKey(t *testing.T) {
+	cpu := NewCPU()
+	cpu.SetKey(0x3, true)
+	cpu.SetKey(0x3, true)
+	assert.True(t, cpu.KeyDown(0x3))
+	assert.True(t, cpu.KeyDown(0x13))
+	assert.Equal(t, uint16(1<<0x3), cpu.KeyPresses)
+	assert.Equal(t, uint16(0), cpu.KeyReleases)
+
+	cpu.SetKey(0x3, false)
+	cpu.SetKey(0xA, false)
+	assert.False(t, cpu.KeyDown(0x3))
+	assert.Equal(t, uint16(1<<0x3), cpu.KeyReleases)
+
+	cpu.SetKeys([16]bool{0x0: true, 0xF: true})
+	assert.Equal(t, [16]byte{0x0: 1, 0xF: 1}, cpu.KeyState)
+	assert.Equal(t, uint16(1<<0x0|1<<0x3|1<<0xF), cpu.KeyPresses)
+
+	// Pressing again forgets the previous release
+	cpu.SetKey(0x3, true)
+	assert.Equal(t, uint16(0), cpu.KeyReleases)
+
+	cpu.Reset()
+	assert.Equal(t, uint16(0), cpu.KeyPresses)
+	assert.Equal(t, uint16(0), cpu.KeyReleases)
+}
+
+func TestWaitKeyProgram(t *testing.T) {
+	// FX0A waits in place without running the previous instruction again
+	cpu := NewCPU()
+	cpu.Quirks = QuirksVIP
+	copy(cpu.Memory.Memory[0x200:], []byte{
+		0x71, 0x01, // V[1] += 1
+		0xF0, 0x0A, // V[0] = key
+		0x72, 0x01, // V[2] += 1
+	})
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, cpu.Cycle())
+	}
+	assert.Equal(t, uint16(0x202), cpu.Register.PC)
+	assert.Equal(t, byte(1), cpu.Register.V[1])
+	assert.True(t, cpu.WaitInput)
+
+	cpu.SetKey(0x9, true)
+	assert.Nil(t, cpu.Cycle())
+	assert.Equal(t, uint16(0x202), cpu.Register.PC)
+	cpu.SetKey(0x9, false)
+	assert.Nil(t, cpu.Cycle())
+	assert.Nil(t, cpu.Cycle())
+	assert.Equal(t, uint16(0x206), cpu.Register.PC)
+	assert.Equal(t, byte(0x9), cpu.Register.V[0])
+	assert.Equal(t, byte(1), cpu.Register.V[2])
+	assert.False(t, cpu.WaitInput)
+}
@@ -1,9 +1,81 @@
 package chip8
 
-import "io/ioutil"
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// PageSize is the size of the pages of memory write protected by Memory.Protect
+const PageSize = 256
+
+// AccessPolicy selects what happens when an instruction accesses memory past the end of the address space
+type AccessPolicy byte
+
+const (
+	// AccessFault stops execution with ErrMemoryOutOfBounds
+	AccessFault AccessPolicy = iota
+	// AccessWrap wraps addresses around the end of the address space, like the address bus of the hardware
+	AccessWrap
+	// AccessLog passes the fault to Memory.Log and continues, reads return 0 and writes are dropped
+	AccessLog
+)
+
+var accessPolicies = map[string]AccessPolicy{
+	"fault": AccessFault,
+	"wrap":  AccessWrap,
+	"log":   AccessLog,
+}
+
+// ParseAccessPolicy returns the access policy with the given name: fault, wrap or log
+func ParseAccessPolicy(name string) (AccessPolicy, error) {
+	policy, ok := accessPolicies[strings.ToLower(name)]
+	if !ok {
+		return AccessFault, fmt.Errorf("unknown memory access policy: %s", name)
+	}
+	return policy, nil
+}
+
+// Protection selects the areas of memory write protected by CPU.Protect
+type Protection byte
+
+const (
+	// ProtectInterpreter protects 0x000-0x1FF, the area of the interpreter and the fonts
+	ProtectInterpreter Protection = 1 << iota
+	// ProtectROM protects the pages holding the ROM, to catch programs overwriting their own code
+	ProtectROM
+)
+
+// ParseProtection parses a comma separated list of the areas to protect: interpreter, rom, or none
+func ParseProtection(names string) (Protection, error) {
+	var protection Protection
+	for _, name := range strings.Split(names, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "", "none":
+		case "interpreter":
+			protection |= ProtectInterpreter
+		case "rom":
+			protection |= ProtectROM
+		default:
+			return 0, fmt.Errorf("unknown memory area to protect: %s, expected interpreter or rom", name)
+		}
+	}
+	return protection, nil
+}
 
 type Memory struct {
-	Memory [4096]byte
+	// 64 KiB of XO-CHIP address space, classic CHIP-8 programs only use the first 4 KiB
+	Memory [65536]byte
+	// Policy applies to the instructions accessing memory past the address space of the quirks,
+	// and writes to protected pages fault unless it is AccessLog
+	Policy AccessPolicy
+	// Log is called with the faults ignored by AccessLog when not nil
+	Log func(err error)
+	// ROMSize is the length of the ROM loaded by LoadROM
+	ROMSize   int
+	protected [65536 / PageSize]bool
+	// Areas protected by CPU.Protect, protected again when a ROM is loaded
+	protection Protection
 }
 
 var fontSet = [...]byte{
@@ -25,14 +97,40 @@ var fontSet = [...]byte{
 	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
 }
 
+// Large 8x10 font used by the SUPER-CHIP FX30 instruction, stored right after fontSet
+var bigFontSet = [...]byte{
+	0xFF, 0xFF, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, // 0
+	0x18, 0x78, 0x78, 0x18, 0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, // 1
+	0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // 2
+	0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 3
+	0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0x03, 0x03, // 4
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 5
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 6
+	0xFF, 0xFF, 0x03, 0x03, 0x06, 0x0C, 0x18, 0x18, 0x18, 0x18, // 7
+	0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 8
+	0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 9
+	0x7E, 0xFF, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xC3, // A
+	0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, // B
+	0x3C, 0xFF, 0xC3, 0xC0, 0xC0, 0xC0, 0xC0, 0xC3, 0xFF, 0x3C, // C
+	0xFC, 0xFE, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFE, 0xFC, // D
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // E
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xC0, 0xC0, // F
+}
+
+const bigFontAddr = len(fontSet)
+
 func (memory *Memory) LoadROM(romPath string) error {
 	rom, err := ioutil.ReadFile(romPath)
 	if err != nil {
 		return err
 	}
+	if 0x200+len(rom) > len(memory.Memory) {
+		return ErrMemoryOutOfBounds{Addr: 0x200 + len(rom) - 1}
+	}
 	for i := 0; i < len(rom); i++ {
 		memory.Memory[0x200+i] = rom[i]
 	}
+	memory.ROMSize = len(rom)
 	return nil
 }
 
@@ -40,4 +138,132 @@ func (memory *Memory) LoadFontSet() {
 	for i := 0x00; i < 0x50; i++ {
 		memory.Memory[i] = fontSet[i]
 	}
+	for i := 0; i < len(bigFontSet); i++ {
+		memory.Memory[bigFontAddr+i] = bigFontSet[i]
+	}
+}
+
+// Read returns the byte stored at addr
+func (memory *Memory) Read(addr int) (byte, error) {
+	if addr < 0 || addr >= len(memory.Memory) {
+		return 0, ErrMemoryOutOfBounds{Addr: addr}
+	}
+	return memory.Memory[addr], nil
+}
+
+// Write stores value at addr
+func (memory *Memory) Write(addr int, value byte) error {
+	if addr < 0 || addr >= len(memory.Memory) {
+		return ErrMemoryOutOfBounds{Addr: addr}
+	}
+	memory.Memory[addr] = value
+	return nil
+}
+
+// Protect write protects the pages overlapping length bytes at addr
+func (memory *Memory) Protect(addr, length int) {
+	for page := addr / PageSize; page <= (addr+length-1)/PageSize && page < len(memory.protected); page++ {
+		memory.protected[page] = true
+	}
+}
+
+// Unprotect removes the write protection of every page and of the areas of CPU.Protect
+func (memory *Memory) Unprotect() {
+	memory.protected = [len(memory.protected)]bool{}
+	memory.protection = 0
+}
+
+// Protected reports whether addr is in a write protected page
+func (memory *Memory) Protected(addr int) bool {
+	return addr >= 0 && addr < len(memory.Memory) && memory.protected[addr/PageSize]
+}
+
+// MemorySize returns the length of the address space selected by Quirks.AddressBits
+func (cpu *CPU) MemorySize() int {
+	bits := cpu.Quirks.AddressBits
+	if bits == 0 || bits > 16 {
+		bits = 16
+	}
+	return 1 << bits
+}
+
+// Protect write protects the areas of memory. The areas are kept across resets, the pages of the ROM
+// follow the ROM loaded by LoadROM.
+func (cpu *CPU) Protect(protection Protection) {
+	cpu.Memory.protection |= protection
+	cpu.protectAreas()
+}
+
+// protectAreas protects the pages of the areas of CPU.Protect, and only them
+func (cpu *CPU) protectAreas() {
+	cpu.Memory.protected = [len(cpu.Memory.protected)]bool{}
+	if cpu.Memory.protection&ProtectInterpreter != 0 {
+		cpu.Memory.Protect(0, 0x200)
+	}
+	if cpu.Memory.protection&ProtectROM != 0 && cpu.Memory.ROMSize > 0 {
+		cpu.Memory.Protect(0x200, cpu.Memory.ROMSize)
+	}
+}
+
+// address applies the access policy to an address accessed by an instruction. The address is
+// ignored when ok is false, after the fault was logged.
+func (cpu *CPU) address(addr int) (int, bool, error) {
+	size := cpu.MemorySize()
+	if addr >= 0 && addr < size {
+		return addr, true, nil
+	}
+	switch cpu.Memory.Policy {
+	case AccessWrap:
+		return addr & (size - 1), true, nil
+	case AccessLog:
+		cpu.Memory.log(ErrMemoryOutOfBounds{Addr: addr})
+		return 0, false, nil
+	}
+	return 0, false, ErrMemoryOutOfBounds{Addr: addr}
+}
+
+// readMemory returns the byte an instruction reads at addr
+func (cpu *CPU) readMemory(addr int) (byte, error) {
+	addr, ok, err := cpu.address(addr)
+	if !ok {
+		return 0, err
+	}
+	if cpu.Bus != nil {
+		return cpu.Bus.Read8(uint16(addr), ReadAccess), nil
+	}
+	return cpu.Memory.Memory[addr], nil
+}
+
+// writeMemory stores the byte an instruction writes at addr
+func (cpu *CPU) writeMemory(addr int, value byte) error {
+	addr, ok, err := cpu.address(addr)
+	if !ok {
+		return err
+	}
+	if cpu.Memory.protected[addr/PageSize] {
+		err := ErrWriteProtected{PC: cpu.Register.PC, Addr: addr}
+		if cpu.Memory.Policy != AccessLog {
+			return err
+		}
+		cpu.Memory.log(err)
+		return nil
+	}
+	if cpu.cache != nil {
+		cpu.cache.invalidate(addr)
+	}
+	if cpu.trace != nil {
+		cpu.trace.Memory = append(cpu.trace.Memory, MemoryChange{uint16(addr), cpu.Memory.Memory[addr], value})
+	}
+	if cpu.Bus != nil {
+		cpu.Bus.Write8(uint16(addr), value, WriteAccess)
+		return nil
+	}
+	cpu.Memory.Memory[addr] = value
+	return nil
+}
+
+func (memory *Memory) log(err error) {
+	if memory.Log != nil {
+		memory.Log(err)
+	}
 }
@@ -0,0 +1,126 @@
+package chip8
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// newMemoryTestCPU runs the VIP quirks, with 4 KiB of memory, and stores V0-V3 at I
+func newMemoryTestCPU(policy AccessPolicy, i uint16) *CPU {
+	cpu := NewCPU()
+	cpu.Quirks = QuirksVIP
+	cpu.Memory.Policy = policy
+	cpu.Register.I = i
+	cpu.Register.V = [16]byte{0x11, 0x22, 0x33, 0x44}
+	copy(cpu.Memory.Memory[0x200:], []byte{0xF3, 0x55})
+	return &cpu
+}
+
+func TestAccessPolicy(t *testing.T) {
+	assert.Equal(t, 4096, newMemoryTestCPU(AccessFault, 0).MemorySize())
+	cpu := NewCPU()
+	assert.Equal(t, 65536, cpu.MemorySize())
+
+	cpu = *newMemoryTestCPU(AccessFault, 0xFFE)
+	err := cpu.Cycle()
+	assert.Equal(t, ErrMemoryOutOfBounds{Addr: 0x1000}, err)
+	assert.Equal(t, uint16(0x200), cpu.Register.PC)
+
+	cpu = *newMemoryTestCPU(AccessWrap, 0xFFE)
+	assert.Nil(t, cpu.Cycle())
+	assert.Equal(t, []byte{0x11, 0x22}, cpu.Memory.Memory[0xFFE:0x1000])
+	assert.Equal(t, []byte{0x33, 0x44}, cpu.Memory.Memory[0x000:0x002])
+	assert.Equal(t, byte(0x00), cpu.Memory.Memory[0x1000])
+
+	cpu = *newMemoryTestCPU(AccessLog, 0xFFE)
+	var logged []error
+	cpu.Memory.Log = func(err error) { logged = append(logged, err) }
+	assert.Nil(t, cpu.Cycle())
+	assert.Equal(t, []byte{0x11, 0x22}, cpu.Memory.Memory[0xFFE:0x1000])
+	assert.Equal(t, []error{ErrMemoryOutOfBounds{Addr: 0x1000}, ErrMemoryOutOfBounds{Addr: 0x1001}}, logged)
+	assert.Equal(t, uint16(0x202), cpu.Register.PC)
+
+	// Instructions are fetched across the end of memory only when wrapping
+	cpu = *newMemoryTestCPU(AccessLog, 0)
+	cpu.Register.PC = 0xFFF
+	assert.Equal(t, ErrMemoryOutOfBounds{Addr: 0x1000}, cpu.Cycle())
+	cpu = *newMemoryTestCPU(AccessWrap, 0)
+	cpu.Memory.Memory[0xFFF] = 0x60
+	cpu.Memory.Memory[0x000] = 0x12
+	cpu.Register.PC = 0xFFF
+	assert.Nil(t, cpu.Cycle())
+	assert.Equal(t, byte(0x12), cpu.Register.V[0])
+
+	for name, policy := range map[string]AccessPolicy{"fault": AccessFault, "Wrap": AccessWrap, "log": AccessLog} {
+		parsed, err := ParseAccessPolicy(name)
+		assert.Nil(t, err)
+		assert.Equal(t, policy, parsed)
+	}
+	_, err = ParseAccessPolicy("ignore")
+	assert.NotNil(t, err)
+}
+
+func TestProtect(t *testing.T) {
+	cpu := NewCPU()
+	assert.Nil(t, cpu.LoadROM("../roms/PONG"))
+	cpu.Protect(ProtectInterpreter | ProtectROM)
+	assert.True(t, cpu.Memory.Protected(0x000))
+	assert.True(t, cpu.Memory.Protected(0x1FF))
+	// PONG is 246 bytes long, in the first page after the interpreter
+	assert.True(t, cpu.Memory.Protected(0x2FF))
+	assert.False(t, cpu.Memory.Protected(0x300))
+
+	// The ROM pages follow the loaded ROM, BLINKY is 2356 bytes long
+	assert.Nil(t, cpu.LoadROM("../roms/BLINKY"))
+	assert.True(t, cpu.Memory.Protected(0xB33))
+	assert.False(t, cpu.Memory.Protected(0xC00))
+	assert.Nil(t, cpu.LoadROM("../roms/PONG"))
+	assert.False(t, cpu.Memory.Protected(0x300))
+
+	// Reset keeps the interpreter protected and the ROM pages writable until a ROM is loaded
+	assert.Nil(t, cpu.LoadROM("../roms/BLINKY"))
+	cpu.Reset()
+	assert.True(t, cpu.Memory.Protected(0x1FF))
+	assert.False(t, cpu.Memory.Protected(0x200))
+	assert.False(t, cpu.Memory.Protected(0xB33))
+
+	// FX33 at 0x200 stores the digits of V0 at 0x1FE
+	assert.Nil(t, cpu.LoadROM("../roms/PONG"))
+	copy(cpu.Memory.Memory[0x200:], []byte{0xF0, 0x33})
+	cpu.Register.I = 0x1FE
+	err := cpu.Cycle()
+	var protected ErrWriteProtected
+	assert.True(t, errors.As(err, &protected))
+	assert.Equal(t, ErrWriteProtected{PC: 0x200, Addr: 0x1FE}, protected)
+	assert.EqualError(t, err, "write to protected memory at 1FE by the instruction at 200")
+
+	cpu.Register.I = 0x300
+	assert.Nil(t, cpu.Cycle())
+
+	// Protected writes are dropped when logging, 0x1FF to 0x201 are all protected
+	var logged []error
+	cpu.Memory.Policy = AccessLog
+	cpu.Memory.Log = func(err error) { logged = append(logged, err) }
+	cpu.Register.PC = 0x200
+	cpu.Register.V[0] = 123
+	cpu.Register.I = 0x1FF
+	assert.Nil(t, cpu.Cycle())
+	assert.Equal(t, []byte{0x00, 0xF0, 0x33}, cpu.Memory.Memory[0x1FF:0x202])
+	assert.Len(t, logged, 3)
+	assert.Equal(t, uint16(0x202), cpu.Register.PC)
+
+	cpu.Memory.Unprotect()
+	assert.False(t, cpu.Memory.Protected(0x000))
+	cpu.Reset()
+	assert.False(t, cpu.Memory.Protected(0x000))
+
+	protection, err := ParseProtection("interpreter, ROM")
+	assert.Nil(t, err)
+	assert.Equal(t, ProtectInterpreter|ProtectROM, protection)
+	protection, err = ParseProtection("none")
+	assert.Nil(t, err)
+	assert.Equal(t, Protection(0), protection)
+	_, err = ParseProtection("font")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,213 @@
+// Package movie records the input of a CHIP-8 session frame by frame and plays it back. Along with the
+// input, a movie keeps the seed of the random numbers, the quirks and the clock speed, and a hash of the
+// display and registers at the end of every frame, so playback can tell the first frame that went differently.
+//
+// Movies are JSON documents. They are recorded and played from the start of a ROM.
+package movie
+
+import (
+	"GoCHIP-8/chip8"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// Version of the movie format, bumped whenever the meaning of a movie changes
+const Version = 1
+
+// Event presses or releases a CHIP-8 key before a frame is executed
+type Event struct {
+	Frame   int  `json:"frame"`
+	Key     byte `json:"key"`
+	Pressed bool `json:"pressed"`
+}
+
+// Movie is a recorded session
+type Movie struct {
+	Version int `json:"version"`
+	// ROM is the SHA-1 of the ROM in hex
+	ROM                  string       `json:"rom"`
+	Seed                 int64        `json:"seed"`
+	Quirks               chip8.Quirks `json:"quirks"`
+	InstructionsPerFrame int          `json:"instructionsPerFrame"`
+	// Input are the key changes in the order of the frames
+	Input []Event `json:"input"`
+	// Hashes of the display and registers at the end of every frame, see Hash
+	Hashes []uint32 `json:"hashes"`
+}
+
+// Frames returns the number of frames of the movie
+func (m *Movie) Frames() int {
+	return len(m.Hashes)
+}
+
+// HashROM returns the SHA-1 of a ROM in hex
+func HashROM(rom []byte) string {
+	sum := sha1.Sum(rom)
+	return hex.EncodeToString(sum[:])
+}
+
+// Hash returns the CRC-32 of the display and the registers of cpu
+func Hash(cpu *chip8.CPU) uint32 {
+	h := crc32.NewIEEE()
+	for i := range cpu.Display {
+		_, _ = h.Write(cpu.Display[i][:])
+	}
+	_ = binary.Write(h, binary.BigEndian, &cpu.Register)
+	return h.Sum32()
+}
+
+// Read reads a movie written by Write
+func Read(r io.Reader) (*Movie, error) {
+	var m Movie
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("unsupported movie version %d", m.Version)
+	}
+	for i, e := range m.Input {
+		if e.Key > 0xF {
+			return nil, fmt.Errorf("input %d: invalid key %d", i, e.Key)
+		}
+		if i > 0 && e.Frame < m.Input[i-1].Frame {
+			return nil, fmt.Errorf("input %d: frame %d is before the previous input", i, e.Frame)
+		}
+	}
+	return &m, nil
+}
+
+// Write writes the movie as JSON
+func (m *Movie) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(m)
+}
+
+// Load reads the movie file at path
+func Load(path string) (*Movie, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Save writes the movie to a file at path
+func (m *Movie) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := m.Write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ErrDesync is the first frame played back whose display or registers differ from the recording
+type ErrDesync struct {
+	Frame          int
+	Expected, Hash uint32
+}
+
+func (e ErrDesync) Error() string {
+	return fmt.Sprintf("desync at frame %d: expected hash %08x, got %08x", e.Frame, e.Expected, e.Hash)
+}
+
+// Recorder records a movie. StartFrame and EndFrame are called around every frame executed.
+type Recorder struct {
+	Movie *Movie
+	// Key state of the previous frame
+	keys [16]byte
+}
+
+// NewRecorder starts recording a movie of rom run by cpu, with the seed, quirks and clock speed of cpu
+func NewRecorder(cpu *chip8.CPU, rom []byte) *Recorder {
+	return &Recorder{Movie: &Movie{
+		Version:              Version,
+		ROM:                  HashROM(rom),
+		Seed:                 cpu.Seed,
+		Quirks:               cpu.Quirks,
+		InstructionsPerFrame: cpu.InstructionsPerFrame,
+		Input:                []Event{},
+		Hashes:               []uint32{},
+	}}
+}
+
+// StartFrame records the keys pressed or released since the previous frame
+func (r *Recorder) StartFrame(cpu *chip8.CPU) {
+	frame := r.Movie.Frames()
+	for key, state := range cpu.KeyState {
+		if state != r.keys[key] {
+			r.Movie.Input = append(r.Movie.Input, Event{Frame: frame, Key: byte(key), Pressed: state != 0})
+		}
+	}
+	r.keys = cpu.KeyState
+}
+
+// EndFrame records the hash of the frame
+func (r *Recorder) EndFrame(cpu *chip8.CPU) {
+	r.Movie.Hashes = append(r.Movie.Hashes, Hash(cpu))
+}
+
+// Player plays a movie back. StartFrame and EndFrame are called around every frame executed.
+type Player struct {
+	Movie *Movie
+	frame int
+	// Next input event
+	next int
+}
+
+// NewPlayer checks the movie was recorded with rom and sets the seed, quirks and clock speed of cpu
+func NewPlayer(m *Movie, cpu *chip8.CPU, rom []byte) (*Player, error) {
+	if hash := HashROM(rom); hash != m.ROM {
+		return nil, fmt.Errorf("movie recorded with ROM %s, not %s", m.ROM, hash)
+	}
+	cpu.SetSeed(m.Seed)
+	cpu.Quirks = m.Quirks
+	if m.InstructionsPerFrame > 0 {
+		cpu.InstructionsPerFrame = m.InstructionsPerFrame
+	}
+	return &Player{Movie: m}, nil
+}
+
+// Frame returns the number of frames played
+func (p *Player) Frame() int {
+	return p.frame
+}
+
+// Done reports whether all frames were played
+func (p *Player) Done() bool {
+	return p.frame >= p.Movie.Frames()
+}
+
+// StartFrame sets the key state of cpu for the next frame
+func (p *Player) StartFrame(cpu *chip8.CPU) {
+	input := p.Movie.Input
+	for ; p.next < len(input) && input[p.next].Frame <= p.frame; p.next++ {
+		cpu.SetKey(input[p.next].Key, input[p.next].Pressed)
+	}
+}
+
+// EndFrame checks the frame against the recording, an ErrDesync is returned when it differs
+func (p *Player) EndFrame(cpu *chip8.CPU) error {
+	frame := p.frame
+	p.frame++
+	if frame >= p.Movie.Frames() {
+		return nil
+	}
+	if hash := Hash(cpu); hash != p.Movie.Hashes[frame] {
+		return ErrDesync{Frame: frame, Expected: p.Movie.Hashes[frame], Hash: hash}
+	}
+	return nil
+}
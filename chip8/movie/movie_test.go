@@ -0,0 +1,102 @@
+package movie
+
+import (
+	"GoCHIP-8/chip8"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// play runs frames frames of rom, calling start and end around every frame
+func play(t *testing.T, rom []byte, frames int, start, end func(cpu *chip8.CPU, frame int) error) (*chip8.CPU, error) {
+	cpu := chip8.NewCPU()
+	copy(cpu.Memory.Memory[0x200:], rom)
+	for frame := 0; frame < frames; frame++ {
+		if err := start(&cpu, frame); err != nil {
+			return &cpu, err
+		}
+		assert.Nil(t, cpu.Run())
+		if err := end(&cpu, frame); err != nil {
+			return &cpu, err
+		}
+	}
+	return &cpu, nil
+}
+
+func TestRecordAndPlay(t *testing.T) {
+	rom, err := ioutil.ReadFile("../../roms/BRIX")
+	assert.Nil(t, err)
+
+	// Hold 4 then 6 to move the paddle while the ball bounces randomly
+	cpu := chip8.NewCPU()
+	cpu.SetSeed(99)
+	cpu.Quirks = chip8.QuirksVIP
+	recorder := NewRecorder(&cpu, rom)
+	copy(cpu.Memory.Memory[0x200:], rom)
+	for frame := 0; frame < 300; frame++ {
+		cpu.SetKey(0x4, frame%100 < 40)
+		cpu.SetKey(0x6, frame%100 > 60)
+		recorder.StartFrame(&cpu)
+		assert.Nil(t, cpu.Run())
+		recorder.EndFrame(&cpu)
+	}
+	m := recorder.Movie
+	assert.Equal(t, 300, m.Frames())
+	assert.Equal(t, []Event{{0, 4, true}, {40, 4, false}, {61, 6, true}, {100, 4, true}, {100, 6, false}}, m.Input[:5])
+
+	var buf bytes.Buffer
+	assert.Nil(t, m.Write(&buf))
+	loaded, err := Read(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, m, loaded)
+
+	var player *Player
+	replayed, err := play(t, rom, 300, func(cpu *chip8.CPU, frame int) error {
+		if frame == 0 {
+			player, err = NewPlayer(loaded, cpu, rom)
+			assert.Nil(t, err)
+		}
+		player.StartFrame(cpu)
+		return nil
+	}, func(cpu *chip8.CPU, frame int) error {
+		return player.EndFrame(cpu)
+	})
+	assert.Nil(t, err)
+	assert.True(t, player.Done())
+	assert.Equal(t, cpu, *replayed)
+
+	// A different seed makes the ball bounce differently, the first frame that differs is reported
+	loaded.Seed = 100
+	_, err = play(t, rom, 300, func(cpu *chip8.CPU, frame int) error {
+		if frame == 0 {
+			player, _ = NewPlayer(loaded, cpu, rom)
+		}
+		player.StartFrame(cpu)
+		return nil
+	}, func(cpu *chip8.CPU, frame int) error {
+		return player.EndFrame(cpu)
+	})
+	var desync ErrDesync
+	assert.True(t, errors.As(err, &desync))
+	assert.Greater(t, desync.Frame, 0)
+	assert.Equal(t, m.Hashes[desync.Frame], desync.Expected)
+
+	_, err = NewPlayer(m, &cpu, rom[1:])
+	assert.NotNil(t, err)
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, text := range []string{
+		`{"version":2}`,
+		`{"version":1,"input":[{"frame":0,"key":16,"pressed":true}]}`,
+		`{"version":1,"input":[{"frame":5,"key":1,"pressed":true},{"frame":4,"key":1,"pressed":false}]}`,
+		`not json`,
+	} {
+		_, err := Read(strings.NewReader(text))
+		assert.NotNil(t, err, text)
+	}
+}
@@ -0,0 +1,175 @@
+package chip8
+
+// Op identifies the instruction encoded by an opcode, it is shared by CPU.Cycle and the disassembler
+// so that both always agree on how an opcode is decoded
+type Op byte
+
+const (
+	OpUnknown Op = iota
+	Op00CN
+	Op00DN
+	Op00E0
+	Op00EE
+	Op00FB
+	Op00FC
+	Op00FD
+	Op00FE
+	Op00FF
+	Op1NNN
+	Op2NNN
+	Op3XNN
+	Op4XNN
+	Op5XY0
+	Op5XY2
+	Op5XY3
+	Op6XNN
+	Op7XNN
+	Op8XY0
+	Op8XY1
+	Op8XY2
+	Op8XY3
+	Op8XY4
+	Op8XY5
+	Op8XY6
+	Op8XY7
+	Op8XYE
+	Op9XY0
+	OpANNN
+	OpBNNN
+	OpCXNN
+	OpDXYN
+	OpEX9E
+	OpEXA1
+	OpF000
+	OpFN01
+	OpF002
+	OpFX07
+	OpFX0A
+	OpFX15
+	OpFX18
+	OpFX1E
+	OpFX29
+	OpFX30
+	OpFX33
+	OpFX3A
+	OpFX55
+	OpFX65
+	OpFX75
+	OpFX85
+)
+
+type opcodeInfo struct {
+	op      Op
+	mask    uint16
+	pattern uint16
+	name    string
+}
+
+var opcodeTable = [...]opcodeInfo{
+	{Op00CN, 0xFFF0, 0x00C0, "00CN"},
+	{Op00DN, 0xFFF0, 0x00D0, "00DN"},
+	{Op00E0, 0xFFFF, 0x00E0, "00E0"},
+	{Op00EE, 0xFFFF, 0x00EE, "00EE"},
+	{Op00FB, 0xFFFF, 0x00FB, "00FB"},
+	{Op00FC, 0xFFFF, 0x00FC, "00FC"},
+	{Op00FD, 0xFFFF, 0x00FD, "00FD"},
+	{Op00FE, 0xFFFF, 0x00FE, "00FE"},
+	{Op00FF, 0xFFFF, 0x00FF, "00FF"},
+	{Op1NNN, 0xF000, 0x1000, "1NNN"},
+	{Op2NNN, 0xF000, 0x2000, "2NNN"},
+	{Op3XNN, 0xF000, 0x3000, "3XNN"},
+	{Op4XNN, 0xF000, 0x4000, "4XNN"},
+	{Op5XY0, 0xF00F, 0x5000, "5XY0"},
+	{Op5XY2, 0xF00F, 0x5002, "5XY2"},
+	{Op5XY3, 0xF00F, 0x5003, "5XY3"},
+	{Op6XNN, 0xF000, 0x6000, "6XNN"},
+	{Op7XNN, 0xF000, 0x7000, "7XNN"},
+	{Op8XY0, 0xF00F, 0x8000, "8XY0"},
+	{Op8XY1, 0xF00F, 0x8001, "8XY1"},
+	{Op8XY2, 0xF00F, 0x8002, "8XY2"},
+	{Op8XY3, 0xF00F, 0x8003, "8XY3"},
+	{Op8XY4, 0xF00F, 0x8004, "8XY4"},
+	{Op8XY5, 0xF00F, 0x8005, "8XY5"},
+	{Op8XY6, 0xF00F, 0x8006, "8XY6"},
+	{Op8XY7, 0xF00F, 0x8007, "8XY7"},
+	{Op8XYE, 0xF00F, 0x800E, "8XYE"},
+	{Op9XY0, 0xF00F, 0x9000, "9XY0"},
+	{OpANNN, 0xF000, 0xA000, "ANNN"},
+	{OpBNNN, 0xF000, 0xB000, "BNNN"},
+	{OpCXNN, 0xF000, 0xC000, "CXNN"},
+	{OpDXYN, 0xF000, 0xD000, "DXYN"},
+	{OpEX9E, 0xF0FF, 0xE09E, "EX9E"},
+	{OpEXA1, 0xF0FF, 0xE0A1, "EXA1"},
+	{OpF000, 0xFFFF, 0xF000, "F000"},
+	{OpFN01, 0xF0FF, 0xF001, "FN01"},
+	{OpF002, 0xFFFF, 0xF002, "F002"},
+	{OpFX07, 0xF0FF, 0xF007, "FX07"},
+	{OpFX0A, 0xF0FF, 0xF00A, "FX0A"},
+	{OpFX15, 0xF0FF, 0xF015, "FX15"},
+	{OpFX18, 0xF0FF, 0xF018, "FX18"},
+	{OpFX1E, 0xF0FF, 0xF01E, "FX1E"},
+	{OpFX29, 0xF0FF, 0xF029, "FX29"},
+	{OpFX30, 0xF0FF, 0xF030, "FX30"},
+	{OpFX33, 0xF0FF, 0xF033, "FX33"},
+	{OpFX3A, 0xF0FF, 0xF03A, "FX3A"},
+	{OpFX55, 0xF0FF, 0xF055, "FX55"},
+	{OpFX65, 0xF0FF, 0xF065, "FX65"},
+	{OpFX75, 0xF0FF, 0xF075, "FX75"},
+	{OpFX85, 0xF0FF, 0xF085, "FX85"},
+}
+
+// Lookup table from every possible opcode to its Op, built from opcodeTable
+var opcodeOps [0x10000]Op
+
+func init() {
+	for opcode := 0; opcode < len(opcodeOps); opcode++ {
+		for _, info := range opcodeTable {
+			if uint16(opcode)&info.mask == info.pattern {
+				opcodeOps[opcode] = info.op
+				break
+			}
+		}
+	}
+}
+
+// String returns the opcode pattern of the instruction, like DXYN
+func (op Op) String() string {
+	for _, info := range opcodeTable {
+		if info.op == op {
+			return info.name
+		}
+	}
+	return "????"
+}
+
+// Instruction is a decoded opcode with its operands
+type Instruction struct {
+	Op     Op
+	Opcode uint16
+	X      uint16
+	Y      uint16
+	N      uint16
+	NN     byte
+	NNN    uint16
+}
+
+// Decode decodes an opcode, Op is OpUnknown when the opcode is not a valid instruction
+func Decode(opcode uint16) Instruction {
+	return Instruction{
+		Op:     opcodeOps[opcode],
+		Opcode: opcode,
+		X:      (opcode & 0x0F00) >> 8,
+		Y:      (opcode & 0x00F0) >> 4,
+		N:      opcode & 0x000F,
+		NN:     byte(opcode & 0x00FF),
+		NNN:    opcode & 0x0FFF,
+	}
+}
+
+// Size returns the length of the instruction in bytes, F000 NNNN is followed by a 16-bit address
+func (instruction Instruction) Size() uint16 {
+	if instruction.Op == OpF000 {
+		return 4
+	}
+	return 2
+}
@@ -0,0 +1,23 @@
+package chip8
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	instruction := Decode(0xD3A5)
+	assert.Equal(t, Instruction{Op: OpDXYN, Opcode: 0xD3A5, X: 0x3, Y: 0xA, N: 0x5, NN: 0xA5, NNN: 0x3A5}, instruction)
+	assert.Equal(t, "DXYN", instruction.Op.String())
+	assert.Equal(t, uint16(2), instruction.Size())
+
+	assert.Equal(t, Op00E0, Decode(0x00E0).Op)
+	assert.Equal(t, OpUnknown, Decode(0x01E0).Op)
+	assert.Equal(t, Op5XY2, Decode(0x5122).Op)
+	assert.Equal(t, OpUnknown, Decode(0x5121).Op)
+	assert.Equal(t, OpFN01, Decode(0xF301).Op)
+	assert.Equal(t, OpF000, Decode(0xF000).Op)
+	assert.Equal(t, uint16(4), Decode(0xF000).Size())
+	assert.Equal(t, OpUnknown, Decode(0xF100).Op)
+	assert.Equal(t, "????", OpUnknown.String())
+}
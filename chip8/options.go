@@ -0,0 +1,51 @@
+package chip8
+
+// Options configure a CPU by name, like the command line flags of the emulator. The zero value
+// runs the default quirks preset at the default speed, with faulting and unprotected memory.
+type Options struct {
+	// Quirks preset, DefaultQuirksPreset when empty
+	Quirks string
+	// Clock speed in Hz, DefaultInstructionsPerFrame are executed per frame when 0
+	Clock int
+	// Seed of the random numbers of CXNN
+	Seed int64
+	// Policy of the memory accesses past the end of memory, fault when empty
+	Memory string
+	// Comma separated memory areas to write protect, see ParseProtection
+	Protect string
+	// Receives the memory faults ignored by the log policy
+	Log func(err error)
+}
+
+// NewCPU returns a CPU set up with the options, running the ROM at romPath
+func (options Options) NewCPU(romPath string) (CPU, error) {
+	cpu := NewCPU()
+	cpu.SetSeed(options.Seed)
+	preset := options.Quirks
+	if preset == "" {
+		preset = DefaultQuirksPreset
+	}
+	var err error
+	if cpu.Quirks, err = ParseQuirks(preset); err != nil {
+		return cpu, err
+	}
+	if options.Clock > 0 {
+		cpu.InstructionsPerFrame = InstructionsPerFrame(options.Clock)
+	}
+	if err := cpu.LoadROM(romPath); err != nil {
+		return cpu, err
+	}
+	if options.Memory != "" {
+		if cpu.Memory.Policy, err = ParseAccessPolicy(options.Memory); err != nil {
+			return cpu, err
+		}
+	}
+	cpu.Memory.Log = options.Log
+	protection, err := ParseProtection(options.Protect)
+	if err != nil {
+		return cpu, err
+	}
+	// The ROM pages are known once it is loaded
+	cpu.Protect(protection)
+	return cpu, nil
+}
@@ -0,0 +1,42 @@
+package chip8
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOptions_NewCPU(t *testing.T) {
+	cpu, err := Options{}.NewCPU("../roms/PONG")
+	assert.Nil(t, err)
+	assert.Equal(t, QuirksOriginal, cpu.Quirks)
+	assert.Equal(t, DefaultInstructionsPerFrame, cpu.InstructionsPerFrame)
+	assert.Equal(t, AccessFault, cpu.Memory.Policy)
+	assert.False(t, cpu.Memory.Protected(0x200))
+	assert.Equal(t, 246, cpu.Memory.ROMSize)
+
+	var logged []error
+	cpu, err = Options{
+		Quirks:  "vip",
+		Clock:   600,
+		Seed:    42,
+		Memory:  "log",
+		Protect: "rom",
+		Log:     func(err error) { logged = append(logged, err) },
+	}.NewCPU("../roms/PONG")
+	assert.Nil(t, err)
+	assert.Equal(t, QuirksVIP, cpu.Quirks)
+	assert.Equal(t, 10, cpu.InstructionsPerFrame)
+	assert.Equal(t, int64(42), cpu.Seed)
+	assert.Equal(t, AccessLog, cpu.Memory.Policy)
+	assert.True(t, cpu.Memory.Protected(0x200))
+	assert.False(t, cpu.Memory.Protected(0x000))
+	cpu.Memory.log(ErrMemoryOutOfBounds{Addr: 0x1000})
+	assert.Equal(t, []error{ErrMemoryOutOfBounds{Addr: 0x1000}}, logged)
+
+	for _, options := range []Options{{Quirks: "null"}, {Memory: "ignore"}, {Protect: "font"}} {
+		_, err = options.NewCPU("../roms/PONG")
+		assert.NotNil(t, err, "%+v", options)
+	}
+	_, err = Options{}.NewCPU("../roms/MISSING")
+	assert.NotNil(t, err)
+}
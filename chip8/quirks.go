@@ -0,0 +1,86 @@
+package chip8
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Quirks selects between the interpretations of instructions whose behavior differs across CHIP-8 implementations.
+// The zero value keeps the interpreter's original behavior.
+type Quirks struct {
+	// 8XY6/8XYE: VX is set to VY before shifting instead of shifting VX in place
+	ShiftUsesVY bool
+	// FX55/FX65: I is incremented by X + 1 after storing or loading instead of being left unmodified
+	LoadStoreIncrementsI bool
+	// BNNN: jump to XNN plus VX (BXNN) instead of NNN plus V0
+	JumpUsesVX bool
+	// 8XY1/8XY2/8XY3: VF is reset to 0 after the logic operation
+	LogicResetsVF bool
+	// DXYN: sprites are clipped at the edges of the screen instead of wrapping around
+	ClipSprites bool
+	// FX0A: the key is stored once it is pressed and released instead of as soon as a key is held
+	KeyWaitsForRelease bool
+	// 2NNN: number of nested subroutine calls before a stack overflow, 0 for DefaultStackDepth
+	StackDepth byte
+	// Width of memory addresses, memory is 1 << AddressBits bytes long, 0 for the 64 KiB of XO-CHIP
+	AddressBits byte
+}
+
+// DefaultQuirksPreset is the quirks preset used when none is chosen
+const DefaultQuirksPreset = "original"
+
+var (
+	// QuirksOriginal keeps the behavior of GoCHIP-8 before the quirks were configurable, with 4 KiB of memory
+	QuirksOriginal = Quirks{
+		AddressBits: 12,
+	}
+	// QuirksVIP matches the original COSMAC VIP interpreter
+	QuirksVIP = Quirks{
+		ShiftUsesVY:          true,
+		LoadStoreIncrementsI: true,
+		LogicResetsVF:        true,
+		ClipSprites:          true,
+		KeyWaitsForRelease:   true,
+		StackDepth:           12,
+		AddressBits:          12,
+	}
+	// QuirksCHIP48 matches the CHIP-48 interpreter for the HP-48 calculators
+	QuirksCHIP48 = Quirks{
+		JumpUsesVX:  true,
+		ClipSprites: true,
+		StackDepth:  16,
+		AddressBits: 12,
+	}
+	// QuirksSCHIP matches SUPER-CHIP 1.1
+	QuirksSCHIP = Quirks{
+		JumpUsesVX:  true,
+		ClipSprites: true,
+		StackDepth:  16,
+		AddressBits: 12,
+	}
+	// QuirksXOCHIP matches Octo's XO-CHIP
+	QuirksXOCHIP = Quirks{
+		ShiftUsesVY:          true,
+		LoadStoreIncrementsI: true,
+		KeyWaitsForRelease:   true,
+		StackDepth:           MaxStackDepth,
+		AddressBits:          16,
+	}
+)
+
+var quirksPresets = map[string]Quirks{
+	"original": QuirksOriginal,
+	"vip":      QuirksVIP,
+	"chip48":   QuirksCHIP48,
+	"schip":    QuirksSCHIP,
+	"xochip":   QuirksXOCHIP,
+}
+
+// ParseQuirks returns the quirks preset with the given name: original, vip, chip48, schip or xochip
+func ParseQuirks(name string) (Quirks, error) {
+	quirks, ok := quirksPresets[strings.ToLower(name)]
+	if !ok {
+		return Quirks{}, fmt.Errorf("unknown quirks preset: %s", name)
+	}
+	return quirks, nil
+}
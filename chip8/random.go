@@ -0,0 +1,40 @@
+package chip8
+
+// Random is the source of the random numbers of CXNN
+type Random interface {
+	// Byte returns a random byte, all 256 values being equally likely
+	Byte() byte
+}
+
+// Rand is the default Random, a SplitMix64 generator. Its state is a single number kept in save states,
+// so a restored machine draws the same numbers as the one that was saved.
+type Rand struct {
+	state uint64
+}
+
+// NewRand returns a generator seeded with seed, the same seed always yields the same numbers
+func NewRand(seed int64) *Rand {
+	r := &Rand{}
+	r.Seed(seed)
+	return r
+}
+
+// Seed restarts the generator from seed
+func (r *Rand) Seed(seed int64) {
+	r.state = uint64(seed)
+}
+
+// Byte returns the top byte of the next number
+func (r *Rand) Byte() byte {
+	r.state += 0x9E3779B97F4A7C15
+	z := r.state
+	z = (z ^ z>>30) * 0xBF58476D1CE4E5B9
+	z = (z ^ z>>27) * 0x94D049BB133111EB
+	return byte((z ^ z>>31) >> 56)
+}
+
+// SetSeed seeds the CPU with a new default generator, the seed is recorded in save states
+func (cpu *CPU) SetSeed(seed int64) {
+	cpu.Seed = seed
+	cpu.Random = NewRand(seed)
+}
@@ -0,0 +1,54 @@
+package chip8
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRand(t *testing.T) {
+	r := NewRand(1)
+	var seen [256]int
+	var first []byte
+	for i := 0; i < 256*64; i++ {
+		b := r.Byte()
+		seen[b]++
+		if i < 8 {
+			first = append(first, b)
+		}
+	}
+	// Every byte comes out, 255 included
+	for value, count := range seen {
+		assert.Greater(t, count, 0, value)
+	}
+
+	r.Seed(1)
+	for _, b := range first {
+		assert.Equal(t, b, r.Byte())
+	}
+	other := NewRand(2)
+	same := true
+	for _, b := range first {
+		same = same && b == other.Byte()
+	}
+	assert.False(t, same)
+}
+
+func TestCPU_SetSeed(t *testing.T) {
+	run := func(seed int64) CPU {
+		cpu := NewCPU()
+		cpu.SetSeed(seed)
+		_ = cpu.LoadROM("../roms/PONG")
+		for i := 0; i < 300; i++ {
+			assert.Nil(t, cpu.Run())
+		}
+		return cpu
+	}
+	assert.Equal(t, run(7), run(7))
+
+	// Reset restarts the generator from the seed
+	cpu := NewCPU()
+	cpu.SetSeed(7)
+	first := cpu.Random.Byte()
+	cpu.Reset()
+	assert.Equal(t, first, cpu.Random.Byte())
+}
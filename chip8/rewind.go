@@ -0,0 +1,140 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// RewindBuffer keeps a history of machine states to step gameplay backwards.
+// Only the newest save state is kept in full, older ones are stored as run-length encoded XOR deltas
+// against the state that followed them, so a frame usually costs a few hundred bytes.
+type RewindBuffer struct {
+	latest []byte
+	// Ring buffer of deltas, the newest one restores the state before latest
+	deltas [][]byte
+	start  int
+	count  int
+}
+
+var errCorruptDelta = errors.New("corrupt rewind delta")
+
+// NewRewindBuffer creates a rewind buffer holding up to frames states
+func NewRewindBuffer(frames int) *RewindBuffer {
+	if frames < 1 {
+		frames = 1
+	}
+	return &RewindBuffer{deltas: make([][]byte, frames-1)}
+}
+
+// Len returns the number of frames that can be rewound
+func (rewind *RewindBuffer) Len() int {
+	return rewind.count
+}
+
+// Reset drops the whole history
+func (rewind *RewindBuffer) Reset() {
+	rewind.latest = nil
+	for i := range rewind.deltas {
+		rewind.deltas[i] = nil
+	}
+	rewind.start = 0
+	rewind.count = 0
+}
+
+// Push records the current state of the CPU, dropping the oldest frame when the buffer is full
+func (rewind *RewindBuffer) Push(cpu *CPU) error {
+	var buf bytes.Buffer
+	if err := cpu.SaveState(&buf); err != nil {
+		return err
+	}
+	state := buf.Bytes()
+	if rewind.latest != nil && len(rewind.deltas) > 0 {
+		delta := encodeDelta(state, rewind.latest)
+		if rewind.count == len(rewind.deltas) {
+			rewind.deltas[rewind.start] = delta
+			rewind.start = (rewind.start + 1) % len(rewind.deltas)
+		} else {
+			rewind.deltas[(rewind.start+rewind.count)%len(rewind.deltas)] = delta
+			rewind.count++
+		}
+	}
+	rewind.latest = state
+	return nil
+}
+
+// Rewind restores the CPU to the frame before the newest one and drops the newest one.
+// It returns false when there is no older frame.
+func (rewind *RewindBuffer) Rewind(cpu *CPU) (bool, error) {
+	if rewind.count == 0 {
+		return false, nil
+	}
+	index := (rewind.start + rewind.count - 1) % len(rewind.deltas)
+	previous, err := decodeDelta(rewind.latest, rewind.deltas[index])
+	if err != nil {
+		return false, err
+	}
+	if err := cpu.LoadState(bytes.NewReader(previous)); err != nil {
+		return false, err
+	}
+	rewind.deltas[index] = nil
+	rewind.count--
+	rewind.latest = previous
+	return true, nil
+}
+
+// encodeDelta XORs two states of the same length and run-length encodes the result
+// as pairs of unchanged byte count and changed bytes
+func encodeDelta(state, base []byte) []byte {
+	var delta []byte
+	var varint [binary.MaxVarintLen64]byte
+	for i := 0; i < len(state); {
+		same := i
+		for same < len(state) && state[same] == base[same] {
+			same++
+		}
+		if same == len(state) {
+			break
+		}
+		changed := same
+		for changed < len(state) && state[changed] != base[changed] {
+			changed++
+		}
+		delta = append(delta, varint[:binary.PutUvarint(varint[:], uint64(same-i))]...)
+		delta = append(delta, varint[:binary.PutUvarint(varint[:], uint64(changed-same))]...)
+		for j := same; j < changed; j++ {
+			delta = append(delta, state[j]^base[j])
+		}
+		i = changed
+	}
+	return delta
+}
+
+// decodeDelta applies a delta created by encodeDelta to state
+func decodeDelta(state, delta []byte) ([]byte, error) {
+	base := append([]byte(nil), state...)
+	r := bytes.NewReader(delta)
+	for i := 0; r.Len() > 0; {
+		same, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, errCorruptDelta
+		}
+		changed, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, errCorruptDelta
+		}
+		i += int(same)
+		if i+int(changed) > len(base) {
+			return nil, errCorruptDelta
+		}
+		for j := 0; j < int(changed); j++ {
+			value, err := r.ReadByte()
+			if err != nil {
+				return nil, errCorruptDelta
+			}
+			base[i] ^= value
+			i++
+		}
+	}
+	return base, nil
+}
@@ -0,0 +1,55 @@
+package chip8
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRewindBuffer(t *testing.T) {
+	cpu := NewCPU()
+	_ = cpu.LoadROM("../roms/BRIX")
+	rewind := NewRewindBuffer(10)
+	var history []CPU
+	for i := 0; i < 20; i++ {
+		assert.Nil(t, cpu.Run())
+		cpu.NeedDraw = true
+		assert.Nil(t, rewind.Push(&cpu))
+		history = append(history, cpu)
+	}
+	// The newest frame is the current one, so 9 older frames can be restored
+	assert.Equal(t, 9, rewind.Len())
+	for i := 18; i >= 10; i-- {
+		ok, err := rewind.Rewind(&cpu)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, history[i], cpu)
+	}
+	ok, err := rewind.Rewind(&cpu)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, history[10], cpu)
+
+	// Recording continues from the rewound frame
+	assert.Nil(t, cpu.Run())
+	assert.Nil(t, rewind.Push(&cpu))
+	ok, err = rewind.Rewind(&cpu)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, history[10], cpu)
+
+	rewind.Reset()
+	assert.Equal(t, 0, rewind.Len())
+}
+
+func TestEncodeDelta(t *testing.T) {
+	base := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+	state := []byte{0, 1, 9, 9, 4, 5, 6, 8}
+	delta := encodeDelta(state, base)
+	decoded, err := decodeDelta(state, delta)
+	assert.Nil(t, err)
+	assert.Equal(t, base, decoded)
+	assert.Empty(t, encodeDelta(base, base))
+
+	_, err = decodeDelta(state, []byte{0x7F, 0x01})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,72 @@
+package sound
+
+import (
+	"GoCHIP-8/chip8"
+	"math"
+)
+
+// PatternBits is the length of the XO-CHIP audio pattern
+const PatternBits = 128
+
+// PatternRate returns the rate in bits per second at which the audio pattern is played at a pitch,
+// 4000 Hz at the default pitch of 64 and an octave higher every 48 steps
+func PatternRate(pitch byte) float64 {
+	return 4000 * math.Pow(2, (float64(pitch)-64)/48)
+}
+
+// Pattern is an Oscillator playing the 1-bit audio pattern of XO-CHIP in a loop, most significant bit first
+type Pattern struct {
+	Pattern [16]byte
+	Pitch   byte
+	// Position in the pattern in bits, kept when the pattern or the pitch change so swaps don't click
+	position float64
+}
+
+// Sample returns the bit at the current position as 1 or -1, a pattern without any bit set is silent
+func (p *Pattern) Sample(sampleRate int) float64 {
+	if p.Pattern == [16]byte{} {
+		return 0
+	}
+	bit := int(p.position)
+	value := -1.0
+	if p.Pattern[bit/8]&(0x80>>uint(bit%8)) != 0 {
+		value = 1
+	}
+	p.position += PatternRate(p.Pitch) / float64(sampleRate)
+	p.position = math.Mod(p.position, PatternBits)
+	return value
+}
+
+// Updater is an Oscillator following the state of a CPU, Stream.Update updates it between two samples
+type Updater interface {
+	Oscillator
+	Update(cpu *chip8.CPU)
+}
+
+// Voice is the sound of a CPU: the audio pattern once the program loads one with F002, a tone before
+type Voice struct {
+	Tone    Oscillator
+	pattern Pattern
+	// Set once a pattern was loaded, see chip8.CPU.PatternLoaded
+	patterned bool
+}
+
+// NewVoice returns a voice playing tone until a pattern is loaded
+func NewVoice(tone Oscillator) *Voice {
+	return &Voice{Tone: tone}
+}
+
+// Update takes the audio pattern and the pitch of cpu, the pattern keeps playing from the same position
+func (v *Voice) Update(cpu *chip8.CPU) {
+	v.pattern.Pattern = cpu.AudioPattern
+	v.pattern.Pitch = cpu.Pitch
+	v.patterned = cpu.PatternLoaded
+}
+
+// Sample returns the next sample of the pattern or the tone
+func (v *Voice) Sample(sampleRate int) float64 {
+	if v.patterned {
+		return v.pattern.Sample(sampleRate)
+	}
+	return v.Tone.Sample(sampleRate)
+}
@@ -0,0 +1,130 @@
+package sound
+
+import (
+	"GoCHIP-8/chip8"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "Update the golden files in testdata")
+
+func TestPatternRate(t *testing.T) {
+	assert.Equal(t, 4000.0, PatternRate(64))
+	assert.Equal(t, 8000.0, PatternRate(112))
+	assert.Equal(t, 2000.0, PatternRate(16))
+	assert.InDelta(t, 4000*1.0145453, PatternRate(65), 0.01)
+}
+
+// samples returns n samples of an oscillator
+func samples(o Oscillator, sampleRate, n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = o.Sample(sampleRate)
+	}
+	return values
+}
+
+func TestPattern(t *testing.T) {
+	// One bit per sample at the default pitch
+	p := &Pattern{Pattern: [16]byte{0xF0, 0x01, 15: 0x80}, Pitch: 64}
+	values := samples(p, 4000, PatternBits+2)
+	assert.Equal(t, []float64{1, 1, 1, 1, -1, -1, -1, -1}, values[:8])
+	assert.Equal(t, []float64{-1, 1}, values[14:16])
+	assert.Equal(t, []float64{1, -1}, values[120:122])
+	// The pattern loops
+	assert.Equal(t, []float64{1, 1}, values[PatternBits:])
+
+	// An octave higher skips every other bit
+	p = &Pattern{Pattern: [16]byte{0xCC, 0xCC}, Pitch: 112}
+	assert.Equal(t, []float64{1, -1, 1, -1, 1, -1, 1, -1, -1}, samples(p, 4000, 9))
+}
+
+func TestVoice(t *testing.T) {
+	v := NewVoice(constant(0.5))
+	cpu := chip8.NewCPU()
+	v.Update(&cpu)
+	assert.Equal(t, []float64{0.5, 0.5}, samples(v, 4000, 2))
+
+	// The pattern replaces the tone once loaded
+	cpu.AudioPattern = [16]byte{0xAA, 0xAA}
+	cpu.PatternLoaded = true
+	v.Update(&cpu)
+	assert.Equal(t, []float64{1, -1, 1, -1}, samples(v, 4000, 4))
+
+	// A new pattern plays from the same position, at the new pitch
+	cpu.AudioPattern = [16]byte{0x0F, 0xFF}
+	cpu.Pitch = 112
+	v.Update(&cpu)
+	assert.Equal(t, []float64{1, 1, 1, 1, 1}, samples(v, 4000, 5))
+	assert.Equal(t, 14.0, v.pattern.position)
+}
+
+func TestVoiceSilentPattern(t *testing.T) {
+	// F002 loads 16 zeros from 0x300, then the sound timer runs with the pattern
+	cpu := chip8.NewCPU()
+	cpu.Quirks = chip8.QuirksXOCHIP
+	copy(cpu.Memory.Memory[0x200:], []byte{
+		0xA3, 0x00, // I = 0x300
+		0xF0, 0x02, // Load the audio pattern at I
+		0x60, 0x0A, // V[0] = 10
+		0xF0, 0x18, // ST = V[0]
+		0x12, 0x08, // Jump to 0x208
+	})
+	assert.Nil(t, cpu.Run())
+	assert.True(t, cpu.PatternLoaded)
+	assert.True(t, cpu.Register.ST > 0)
+
+	s := NewStream(2000, NewVoice(constant(1)), 1)
+	s.Update(&cpu)
+	assert.Equal(t, make([]int16, 20), read(t, s, 20))
+
+	cpu.Reset()
+	assert.False(t, cpu.PatternLoaded)
+}
+
+// patternROM loads a pattern, raises the pitch an octave and beeps for 10 frames. After 5 frames it swaps
+// the pattern while the sound plays.
+var patternROM = []byte{
+	0xA2, 0x20, // I = 0x220
+	0xF0, 0x02, // Load the audio pattern at I
+	0x60, 0x70, // V[0] = 112
+	0xF0, 0x3A, // Pitch = V[0]
+	0x61, 0x0A, // V[1] = 10
+	0xF1, 0x18, // ST = V[1]
+	0x62, 0x05, // V[2] = 5
+	0xF2, 0x15, // DT = V[2]
+	0xF2, 0x07, // V[2] = DT
+	0x32, 0x00, // Skip if V[2] == 0
+	0x12, 0x10, // Jump to 0x210
+	0xA2, 0x30, // I = 0x230
+	0xF0, 0x02, // Load the audio pattern at I
+	0x12, 0x1A, // Jump to 0x21A
+	0x00, 0x00, 0x00, 0x00,
+	// 0x220: square wave of 8 bits
+	0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0,
+	// 0x230: pulses
+	0x80, 0x00, 0x80, 0x00, 0x80, 0x00, 0x80, 0x00, 0x80, 0x00, 0x80, 0x00, 0x80, 0x00, 0x80, 0x00,
+}
+
+func TestPatternGolden(t *testing.T) {
+	cpu := chip8.NewCPU()
+	cpu.Quirks = chip8.QuirksXOCHIP
+	copy(cpu.Memory.Memory[0x200:], patternROM)
+	r := NewRenderer(NewStream(8000, NewVoice(NewTone(Square, 440)), 0.5))
+	for frame := 0; frame < 12; frame++ {
+		assert.Nil(t, cpu.Run())
+		r.Frame(&cpu)
+	}
+
+	golden := filepath.Join("testdata", "pattern.pcm")
+	if *update {
+		assert.Nil(t, ioutil.WriteFile(golden, r.PCM(), 0644))
+	}
+	expected, err := ioutil.ReadFile(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, r.PCM())
+}
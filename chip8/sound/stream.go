@@ -0,0 +1,96 @@
+// Package sound generates the audio of a chip8.CPU as 16-bit little endian stereo PCM, the format of ebiten's
+// audio players. A Stream plays an Oscillator while the sound timer is active, like a Voice playing the XO-CHIP
+// audio pattern, and a Renderer renders the audio of a headless run frame by frame to a WAV file.
+package sound
+
+import (
+	"GoCHIP-8/chip8"
+	"math"
+	"sync"
+)
+
+const (
+	Channels       = 2
+	BytesPerSample = 2
+	// BytesPerFrame is the size of a sample of every channel
+	BytesPerFrame = Channels * BytesPerSample
+	// fadeTime in seconds ramps the sound in and out when the gate changes, cutting a wave abruptly clicks
+	fadeTime = 0.002
+)
+
+// Oscillator produces the samples of a sound
+type Oscillator interface {
+	// Sample returns the next sample from -1 to 1
+	Sample(sampleRate int) float64
+}
+
+// Stream is an io.Reader of the PCM samples of an oscillator, audible while its gate is open. It never ends,
+// while the gate is closed it reads silence. Its methods can be called while another goroutine reads it.
+type Stream struct {
+	SampleRate int
+	mu         sync.Mutex
+	oscillator Oscillator
+	volume     float64
+	gate       bool
+	// Envelope ramping from 0 to 1 when the gate opens and back when it closes
+	level float64
+}
+
+// NewStream returns a closed stream of an oscillator at the given volume from 0 to 1
+func NewStream(sampleRate int, oscillator Oscillator, volume float64) *Stream {
+	return &Stream{SampleRate: sampleRate, oscillator: oscillator, volume: volume}
+}
+
+// SetGate opens or closes the gate of the stream
+func (s *Stream) SetGate(open bool) {
+	s.mu.Lock()
+	s.gate = open
+	s.mu.Unlock()
+}
+
+// SetVolume sets the volume from 0 to 1
+func (s *Stream) SetVolume(volume float64) {
+	s.mu.Lock()
+	s.volume = volume
+	s.mu.Unlock()
+}
+
+// Update opens the gate while the sound timer of cpu is active, and updates an Updater oscillator
+func (s *Stream) Update(cpu *chip8.CPU) {
+	s.mu.Lock()
+	s.gate = cpu.Register.ST > 0
+	if u, ok := s.oscillator.(Updater); ok {
+		u.Update(cpu)
+	}
+	s.mu.Unlock()
+}
+
+// Read reads whole frames of samples, it never fails
+func (s *Stream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	step := 1 / (fadeTime * float64(s.SampleRate))
+	n := len(p) / BytesPerFrame * BytesPerFrame
+	for i := 0; i < n; i += BytesPerFrame {
+		if s.gate {
+			s.level = math.Min(s.level+step, 1)
+		} else {
+			s.level = math.Max(s.level-step, 0)
+		}
+		var value int16
+		// The oscillator is paused while silent, it starts again where it stopped
+		if s.level > 0 {
+			value = int16(math.Round(s.oscillator.Sample(s.SampleRate) * s.level * s.volume * math.MaxInt16))
+		}
+		for c := 0; c < Channels; c++ {
+			p[i+c*BytesPerSample] = byte(value)
+			p[i+c*BytesPerSample+1] = byte(uint16(value) >> 8)
+		}
+	}
+	return n, nil
+}
+
+// Close does nothing, it lets ebiten's audio players take the stream
+func (s *Stream) Close() error {
+	return nil
+}
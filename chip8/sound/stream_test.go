@@ -0,0 +1,58 @@
+package sound
+
+import (
+	"GoCHIP-8/chip8"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// constant is an Oscillator always returning the same sample
+type constant float64
+
+func (c constant) Sample(int) float64 {
+	return float64(c)
+}
+
+// read returns n samples of the left channel of s, checking the right channel is the same
+func read(t *testing.T, s *Stream, n int) []int16 {
+	buf := make([]byte, n*BytesPerFrame+1)
+	read, err := s.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, n*BytesPerFrame, read)
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(buf[i*BytesPerFrame:]))
+		assert.Equal(t, samples[i], int16(binary.LittleEndian.Uint16(buf[i*BytesPerFrame+BytesPerSample:])))
+	}
+	return samples
+}
+
+func TestStream(t *testing.T) {
+	// The fade lasts 4 samples at 2000 Hz
+	s := NewStream(2000, constant(1), 0.5)
+	assert.Equal(t, []int16{0, 0, 0}, read(t, s, 3))
+
+	cpu := chip8.NewCPU()
+	cpu.Register.ST = 2
+	s.Update(&cpu)
+	assert.Equal(t, []int16{4096, 8192, 12288, 16384, 16384, 16384}, read(t, s, 6))
+
+	s.SetVolume(1)
+	assert.Equal(t, []int16{32767}, read(t, s, 1))
+
+	cpu.Register.ST = 0
+	s.Update(&cpu)
+	assert.Equal(t, []int16{24575, 16384, 8192, 0, 0}, read(t, s, 5))
+
+	// The oscillator only runs while audible
+	tone := NewTone(Square, 500)
+	s = NewStream(2000, tone, 1)
+	read(t, s, 10)
+	assert.Equal(t, 0.0, tone.phase)
+	s.SetGate(true)
+	read(t, s, 1)
+	assert.Equal(t, 0.25, tone.phase)
+	assert.Nil(t, s.Close())
+}
@@ -0,0 +1,61 @@
+package sound
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Waveform is the shape of a tone
+type Waveform int
+
+const (
+	Square Waveform = iota
+	Sine
+	Triangle
+)
+
+// ParseWaveform parses the name of a waveform: square, sine or triangle
+func ParseWaveform(name string) (Waveform, error) {
+	switch strings.ToLower(name) {
+	case "square":
+		return Square, nil
+	case "sine":
+		return Sine, nil
+	case "triangle":
+		return Triangle, nil
+	}
+	return 0, fmt.Errorf("unknown waveform: %s", name)
+}
+
+// Tone is an Oscillator playing a continuous tone, the beep of CHIP-8
+type Tone struct {
+	Waveform  Waveform
+	Frequency float64
+	// Position in the current period, from 0 to 1
+	phase float64
+}
+
+// NewTone returns a tone of the given waveform and frequency in Hz
+func NewTone(waveform Waveform, frequency float64) *Tone {
+	return &Tone{Waveform: waveform, Frequency: frequency}
+}
+
+// Sample returns the next sample of the tone
+func (t *Tone) Sample(sampleRate int) float64 {
+	var value float64
+	switch t.Waveform {
+	case Square:
+		value = 1
+		if t.phase >= 0.5 {
+			value = -1
+		}
+	case Sine:
+		value = math.Sin(2 * math.Pi * t.phase)
+	case Triangle:
+		value = 1 - 4*math.Abs(t.phase-0.5)
+	}
+	t.phase += t.Frequency / float64(sampleRate)
+	t.phase -= math.Floor(t.phase)
+	return value
+}
@@ -0,0 +1,33 @@
+package sound
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWaveform(t *testing.T) {
+	for name, waveform := range map[string]Waveform{"square": Square, "Sine": Sine, "TRIANGLE": Triangle} {
+		w, err := ParseWaveform(name)
+		assert.Nil(t, err)
+		assert.Equal(t, waveform, w)
+	}
+	_, err := ParseWaveform("saw")
+	assert.EqualError(t, err, "unknown waveform: saw")
+}
+
+func TestTone(t *testing.T) {
+	// 8 samples per period
+	samples := func(waveform Waveform) []float64 {
+		tone := NewTone(waveform, 1000)
+		var values []float64
+		for i := 0; i < 10; i++ {
+			values = append(values, math.Round(tone.Sample(8000)*1000)/1000)
+		}
+		return values
+	}
+	assert.Equal(t, []float64{1, 1, 1, 1, -1, -1, -1, -1, 1, 1}, samples(Square))
+	assert.Equal(t, []float64{0, 0.707, 1, 0.707, 0, -0.707, -1, -0.707, 0, 0.707}, samples(Sine))
+	assert.Equal(t, []float64{-1, -0.5, 0, 0.5, 1, 0.5, 0, -0.5, -1, -0.5}, samples(Triangle))
+}
@@ -0,0 +1,97 @@
+package sound
+
+import (
+	"GoCHIP-8/chip8"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// Renderer renders the audio of a headless run, one frame after another
+type Renderer struct {
+	Stream *Stream
+	pcm    bytes.Buffer
+	frames int
+}
+
+// NewRenderer returns a renderer of the samples of stream
+func NewRenderer(stream *Stream) *Renderer {
+	return &Renderer{Stream: stream}
+}
+
+// Frame renders the samples of a frame of cpu that just ran, the sound plays for every frame ending with
+// the sound timer active. Frames are cut on sample boundaries without drifting from the frame rate.
+func (r *Renderer) Frame(cpu *chip8.CPU) {
+	r.Stream.Update(cpu)
+	start := r.frames * r.Stream.SampleRate / chip8.TimerFrequency
+	r.frames++
+	end := r.frames * r.Stream.SampleRate / chip8.TimerFrequency
+	buf := make([]byte, (end-start)*BytesPerFrame)
+	_, _ = r.Stream.Read(buf)
+	r.pcm.Write(buf)
+}
+
+// PCM returns the samples rendered so far
+func (r *Renderer) PCM() []byte {
+	return r.pcm.Bytes()
+}
+
+// WriteWAV writes the samples rendered so far as a WAV file
+func (r *Renderer) WriteWAV(w io.Writer) error {
+	return WriteWAV(w, r.Stream.SampleRate, r.PCM())
+}
+
+// Save writes the samples rendered so far to a WAV file at path
+func (r *Renderer) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.WriteWAV(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// wavHeader is the RIFF header of a PCM WAV file
+type wavHeader struct {
+	RIFF          [4]byte
+	Size          uint32
+	WAVE          [4]byte
+	Fmt           [4]byte
+	FmtSize       uint32
+	Format        uint16
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+	Data          [4]byte
+	DataSize      uint32
+}
+
+// WriteWAV writes samples in the format of a Stream as a WAV file
+func WriteWAV(w io.Writer, sampleRate int, pcm []byte) error {
+	header := wavHeader{
+		RIFF:          [4]byte{'R', 'I', 'F', 'F'},
+		Size:          uint32(36 + len(pcm)),
+		WAVE:          [4]byte{'W', 'A', 'V', 'E'},
+		Fmt:           [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		Format:        1,
+		Channels:      Channels,
+		SampleRate:    uint32(sampleRate),
+		ByteRate:      uint32(sampleRate * BytesPerFrame),
+		BlockAlign:    BytesPerFrame,
+		BitsPerSample: BytesPerSample * 8,
+		Data:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      uint32(len(pcm)),
+	}
+	if err := binary.Write(w, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	_, err := w.Write(pcm)
+	return err
+}
@@ -0,0 +1,77 @@
+package sound
+
+import (
+	"GoCHIP-8/chip8"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderer(t *testing.T) {
+	cpu := chip8.NewCPU()
+	// Beep for 3 frames
+	copy(cpu.Memory.Memory[0x200:], []byte{
+		0x60, 0x04, // V[0] = 4
+		0xF0, 0x18, // ST = V[0]
+		0x12, 0x04, // Jump to 0x204
+	})
+	r := NewRenderer(NewStream(44100, NewTone(Square, 441), 1))
+	for frame := 0; frame < 6; frame++ {
+		assert.Nil(t, cpu.Run())
+		r.Frame(&cpu)
+	}
+	// 44100 Hz is 735 samples per frame
+	pcm := r.PCM()
+	assert.Len(t, pcm, 6*735*BytesPerFrame)
+	// The sound fades out at the start of the next frame
+	audible := func(frame int) bool {
+		for i := frame*735 + 100; i < (frame+1)*735; i++ {
+			if binary.LittleEndian.Uint16(pcm[i*BytesPerFrame:]) != 0 {
+				return true
+			}
+		}
+		return false
+	}
+	assert.Equal(t, []bool{true, true, true, false, false, false},
+		[]bool{audible(0), audible(1), audible(2), audible(3), audible(4), audible(5)})
+
+	// 1000 Hz doesn't split evenly in frames, they don't drift either
+	r = NewRenderer(NewStream(1000, constant(0), 1))
+	for frame := 0; frame < 60; frame++ {
+		r.Frame(&cpu)
+	}
+	assert.Len(t, r.PCM(), 1000*BytesPerFrame)
+}
+
+func TestWriteWAV(t *testing.T) {
+	var buf bytes.Buffer
+	pcm := []byte{1, 0, 1, 0, 0xFF, 0xFF, 0xFF, 0xFF}
+	assert.Nil(t, WriteWAV(&buf, 48000, pcm))
+	var header wavHeader
+	assert.Nil(t, binary.Read(&buf, binary.LittleEndian, &header))
+	assert.Equal(t, "RIFF", string(header.RIFF[:]))
+	assert.Equal(t, "WAVE", string(header.WAVE[:]))
+	assert.Equal(t, uint32(44), header.Size)
+	assert.Equal(t, uint16(2), header.Channels)
+	assert.Equal(t, uint32(48000), header.SampleRate)
+	assert.Equal(t, uint32(192000), header.ByteRate)
+	assert.Equal(t, uint16(16), header.BitsPerSample)
+	assert.Equal(t, uint32(8), header.DataSize)
+	assert.Equal(t, pcm, buf.Bytes())
+
+	dir, err := ioutil.TempDir("", "sound")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "beep.wav")
+	r := NewRenderer(NewStream(48000, constant(1), 1))
+	r.Frame(&chip8.CPU{})
+	assert.Nil(t, r.Save(path))
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Len(t, data, 44+800*BytesPerFrame)
+}
@@ -0,0 +1,156 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Save state layout: magic, version, payload length, payload and CRC-32 of the payload.
+// Bump stateVersion whenever the layout of the payload changes.
+var stateMagic = [4]byte{'G', 'C', '8', 'S'}
+
+const stateVersion uint16 = 6
+
+var (
+	ErrStateMagic    = errors.New("not a save state")
+	ErrStateVersion  = errors.New("unsupported save state version")
+	ErrStateChecksum = errors.New("save state checksum mismatch")
+)
+
+// ErrStateLength is returned when the payload length of a save state is not the one of its version
+type ErrStateLength struct {
+	Expected, Actual int
+}
+
+func (e ErrStateLength) Error() string {
+	return fmt.Sprintf("save state payload is %d bytes long, expected %d", e.Actual, e.Expected)
+}
+
+type stateHeader struct {
+	Magic   [4]byte
+	Version uint16
+	Length  uint32
+}
+
+// state is the fixed-size payload of a save state
+type state struct {
+	Register      Register
+	Memory        [len(Memory{}.Memory)]byte
+	ROMSize       uint32
+	Stack         [len(CPU{}.Stack)]uint16
+	Display       [HiResDisplayHeight][HiResDisplayWidth]byte
+	KeyState      [16]byte
+	KeyPresses    uint16
+	KeyReleases   uint16
+	WaitInput     bool
+	HiRes         bool
+	Exited        bool
+	Plane         byte
+	Flags         [16]byte
+	AudioPattern  [16]byte
+	PatternLoaded bool
+	Pitch         byte
+	Quirks        Quirks
+	Seed          int64
+	// State of the default generator, 0 when another Random is used
+	RandomState uint64
+}
+
+// SaveState writes the full machine state to w
+func (cpu *CPU) SaveState(w io.Writer) error {
+	s := state{
+		Register:      cpu.Register,
+		Memory:        cpu.Memory.Memory,
+		ROMSize:       uint32(cpu.Memory.ROMSize),
+		Stack:         cpu.Stack,
+		Display:       cpu.Display,
+		KeyState:      cpu.KeyState,
+		KeyPresses:    cpu.KeyPresses,
+		KeyReleases:   cpu.KeyReleases,
+		WaitInput:     cpu.WaitInput,
+		HiRes:         cpu.HiRes,
+		Exited:        cpu.Exited,
+		Plane:         cpu.Plane,
+		Flags:         cpu.Flags,
+		AudioPattern:  cpu.AudioPattern,
+		PatternLoaded: cpu.PatternLoaded,
+		Pitch:         cpu.Pitch,
+		Quirks:        cpu.Quirks,
+		Seed:          cpu.Seed,
+	}
+	if r, ok := cpu.Random.(*Rand); ok {
+		s.RandomState = r.state
+	}
+	var payload bytes.Buffer
+	if err := binary.Write(&payload, binary.BigEndian, &s); err != nil {
+		return err
+	}
+	header := stateHeader{Magic: stateMagic, Version: stateVersion, Length: uint32(payload.Len())}
+	if err := binary.Write(w, binary.BigEndian, &header); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload.Bytes()); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, crc32.ChecksumIEEE(payload.Bytes()))
+}
+
+// LoadState restores the machine state written by SaveState, the CPU is left untouched if the state is rejected
+func (cpu *CPU) LoadState(r io.Reader) error {
+	var header stateHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return err
+	}
+	if header.Magic != stateMagic {
+		return ErrStateMagic
+	}
+	if header.Version != stateVersion {
+		return ErrStateVersion
+	}
+	var s state
+	if int(header.Length) != binary.Size(&s) {
+		return ErrStateLength{Expected: binary.Size(&s), Actual: int(header.Length)}
+	}
+	payload := make([]byte, header.Length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err
+	}
+	var checksum uint32
+	if err := binary.Read(r, binary.BigEndian, &checksum); err != nil {
+		return err
+	}
+	if checksum != crc32.ChecksumIEEE(payload) {
+		return ErrStateChecksum
+	}
+	if err := binary.Read(bytes.NewReader(payload), binary.BigEndian, &s); err != nil {
+		return err
+	}
+	cpu.Register = s.Register
+	cpu.Memory.Memory = s.Memory
+	cpu.Memory.ROMSize = int(s.ROMSize)
+	cpu.InvalidateCache()
+	cpu.Stack = s.Stack
+	cpu.Display = s.Display
+	cpu.KeyState = s.KeyState
+	cpu.KeyPresses = s.KeyPresses
+	cpu.KeyReleases = s.KeyReleases
+	cpu.WaitInput = s.WaitInput
+	cpu.HiRes = s.HiRes
+	cpu.Exited = s.Exited
+	cpu.Plane = s.Plane
+	cpu.Flags = s.Flags
+	cpu.AudioPattern = s.AudioPattern
+	cpu.PatternLoaded = s.PatternLoaded
+	cpu.Pitch = s.Pitch
+	cpu.Quirks = s.Quirks
+	cpu.Seed = s.Seed
+	if r, ok := cpu.Random.(*Rand); ok {
+		r.state = s.RandomState
+	}
+	cpu.NeedDraw = true
+	return nil
+}
@@ -0,0 +1,69 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCPU_SaveState(t *testing.T) {
+	cpu := NewCPU()
+	_ = cpu.LoadROM("../roms/PONG")
+	cpu.Quirks = QuirksVIP
+	cpu.SetSeed(42)
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, cpu.Run())
+	}
+	cpu.KeyState[0x4] = 0x01
+	cpu.Flags[0x2] = 0x18
+	cpu.NeedDraw = false
+	var buf bytes.Buffer
+	assert.Nil(t, cpu.SaveState(&buf))
+
+	newCPU := NewCPU()
+	assert.Nil(t, newCPU.LoadState(bytes.NewReader(buf.Bytes())))
+	cpu.NeedDraw = true
+	assert.Equal(t, cpu, newCPU)
+
+	// The random numbers continue where they were saved, so the restored machine runs the same
+	for i := 0; i < 300; i++ {
+		assert.Nil(t, cpu.Run())
+		assert.Nil(t, newCPU.Run())
+	}
+	assert.Equal(t, cpu, newCPU)
+}
+
+func TestCPU_LoadStateRejected(t *testing.T) {
+	cpu := NewCPU()
+	_ = cpu.LoadROM("../roms/PONG")
+	var buf bytes.Buffer
+	assert.Nil(t, cpu.SaveState(&buf))
+	state := buf.Bytes()
+
+	corrupt := append([]byte(nil), state...)
+	corrupt[0x300] ^= 0xFF
+	newCPU := NewCPU()
+	assert.Equal(t, ErrStateChecksum, newCPU.LoadState(bytes.NewReader(corrupt)))
+	assert.Equal(t, NewCPU(), newCPU)
+
+	corrupt = append([]byte(nil), state...)
+	corrupt[0] = 'X'
+	assert.Equal(t, ErrStateMagic, newCPU.LoadState(bytes.NewReader(corrupt)))
+
+	corrupt = append([]byte(nil), state...)
+	corrupt[5]++
+	assert.Equal(t, ErrStateVersion, newCPU.LoadState(bytes.NewReader(corrupt)))
+
+	// The payload length follows the magic and the version
+	corrupt = append([]byte(nil), state...)
+	length := int(binary.BigEndian.Uint32(corrupt[6:]))
+	binary.BigEndian.PutUint32(corrupt[6:], uint32(length-2))
+	err := newCPU.LoadState(bytes.NewReader(corrupt))
+	assert.Equal(t, ErrStateLength{Expected: length, Actual: length - 2}, err)
+	assert.EqualError(t, err, fmt.Sprintf("save state payload is %d bytes long, expected %d", length-2, length))
+
+	assert.NotNil(t, newCPU.LoadState(bytes.NewReader(state[:len(state)-10])))
+	assert.Equal(t, NewCPU(), newCPU)
+}
@@ -0,0 +1,66 @@
+package chip8
+
+import "fmt"
+
+// Tracer receives every instruction executed by Cycle, set CPU.Tracer to trace execution
+type Tracer interface {
+	Trace(trace *Trace)
+}
+
+// Trace is an executed instruction and its side effects
+type Trace struct {
+	PC     uint16
+	Opcode uint16
+	// Long is the address following the 4 bytes long F000 NNNN instruction
+	Long uint16
+	// Registers changed by the instruction, PC is left out as it changes with every instruction
+	Registers []RegisterChange
+	// Memory written by the instruction, including bytes written with the value they already had
+	Memory []MemoryChange
+	// Err is the fault of the instruction, the side effects are then the ones done before the fault
+	Err error
+}
+
+// RegisterChange is a register set to a new value, V0-VF, I, SP, DT or ST
+type RegisterChange struct {
+	Name string `json:"name"`
+	Old  uint16 `json:"old"`
+	New  uint16 `json:"new"`
+}
+
+// MemoryChange is a byte of memory written
+type MemoryChange struct {
+	Addr uint16 `json:"addr"`
+	Old  byte   `json:"old"`
+	New  byte   `json:"new"`
+}
+
+// traceCycle executes an instruction like Cycle and passes it with its side effects to the tracer
+func (cpu *CPU) traceCycle() error {
+	trace := &Trace{PC: cpu.Register.PC}
+	before := cpu.Register
+	// fetch records the instruction and writeMemory the bytes written, as they are accessed
+	cpu.trace = trace
+	trace.Err = cpu.execute()
+	cpu.trace = nil
+
+	after := cpu.Register
+	for i := range before.V {
+		if before.V[i] != after.V[i] {
+			trace.Registers = append(trace.Registers, RegisterChange{fmt.Sprintf("V%X", i), uint16(before.V[i]), uint16(after.V[i])})
+		}
+	}
+	if before.I != after.I {
+		trace.Registers = append(trace.Registers, RegisterChange{"I", before.I, after.I})
+	}
+	for _, r := range []struct {
+		name          string
+		before, after byte
+	}{{"SP", before.SP, after.SP}, {"DT", before.DT, after.DT}, {"ST", before.ST, after.ST}} {
+		if r.before != r.after {
+			trace.Registers = append(trace.Registers, RegisterChange{r.name, uint16(r.before), uint16(r.after)})
+		}
+	}
+	cpu.Tracer.Trace(trace)
+	return trace.Err
+}
@@ -0,0 +1,84 @@
+package trace
+
+import (
+	"GoCHIP-8/chip8"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter selects the instructions written to a trace, the zero value selects all of them
+type Filter struct {
+	// From and To are the inclusive range of PC traced, ignored when To is 0
+	From, To uint16
+	// Classes are the opcode classes traced, all of them when empty
+	Classes []Class
+}
+
+// Class is an opcode pattern like DXYN, or the first hex digit of the opcodes like F
+type Class string
+
+// Match reports whether an opcode belongs to the class
+func (c Class) Match(opcode uint16) bool {
+	if len(c) == 1 {
+		return strings.EqualFold(string(c), fmt.Sprintf("%X", opcode>>12))
+	}
+	return strings.EqualFold(string(c), chip8.Decode(opcode).Op.String())
+}
+
+// ParseFilter parses a PC range like 0x200-0x2FF and comma separated opcode classes like DXYN,F,
+// empty strings select everything
+func ParseFilter(pcRange, classes string) (Filter, error) {
+	var filter Filter
+	if pcRange != "" {
+		bounds := strings.SplitN(pcRange, "-", 2)
+		if len(bounds) != 2 {
+			return Filter{}, fmt.Errorf("invalid PC range %q, expected like 0x200-0x2FF", pcRange)
+		}
+		from, err1 := strconv.ParseUint(strings.TrimSpace(bounds[0]), 0, 16)
+		to, err2 := strconv.ParseUint(strings.TrimSpace(bounds[1]), 0, 16)
+		if err1 != nil || err2 != nil || from > to || to == 0 {
+			return Filter{}, fmt.Errorf("invalid PC range %q, expected like 0x200-0x2FF", pcRange)
+		}
+		filter.From, filter.To = uint16(from), uint16(to)
+	}
+	if classes != "" {
+		for _, name := range strings.Split(classes, ",") {
+			class := Class(strings.ToUpper(strings.TrimSpace(name)))
+			if !validClass(class) {
+				return Filter{}, fmt.Errorf("invalid opcode class %q, expected a pattern like DXYN or a hex digit", name)
+			}
+			filter.Classes = append(filter.Classes, class)
+		}
+	}
+	return filter, nil
+}
+
+func validClass(class Class) bool {
+	if len(class) == 1 {
+		_, err := strconv.ParseUint(string(class), 16, 4)
+		return err == nil
+	}
+	for op := chip8.Op00CN; op <= chip8.OpFX85; op++ {
+		if string(class) == op.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the instruction of a trace is selected
+func (f Filter) Match(trace *chip8.Trace) bool {
+	if f.To != 0 && (trace.PC < f.From || trace.PC > f.To) {
+		return false
+	}
+	if len(f.Classes) == 0 {
+		return true
+	}
+	for _, class := range f.Classes {
+		if class.Match(trace.Opcode) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package trace
+
+import (
+	"GoCHIP-8/chip8"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var program = []byte{
+	0x6A, 0x7B, // v10 := 123
+	0xA3, 0x00, // i := 0x300
+	0xFA, 0x33, // bcd v10
+	0xF0, 0x00, 0x04, 0x00, // i := long 0x400
+	0xD0, 0x15, // sprite v0 v1 5
+	0xFF, 0xFF, // unknown
+}
+
+// run traces the program to a writer of the given format
+func run(t *testing.T, format Format, filter Filter) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, format)
+	w.Filter = filter
+	cpu := chip8.NewCPU()
+	cpu.Tracer = w
+	copy(cpu.Memory.Memory[0x200:], program)
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, cpu.Cycle())
+	}
+	assert.NotNil(t, cpu.Cycle())
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestText(t *testing.T) {
+	assert.Equal(t, ""+
+		"       0 200 6A7B  va := 0x7B               VA=00->7B\n"+
+		"       1 202 A300  i := 0x300               I=000->300\n"+
+		"       2 204 FA33  bcd va                   [300]=00->01 [301]=00->02 [302]=00->03\n"+
+		"       3 206 F000  i := long 0x400          I=300->400\n"+
+		"       4 20A D015  sprite v0 v1 5\n"+
+		"       5 20C FFFF  0xFF 0xFF                fault: unknown opcode FFFF at 20C\n",
+		string(run(t, Text, Filter{})))
+}
+
+func TestJSONLAndBinary(t *testing.T) {
+	filter := Filter{From: 0x204, To: 0x20A, Classes: []Class{"FX33", "D"}}
+	var expected []Record
+	scanner := bufio.NewScanner(bytes.NewReader(run(t, JSONL, filter)))
+	for scanner.Scan() {
+		var record Record
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &record))
+		expected = append(expected, record)
+	}
+	assert.Len(t, expected, 2)
+	assert.Equal(t, []uint64{2, 4}, []uint64{expected[0].N, expected[1].N})
+	assert.Equal(t, "bcd va", expected[0].Asm)
+	assert.Equal(t, []chip8.MemoryChange{{Addr: 0x300, New: 1}, {Addr: 0x301, New: 2}, {Addr: 0x302, New: 3}}, expected[0].Memory)
+	assert.Equal(t, "sprite v0 v1 5", expected[1].Asm)
+
+	data := run(t, Binary, filter)
+	r, err := NewReader(bytes.NewReader(data))
+	assert.Nil(t, err)
+	var records []Record
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		records = append(records, record)
+	}
+	assert.Equal(t, expected, records)
+
+	// Faults and long addresses are kept, truncated traces are reported
+	all := run(t, Binary, Filter{})
+	r, _ = NewReader(bytes.NewReader(all))
+	for i := 0; i < 3; i++ {
+		_, _ = r.Read()
+	}
+	record, err := r.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(0x400), record.Long)
+	assert.Equal(t, []chip8.RegisterChange{{Name: "I", Old: 0x300, New: 0x400}}, record.Registers)
+	_, _ = r.Read()
+	record, err = r.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "unknown opcode FFFF at 20C", record.Error)
+	r, _ = NewReader(bytes.NewReader(all[:len(all)-1]))
+	for err == nil {
+		_, err = r.Read()
+	}
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = NewReader(bytes.NewReader([]byte("GC8S\x01")))
+	assert.NotNil(t, err)
+}
+
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter("0x200-0x2ff", "dxyn, 8")
+	assert.Nil(t, err)
+	assert.Equal(t, Filter{From: 0x200, To: 0x2FF, Classes: []Class{"DXYN", "8"}}, filter)
+	assert.True(t, filter.Match(&chip8.Trace{PC: 0x2FF, Opcode: 0x8124}))
+	assert.True(t, filter.Match(&chip8.Trace{PC: 0x200, Opcode: 0xD125}))
+	assert.False(t, filter.Match(&chip8.Trace{PC: 0x200, Opcode: 0x6125}))
+	assert.False(t, filter.Match(&chip8.Trace{PC: 0x300, Opcode: 0x8124}))
+	assert.True(t, Filter{}.Match(&chip8.Trace{PC: 0xFFFF}))
+
+	for _, bad := range [][2]string{{"0x200", ""}, {"0x300-0x200", ""}, {"", "DXYZ"}, {"", "G"}} {
+		_, err := ParseFilter(bad[0], bad[1])
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, JSONL, FormatOf("run.jsonl"))
+	assert.Equal(t, Binary, FormatOf("run.bin"))
+	assert.Equal(t, Text, FormatOf("run.log"))
+	format, err := ParseFormat("Binary")
+	assert.Nil(t, err)
+	assert.Equal(t, Binary, format)
+	_, err = ParseFormat("xml")
+	assert.NotNil(t, err)
+}
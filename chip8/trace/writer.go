@@ -0,0 +1,342 @@
+// Package trace writes the instructions executed by a chip8.CPU with their side effects, as text, JSON Lines
+// or a compact binary format, to compare executions between versions of the emulator or with other emulators.
+//
+// The binary format starts with the magic "C8TR" and a version byte. Each record is the uvarint count of
+// instructions left out by the filter since the previous record, PC, the opcode and for F000 the address
+// following it as big-endian words, then a byte with the number of registers changed and the bit 0x80 set
+// on faults, a byte with the number of memory bytes written, the registers as an index (0-15 for V0-VF,
+// 16 I, 17 SP, 18 DT, 19 ST) followed by the old and new values as words, the memory writes as an address
+// word followed by the old and new bytes, and on faults the uvarint length and text of the error.
+package trace
+
+import (
+	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/disasm"
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Format of a trace
+type Format int
+
+const (
+	// Text is one human readable line per instruction
+	Text Format = iota
+	// JSONL is one JSON Record per line
+	JSONL
+	// Binary is the compact format described in the package documentation
+	Binary
+)
+
+// ParseFormat parses the name of a format: text, jsonl or binary
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "text", "txt":
+		return Text, nil
+	case "jsonl", "json":
+		return JSONL, nil
+	case "binary", "bin":
+		return Binary, nil
+	}
+	return 0, fmt.Errorf("unknown trace format: %s", name)
+}
+
+// FormatOf returns the format of a trace file by its extension, .jsonl and .bin, text otherwise
+func FormatOf(path string) Format {
+	format, err := ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return Text
+	}
+	return format
+}
+
+var binaryMagic = [4]byte{'C', '8', 'T', 'R'}
+
+const binaryVersion = 1
+
+// registerNames are the registers of a trace by their index in the binary format
+var registerNames = []string{
+	"V0", "V1", "V2", "V3", "V4", "V5", "V6", "V7",
+	"V8", "V9", "VA", "VB", "VC", "VD", "VE", "VF",
+	"I", "SP", "DT", "ST",
+}
+
+func registerIndex(name string) byte {
+	for i, n := range registerNames {
+		if n == name {
+			return byte(i)
+		}
+	}
+	return 0
+}
+
+// Record is a traced instruction as written in JSON Lines and read back from the binary format
+type Record struct {
+	// N is the number of instructions executed before this one, including those left out by the filter
+	N         uint64                 `json:"n"`
+	PC        uint16                 `json:"pc"`
+	Opcode    uint16                 `json:"opcode"`
+	Long      uint16                 `json:"long,omitempty"`
+	Asm       string                 `json:"asm"`
+	Registers []chip8.RegisterChange `json:"registers,omitempty"`
+	Memory    []chip8.MemoryChange   `json:"memory,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+func newRecord(n uint64, trace *chip8.Trace) Record {
+	record := Record{
+		N:         n,
+		PC:        trace.PC,
+		Opcode:    trace.Opcode,
+		Long:      trace.Long,
+		Registers: trace.Registers,
+		Memory:    trace.Memory,
+	}
+	record.Asm = disassemble(record.Opcode, record.Long)
+	if trace.Err != nil {
+		record.Error = trace.Err.Error()
+	}
+	return record
+}
+
+func disassemble(opcode, long uint16) string {
+	return disasm.Format(chip8.Decode(opcode), long, disasm.Octo)
+}
+
+// Writer is a chip8.Tracer writing the instructions selected by its filter
+type Writer struct {
+	Filter Filter
+	format Format
+	w      *bufio.Writer
+	// File closed by Close when the writer was created by Create
+	closer io.Closer
+	// Instructions traced so far and the number of the one following the last record
+	n, next uint64
+	// First write error, returned by Close
+	err error
+}
+
+// NewWriter returns a writer of traces in the given format to w
+func NewWriter(w io.Writer, format Format) *Writer {
+	t := &Writer{format: format, w: bufio.NewWriter(w)}
+	if format == Binary {
+		_, t.err = t.w.Write(append(binaryMagic[:], binaryVersion))
+	}
+	return t
+}
+
+// Create creates a trace file, its format is chosen by FormatOf
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	t := NewWriter(f, FormatOf(path))
+	t.closer = f
+	return t, nil
+}
+
+// Trace writes an instruction when it passes the filter
+func (t *Writer) Trace(trace *chip8.Trace) {
+	n := t.n
+	t.n++
+	if t.err != nil || !t.Filter.Match(trace) {
+		return
+	}
+	switch t.format {
+	case Text:
+		t.err = t.writeText(n, trace)
+	case JSONL:
+		data, err := json.Marshal(newRecord(n, trace))
+		if err == nil {
+			data = append(data, '\n')
+			_, err = t.w.Write(data)
+		}
+		t.err = err
+	case Binary:
+		t.err = t.writeBinary(n, trace)
+	}
+}
+
+func (t *Writer) writeText(n uint64, trace *chip8.Trace) error {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%8d %03X %04X  %-24s", n, trace.PC, trace.Opcode, disassemble(trace.Opcode, trace.Long)))
+	for _, r := range trace.Registers {
+		if r.Name == "I" {
+			sb.WriteString(fmt.Sprintf(" I=%03X->%03X", r.Old, r.New))
+		} else {
+			sb.WriteString(fmt.Sprintf(" %s=%02X->%02X", r.Name, r.Old, r.New))
+		}
+	}
+	for _, m := range trace.Memory {
+		sb.WriteString(fmt.Sprintf(" [%03X]=%02X->%02X", m.Addr, m.Old, m.New))
+	}
+	if trace.Err != nil {
+		sb.WriteString(" fault: " + trace.Err.Error())
+	}
+	_, err := t.w.WriteString(strings.TrimRight(sb.String(), " ") + "\n")
+	return err
+}
+
+func (t *Writer) writeBinary(n uint64, trace *chip8.Trace) error {
+	buf := make([]byte, 0, 16)
+	buf = appendUvarint(buf, n-t.next)
+	t.next = n + 1
+	buf = appendWord(buf, trace.PC)
+	buf = appendWord(buf, trace.Opcode)
+	if trace.Opcode == 0xF000 {
+		buf = appendWord(buf, trace.Long)
+	}
+	registers := byte(len(trace.Registers))
+	if trace.Err != nil {
+		registers |= 0x80
+	}
+	buf = append(buf, registers, byte(len(trace.Memory)))
+	for _, r := range trace.Registers {
+		buf = append(buf, registerIndex(r.Name))
+		buf = appendWord(buf, r.Old)
+		buf = appendWord(buf, r.New)
+	}
+	for _, m := range trace.Memory {
+		buf = appendWord(buf, m.Addr)
+		buf = append(buf, m.Old, m.New)
+	}
+	if trace.Err != nil {
+		text := trace.Err.Error()
+		buf = appendUvarint(buf, uint64(len(text)))
+		buf = append(buf, text...)
+	}
+	_, err := t.w.Write(buf)
+	return err
+}
+
+func appendUvarint(buf []byte, value uint64) []byte {
+	var varint [binary.MaxVarintLen64]byte
+	return append(buf, varint[:binary.PutUvarint(varint[:], value)]...)
+}
+
+func appendWord(buf []byte, value uint16) []byte {
+	return append(buf, byte(value>>8), byte(value))
+}
+
+// Flush writes the buffered traces, it returns the first error met while writing
+func (t *Writer) Flush() error {
+	if t.err != nil {
+		return t.err
+	}
+	return t.w.Flush()
+}
+
+// Close flushes the writer and closes the file of Create
+func (t *Writer) Close() error {
+	err := t.Flush()
+	if t.closer != nil {
+		if closeErr := t.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Reader reads traces in the binary format
+type Reader struct {
+	r    *bufio.Reader
+	next uint64
+}
+
+// NewReader checks the header of a binary trace and returns a reader of its records
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	var header [5]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, fmt.Errorf("reading trace header: %w", err)
+	}
+	if [4]byte{header[0], header[1], header[2], header[3]} != binaryMagic {
+		return nil, fmt.Errorf("not a binary trace")
+	}
+	if header[4] != binaryVersion {
+		return nil, fmt.Errorf("unsupported binary trace version %d", header[4])
+	}
+	return &Reader{r: br}, nil
+}
+
+// Read returns the next record, io.EOF at the end of the trace
+func (r *Reader) Read() (Record, error) {
+	skipped, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return Record{}, err
+	}
+	record := Record{N: r.next + skipped}
+	r.next = record.N + 1
+	var fixed [6]byte
+	if err := r.readFull(fixed[:4]); err != nil {
+		return Record{}, err
+	}
+	record.PC = word(fixed[0:])
+	record.Opcode = word(fixed[2:])
+	if record.Opcode == 0xF000 {
+		if err := r.readFull(fixed[:2]); err != nil {
+			return Record{}, err
+		}
+		record.Long = word(fixed[:])
+	}
+	if err := r.readFull(fixed[:2]); err != nil {
+		return Record{}, err
+	}
+	registers, memory, fault := int(fixed[0]&0x7F), int(fixed[1]), fixed[0]&0x80 != 0
+	for i := 0; i < registers; i++ {
+		if err := r.readFull(fixed[:5]); err != nil {
+			return Record{}, err
+		}
+		if int(fixed[0]) >= len(registerNames) {
+			return Record{}, fmt.Errorf("invalid register index %d", fixed[0])
+		}
+		record.Registers = append(record.Registers, chip8.RegisterChange{
+			Name: registerNames[fixed[0]],
+			Old:  word(fixed[1:]),
+			New:  word(fixed[3:]),
+		})
+	}
+	for i := 0; i < memory; i++ {
+		if err := r.readFull(fixed[:4]); err != nil {
+			return Record{}, err
+		}
+		record.Memory = append(record.Memory, chip8.MemoryChange{Addr: word(fixed[:]), Old: fixed[2], New: fixed[3]})
+	}
+	if fault {
+		length, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return Record{}, unexpected(err)
+		}
+		text := make([]byte, length)
+		if err := r.readFull(text); err != nil {
+			return Record{}, err
+		}
+		record.Error = string(text)
+	}
+	record.Asm = disassemble(record.Opcode, record.Long)
+	return record, nil
+}
+
+// readFull reads a part of a record, the end of the trace is unexpected there
+func (r *Reader) readFull(buf []byte) error {
+	_, err := io.ReadFull(r.r, buf)
+	return unexpected(err)
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func word(buf []byte) uint16 {
+	return uint16(buf[0])<<8 | uint16(buf[1])
+}
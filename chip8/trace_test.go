@@ -0,0 +1,126 @@
+package chip8
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// traces collects the traces it receives
+type traces []Trace
+
+func (t *traces) Trace(trace *Trace) {
+	*t = append(*t, *trace)
+}
+
+func TestCPU_Tracer(t *testing.T) {
+	cpu := NewCPU()
+	var got traces
+	cpu.Tracer = &got
+	program := []byte{
+		0x6A, 0x7B, // v10 := 123
+		0xA3, 0x00, // i := 0x300
+		0xFA, 0x33, // bcd v10
+		0xF0, 0x00, 0x04, 0x00, // i := long 0x400
+		0x12, 0x0A, // jump 0x20A
+		0xFF, 0xFF, // unknown
+	}
+	copy(cpu.Memory.Memory[0x200:], program)
+	cpu.Memory.Memory[0x300] = 0x01
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, cpu.Cycle())
+	}
+	cpu.Register.PC = 0x20C
+	err := cpu.Cycle()
+	assert.NotNil(t, err)
+
+	assert.Equal(t, traces{
+		{PC: 0x200, Opcode: 0x6A7B, Registers: []RegisterChange{{"VA", 0x00, 0x7B}}},
+		{PC: 0x202, Opcode: 0xA300, Registers: []RegisterChange{{"I", 0x000, 0x300}}},
+		{PC: 0x204, Opcode: 0xFA33, Memory: []MemoryChange{{0x300, 0x01, 1}, {0x301, 0x00, 2}, {0x302, 0x00, 3}}},
+		{PC: 0x206, Opcode: 0xF000, Long: 0x400, Registers: []RegisterChange{{"I", 0x300, 0x400}}},
+		{PC: 0x20A, Opcode: 0x120A},
+		{PC: 0x20C, Opcode: 0xFFFF, Err: err},
+	}, got)
+	var unknown ErrUnknownOpcode
+	assert.True(t, errors.As(got[5].Err, &unknown))
+
+	// Tracing does not change execution
+	traced := NewCPU()
+	traced.Tracer = &traces{}
+	plain := NewCPU()
+	assert.Nil(t, traced.LoadROM("../roms/PONG"))
+	assert.Nil(t, plain.LoadROM("../roms/PONG"))
+	for i := 0; i < 60; i++ {
+		assert.Nil(t, traced.Run())
+		assert.Nil(t, plain.Run())
+	}
+	traced.Tracer = nil
+	assert.Equal(t, plain, traced)
+}
+
+func TestCPU_TracerPartialWrites(t *testing.T) {
+	cpu := NewCPU()
+	var got traces
+	cpu.Tracer = &got
+	cpu.Register.V = [16]byte{0x11, 0x22, 0x33}
+	copy(cpu.Memory.Memory[0x200:], []byte{
+		0xF2, 0x55, // save v2
+		0xF2, 0x55, // save v2
+	})
+
+	// The bytes written before a fault are traced
+	cpu.Memory.Protect(0x300, 1)
+	cpu.Register.I = 0x2FE
+	err := cpu.Cycle()
+	assert.Equal(t, ErrWriteProtected{PC: 0x200, Addr: 0x300}, err)
+	assert.Equal(t, []MemoryChange{{0x2FE, 0x00, 0x11}, {0x2FF, 0x00, 0x22}}, got[0].Memory)
+	assert.Equal(t, err, got[0].Err)
+
+	// Writes wrapping around the end of memory are traced at the addresses written
+	cpu.Quirks.AddressBits = 12
+	cpu.Memory.Policy = AccessWrap
+	cpu.Register.PC = 0x202
+	cpu.Register.I = 0xFFF
+	assert.Nil(t, cpu.Cycle())
+	assert.Equal(t, []MemoryChange{{0xFFF, 0x00, 0x11}, {0x000, 0xF0, 0x22}, {0x001, 0x90, 0x33}}, got[1].Memory)
+}
+
+// romBus serves its own bytes for the addresses of rom and forwards the other accesses to memory
+type romBus struct {
+	memory *Memory
+	rom    map[uint16]byte
+}
+
+func (b romBus) Read8(addr uint16, kind AccessKind) byte {
+	if value, ok := b.rom[addr]; ok {
+		return value
+	}
+	return b.memory.Read8(addr, kind)
+}
+
+func (b romBus) Write8(addr uint16, value byte, kind AccessKind) {
+	b.memory.Write8(addr, value, kind)
+}
+
+func TestCPU_TracerFetch(t *testing.T) {
+	// The instruction is traced as fetched, wrapping around the end of memory
+	cpu := NewCPU()
+	var got traces
+	cpu.Tracer = &got
+	cpu.Quirks.AddressBits = 12
+	cpu.Memory.Policy = AccessWrap
+	cpu.Memory.Memory[0xFFF] = 0x60
+	cpu.Memory.Memory[0x000] = 0x12
+	cpu.Register.PC = 0xFFF
+	assert.Nil(t, cpu.Cycle())
+	assert.Equal(t, Trace{PC: 0xFFF, Opcode: 0x6012, Registers: []RegisterChange{{"V0", 0x00, 0x12}}}, got[0])
+
+	// and through the bus
+	cpu = NewCPU()
+	got = nil
+	cpu.Tracer = &got
+	cpu.Bus = romBus{&cpu.Memory, map[uint16]byte{0x200: 0xF0, 0x201: 0x00, 0x202: 0x05, 0x203: 0x00}}
+	assert.Nil(t, cpu.Cycle())
+	assert.Equal(t, Trace{PC: 0x200, Opcode: 0xF000, Long: 0x500, Registers: []RegisterChange{{"I", 0x000, 0x500}}}, got[0])
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"GoCHIP-8/chip8/asm"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func asmCommand(args []string) error {
+	flags := flag.NewFlagSet("asm", flag.ExitOnError)
+	output := flags.String("o", "", "Output ROM `path`, defaults to the source path with the extension .ch8")
+	symbols := flags.Bool("sym", false, "Also write the labels and source lines next to the ROM with the extension .sym, for debuggers")
+	flags.Usage = func() {
+		_, _ = os.Stderr.WriteString("Usage: gochip8 asm <source> [-o rom] [-sym]\n\nOptions:\n")
+		flags.PrintDefaults()
+	}
+	// The source may come before the options, like gochip8 asm in.8o -o out.ch8
+	var sources []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		sources = append(sources, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(sources) != 1 {
+		flags.Usage()
+		return errors.New("asm requires one source file")
+	}
+	program, err := asm.AssembleFile(sources[0])
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(sources[0], ".8o") + ".ch8"
+	}
+	if err := ioutil.WriteFile(*output, program.ROM, 0644); err != nil {
+		return err
+	}
+	if !*symbols {
+		return nil
+	}
+	return writeOutput(asm.SymbolsPath(*output), func(w io.Writer) error {
+		return asm.WriteSymbols(w, program.Symbols())
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"GoCHIP-8/chip8"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// cpuFlags are the flags setting up the CPU, shared by the commands running a ROM
+type cpuFlags struct {
+	command string
+	romPath string
+	options chip8.Options
+}
+
+// addCPUFlags defines the CPU flags of command on flags
+func addCPUFlags(flags *flag.FlagSet, command string) *cpuFlags {
+	f := &cpuFlags{command: command}
+	flags.StringVar(&f.romPath, "rom", "", "The `path` to ROM")
+	flags.IntVar(&f.options.Clock, "clock", 400, "CPU `clock speed` in Hz")
+	flags.StringVar(&f.options.Quirks, "quirks", chip8.DefaultQuirksPreset, "Quirks `preset`: original, vip, chip48, schip, xochip")
+	flags.StringVar(&f.options.Memory, "memory", "fault", "`Policy` of memory accesses past the end of memory: fault, wrap or log")
+	flags.StringVar(&f.options.Protect, "protect", "", "Comma separated memory `areas` to write protect: interpreter, rom")
+	flags.Int64Var(&f.options.Seed, "seed", 0, "`Seed` of the random numbers of CXNN, runs with the same seed and input are identical")
+	return f
+}
+
+// newCPU returns the CPU set up by the parsed flags, running the ROM of -rom
+func newCPU(f *cpuFlags) (chip8.CPU, error) {
+	if f.romPath == "" {
+		return chip8.CPU{}, fmt.Errorf("%s requires -rom", f.command)
+	}
+	options := f.options
+	options.Log = func(err error) {
+		_, _ = fmt.Fprintf(os.Stderr, "ignored memory fault: %s\n", err)
+	}
+	return options.NewCPU(f.romPath)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"GoCHIP-8/chip8/dap"
+	"flag"
+	"log"
+	"os"
+)
+
+func dapCommand(args []string) error {
+	flags := flag.NewFlagSet("dap", flag.ExitOnError)
+	flags.Usage = func() {
+		_, _ = os.Stderr.WriteString("Usage: gochip8 dap\n\n" +
+			"Speaks the Debug Adapter Protocol over stdin and stdout, the ROM is the program of the launch request.\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	server := dap.NewServer()
+	// Stdout carries the protocol
+	server.Logger = log.New(os.Stderr, "gochip8: ", 0)
+	return server.Serve(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"GoCHIP-8/chip8/asm"
+	"GoCHIP-8/chip8/debugger"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func debugCommand(args []string) error {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	cpuFlags := addCPUFlags(flags, "debug")
+	symbolsPath := flags.String("sym", "", "The `path` to the symbol file naming the call stack, the ROM path with the extension .sym by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cpu, err := newCPU(cpuFlags)
+	if err != nil {
+		return err
+	}
+	d := debugger.New(&cpu)
+	if d.Symbols, err = readSymbols(*symbolsPath, cpuFlags.romPath); err != nil {
+		return err
+	}
+	_, _ = os.Stdout.WriteString("Type help to list the commands.\n")
+	return d.REPL(os.Stdin, os.Stdout)
+}
+
+// readSymbols reads the symbol file at path, or next to the ROM when path is empty and the file exists
+func readSymbols(path, romPath string) (asm.Symbols, error) {
+	requested := path != ""
+	if !requested {
+		path = asm.SymbolsPath(romPath)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if !requested && os.IsNotExist(err) {
+			return asm.Symbols{}, nil
+		}
+		return asm.Symbols{}, err
+	}
+	defer f.Close()
+	symbols, err := asm.ReadSymbols(f)
+	if err != nil {
+		return asm.Symbols{}, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return symbols, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"GoCHIP-8/chip8/disasm"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+)
+
+func disasmCommand(args []string) error {
+	flags := flag.NewFlagSet("disasm", flag.ExitOnError)
+	syntaxName := flags.String("syntax", "octo", "Assembly `syntax`: octo, cowgod")
+	origin := flags.Uint("origin", 0x200, "Load `address` of the ROM")
+	flags.Usage = func() {
+		_, _ = os.Stderr.WriteString("Usage: gochip8 disasm [-syntax octo|cowgod] [-origin address] <rom>\n\nOptions:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("disasm requires a ROM")
+	}
+	syntax, err := disasm.ParseSyntax(*syntaxName)
+	if err != nil {
+		return err
+	}
+	rom, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return disasm.Analyze(rom, uint16(*origin)).Write(os.Stdout, syntax)
+}
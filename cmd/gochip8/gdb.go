@@ -0,0 +1,29 @@
+package main
+
+import (
+	"GoCHIP-8/chip8/gdb"
+	"flag"
+	"log"
+	"os"
+)
+
+func gdbCommand(args []string) error {
+	flags := flag.NewFlagSet("gdb", flag.ExitOnError)
+	addr := flags.String("gdb", ":1234", "TCP `address` to listen on for gdb")
+	cpuFlags := addCPUFlags(flags, "gdb")
+	flags.Usage = func() {
+		_, _ = os.Stderr.WriteString("Usage: gochip8 --gdb :1234 -rom <rom>\n\nOptions:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cpu, err := newCPU(cpuFlags)
+	if err != nil {
+		return err
+	}
+	server := gdb.NewServer(&cpu)
+	server.Logger = log.New(os.Stderr, "gochip8: ", 0)
+	return server.ListenAndServe(*addr)
+}
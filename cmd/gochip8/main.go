@@ -0,0 +1,63 @@
+// Command gochip8 provides the CHIP-8 tools that do not need a window or an audio device,
+// the emulator with graphics and sound is the GoCHIP-8 program in the repository root.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func usage() {
+	_, _ = fmt.Fprintf(os.Stderr, `gochip8
+Usage: gochip8 <command> [options]
+
+Commands:
+  run     Run a ROM headless and dump the final display and registers
+  disasm  Disassemble a ROM
+  asm     Assemble a ROM from Octo assembly
+  debug   Debug a ROM from the command line
+  gdb     Serve a ROM to gdb, also started by gochip8 --gdb :1234
+  dap     Serve the Debug Adapter Protocol on stdin and stdout for editors
+
+Run "gochip8 <command> -h" to show the options of a command.
+`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = runCommand(os.Args[2:])
+	case "disasm":
+		err = disasmCommand(os.Args[2:])
+	case "asm":
+		err = asmCommand(os.Args[2:])
+	case "debug":
+		err = debugCommand(os.Args[2:])
+	case "gdb":
+		err = gdbCommand(os.Args[2:])
+	case "dap":
+		err = dapCommand(os.Args[2:])
+	case "-h", "--help", "help":
+		usage()
+		return
+	default:
+		// gochip8 --gdb :1234 is a shorthand for gochip8 gdb -gdb :1234
+		if strings.HasPrefix(strings.TrimLeft(os.Args[1], "-"), "gdb") && strings.HasPrefix(os.Args[1], "-") {
+			err = gdbCommand(os.Args[1:])
+			break
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "gochip8: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "gochip8: %s\n", err)
+		os.Exit(1)
+	}
+}
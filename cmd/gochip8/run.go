@@ -0,0 +1,266 @@
+package main
+
+import (
+	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/bus"
+	"GoCHIP-8/chip8/input"
+	"GoCHIP-8/chip8/movie"
+	"GoCHIP-8/chip8/sound"
+	"GoCHIP-8/chip8/trace"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// registers is the JSON dump of the CPU registers
+type registers struct {
+	V     []int `json:"V"`
+	I     int   `json:"I"`
+	PC    int   `json:"PC"`
+	SP    int   `json:"SP"`
+	DT    int   `json:"DT"`
+	ST    int   `json:"ST"`
+	Stack []int `json:"Stack"`
+}
+
+// sampleRate of the WAV files written by -wav
+const sampleRate = 48000
+
+var displayPalette = color.Palette{
+	color.Black,
+	color.White,
+	color.RGBA{R: 0xFF, G: 0x66, B: 0x00, A: 0xFF},
+	color.RGBA{R: 0x66, G: 0x22, B: 0x00, A: 0xFF},
+}
+
+func runCommand(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	headless := flags.Bool("headless", false, "Run without a window, required as gochip8 has no graphics")
+	cpuFlags := addCPUFlags(flags, "run")
+	frames := flags.Int("frames", 600, "Number of `frames` to run at 60 frames per second")
+	console := flags.String("console", "", "Print the bytes written to the `address` to stdout instead of storing them, for test ROMs")
+	inputPath := flags.String("input", "", "`Path` to an input script, each line is \"<frame> <key> down|up\"")
+	pngPath := flags.String("png", "", "Write the final display as PNG to `path`")
+	asciiPath := flags.String("ascii", "", "Write the final display as ASCII art to `path`, - for stdout")
+	registersPath := flags.String("registers", "", "Write the final registers as JSON to `path`, - for stdout")
+	tracePath := flags.String("trace", "", "Write every executed instruction to `file`, as JSON Lines for .jsonl, binary for .bin and text otherwise")
+	tracePC := flags.String("trace-pc", "", "Only trace the instructions in the PC `range`, like 0x200-0x2FF")
+	traceOps := flags.String("trace-ops", "", "Only trace the comma separated opcode `classes`, like DXYN,F")
+	recordPath := flags.String("record", "", "Record the input, seed and quirks to a movie `file` for -play")
+	playPath := flags.String("play", "", "Play the movie `file` back for all its frames, the run fails at the first frame that differs")
+	wavPath := flags.String("wav", "", "Write the sound to a WAV `file`")
+	waveformName := flags.String("waveform", "square", "`Waveform` of the sound: square, sine or triangle")
+	frequency := flags.Float64("frequency", 440, "`Frequency` of the sound in Hz")
+	volume := flags.Float64("volume", 0.5, "`Volume` of the sound from 0 to 1")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !*headless {
+		return errors.New("run requires -headless, use the GoCHIP-8 program to play with a window")
+	}
+	if *playPath != "" && *inputPath != "" {
+		return errors.New("-play replaces -input, use only one of them")
+	}
+
+	cpu, err := newCPU(cpuFlags)
+	if err != nil {
+		return err
+	}
+	if *console != "" {
+		addr, err := strconv.ParseUint(*console, 0, 16)
+		if err != nil {
+			return fmt.Errorf("invalid console address %q", *console)
+		}
+		mapper := bus.NewMapper(&cpu.Memory)
+		mapper.Map(uint16(addr), 1, bus.Console{W: os.Stdout})
+		cpu.Bus = mapper
+	}
+	var source input.Source
+	if *inputPath != "" {
+		f, err := os.Open(*inputPath)
+		if err != nil {
+			return err
+		}
+		events, err := input.ParseScript(f)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("%s:%s", *inputPath, err)
+		}
+		source = input.NewScript(events)
+	}
+
+	var recorder *movie.Recorder
+	var player *movie.Player
+	if *recordPath != "" || *playPath != "" {
+		rom, err := ioutil.ReadFile(cpuFlags.romPath)
+		if err != nil {
+			return err
+		}
+		if *playPath != "" {
+			m, err := movie.Load(*playPath)
+			if err != nil {
+				return err
+			}
+			if player, err = movie.NewPlayer(m, &cpu, rom); err != nil {
+				return err
+			}
+			*frames = m.Frames()
+		}
+		if *recordPath != "" {
+			recorder = movie.NewRecorder(&cpu, rom)
+		}
+	}
+
+	var renderer *sound.Renderer
+	if *wavPath != "" {
+		waveform, err := sound.ParseWaveform(*waveformName)
+		if err != nil {
+			return err
+		}
+		renderer = sound.NewRenderer(sound.NewStream(sampleRate, sound.NewVoice(sound.NewTone(waveform, *frequency)), *volume))
+	}
+
+	var tracer *trace.Writer
+	if *tracePath != "" {
+		filter, err := trace.ParseFilter(*tracePC, *traceOps)
+		if err != nil {
+			return err
+		}
+		tracer, err = trace.Create(*tracePath)
+		if err != nil {
+			return err
+		}
+		tracer.Filter = filter
+		cpu.Tracer = tracer
+	}
+
+	// Dump the outputs even when the CPU faults so the failure can be inspected
+	runErr := runHeadless(&cpu, *frames, source, recorder, player, renderer)
+	if tracer != nil {
+		if err := tracer.Close(); err != nil {
+			return err
+		}
+	}
+	if *pngPath != "" {
+		if err := writeOutput(*pngPath, func(w io.Writer) error {
+			return png.Encode(w, cpu.DisplayImage(displayPalette))
+		}); err != nil {
+			return err
+		}
+	}
+	if *asciiPath != "" {
+		if err := writeOutput(*asciiPath, func(w io.Writer) error {
+			_, err := io.WriteString(w, displayASCII(&cpu))
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	if *registersPath != "" {
+		if err := writeOutput(*registersPath, func(w io.Writer) error {
+			return writeRegisters(w, &cpu)
+		}); err != nil {
+			return err
+		}
+	}
+	if recorder != nil {
+		if err := recorder.Movie.Save(*recordPath); err != nil {
+			return err
+		}
+	}
+	if renderer != nil {
+		if err := renderer.Save(*wavPath); err != nil {
+			return err
+		}
+	}
+	return runErr
+}
+
+// runHeadless runs the CPU for the given number of frames, polling the input source before each frame.
+// The frames are recorded by recorder, checked by player and their sound rendered by renderer when they are not nil.
+func runHeadless(cpu *chip8.CPU, frames int, source input.Source, recorder *movie.Recorder, player *movie.Player, renderer *sound.Renderer) error {
+	for frame := 0; frame < frames && !cpu.Exited; frame++ {
+		if source != nil {
+			input.Poll(cpu, source)
+		}
+		if player != nil {
+			player.StartFrame(cpu)
+		}
+		if recorder != nil {
+			recorder.StartFrame(cpu)
+		}
+		if err := cpu.RunFrames(1); err != nil {
+			return fmt.Errorf("frame %d: %w", frame, err)
+		}
+		if recorder != nil {
+			recorder.EndFrame(cpu)
+		}
+		if renderer != nil {
+			renderer.Frame(cpu)
+		}
+		if player != nil {
+			if err := player.EndFrame(cpu); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// displayASCII renders the display with one character per pixel: . is off, # is plane 1, + is plane 2 and @ is both
+func displayASCII(cpu *chip8.CPU) string {
+	const pixels = ".#+@"
+	width, height := cpu.DisplaySize()
+	var sb strings.Builder
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			sb.WriteByte(pixels[cpu.Display[i][j]&0x03])
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func writeRegisters(w io.Writer, cpu *chip8.CPU) error {
+	regs := registers{
+		I:     int(cpu.Register.I),
+		PC:    int(cpu.Register.PC),
+		SP:    int(cpu.Register.SP),
+		DT:    int(cpu.Register.DT),
+		ST:    int(cpu.Register.ST),
+		Stack: []int{},
+	}
+	for _, v := range cpu.Register.V {
+		regs.V = append(regs.V, int(v))
+	}
+	for _, addr := range cpu.Stack[:cpu.Register.SP] {
+		regs.Stack = append(regs.Stack, int(addr))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(regs)
+}
+
+// writeOutput calls write with the file at path, or with stdout when path is -
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/input"
+	"GoCHIP-8/chip8/movie"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunHeadless(t *testing.T) {
+	// Draws the digit of the last pressed key at (0, 0)
+	cpu := chip8.NewCPU()
+	copy(cpu.Memory.Memory[0x200:], []byte{
+		0xF0, 0x0A, // V[0] = key
+		0xF0, 0x29, // I = font of V[0]
+		0xD1, 0x15, // Draw at (V[1], V[1])
+		0x12, 0x06, // Jump to 0x206
+	})
+	err := runHeadless(&cpu, 10, input.NewScript([]input.Event{{Frame: 5, Key: 0x7, Pressed: true}}), nil, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(0x7), cpu.Register.V[0])
+	assert.Equal(t, strings.Repeat(".", 64), strings.Split(displayASCII(&cpu), "\n")[5])
+	assert.Equal(t, "####"+strings.Repeat(".", 60), strings.Split(displayASCII(&cpu), "\n")[0])
+
+	cpu = chip8.NewCPU()
+	err = runHeadless(&cpu, 10, nil, nil, nil, nil)
+	var unknownOpcode chip8.ErrUnknownOpcode
+	assert.True(t, errors.As(err, &unknownOpcode))
+}
+
+func TestRunCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gochip8")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	pngPath := filepath.Join(dir, "display.png")
+	asciiPath := filepath.Join(dir, "display.txt")
+	registersPath := filepath.Join(dir, "registers.json")
+	wavPath := filepath.Join(dir, "sound.wav")
+	err = runCommand([]string{"-headless", "-rom", "../../roms/PONG", "-frames", "30",
+		"-png", pngPath, "-ascii", asciiPath, "-registers", registersPath, "-wav", wavPath})
+	assert.Nil(t, err)
+
+	f, err := os.Open(pngPath)
+	assert.Nil(t, err)
+	img, err := png.Decode(f)
+	_ = f.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, chip8.DisplayWidth, img.Bounds().Dx())
+
+	ascii, err := ioutil.ReadFile(asciiPath)
+	assert.Nil(t, err)
+	assert.Equal(t, chip8.DisplayHeight, strings.Count(string(ascii), "\n"))
+	assert.Contains(t, string(ascii), "#")
+
+	data, err := ioutil.ReadFile(registersPath)
+	assert.Nil(t, err)
+	var regs registers
+	assert.Nil(t, json.Unmarshal(data, &regs))
+	assert.Len(t, regs.V, 16)
+	assert.Equal(t, 0x3F, regs.V[0xC])
+
+	// 30 frames of 800 stereo 16-bit samples after the header
+	info, err := os.Stat(wavPath)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(44+30*800*4), info.Size())
+
+	assert.NotNil(t, runCommand([]string{"-rom", "../../roms/PONG"}))
+	assert.NotNil(t, runCommand([]string{"-headless"}))
+	assert.NotNil(t, runCommand([]string{"-headless", "-rom", "../../roms/PONG", "-wav", wavPath, "-waveform", "saw"}))
+}
+
+func TestRunCommandMovie(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gochip8")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	inputPath := filepath.Join(dir, "input.txt")
+	moviePath := filepath.Join(dir, "pong.json")
+	assert.Nil(t, ioutil.WriteFile(inputPath, []byte("10 1 down\n40 1 up\n50 4 down\n"), 0644))
+	err = runCommand([]string{"-headless", "-rom", "../../roms/PONG", "-frames", "120", "-seed", "5",
+		"-input", inputPath, "-record", moviePath})
+	assert.Nil(t, err)
+
+	// The movie brings its own seed and input
+	assert.Nil(t, runCommand([]string{"-headless", "-rom", "../../roms/PONG", "-play", moviePath}))
+	assert.NotNil(t, runCommand([]string{"-headless", "-rom", "../../roms/BRIX", "-play", moviePath}))
+	assert.NotNil(t, runCommand([]string{"-headless", "-rom", "../../roms/PONG", "-play", moviePath, "-input", inputPath}))
+
+	// The first frame that differs from the recording is reported
+	m, err := movie.Load(moviePath)
+	assert.Nil(t, err)
+	m.Hashes[30]++
+	m.Hashes[60]++
+	assert.Nil(t, m.Save(moviePath))
+	err = runCommand([]string{"-headless", "-rom", "../../roms/PONG", "-play", moviePath})
+	var desync movie.ErrDesync
+	assert.True(t, errors.As(err, &desync))
+	assert.Equal(t, 30, desync.Frame)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"GoCHIP-8/chip8/input"
+	"GoCHIP-8/chip8/keymap"
+	"fmt"
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+	"log"
+	"os"
+)
+
+// The gamepad profile of a ROM is its default profile from input.Profile, overridden by the gamepad keymap
+// stored next to the ROM as <rom>.gamepad, which maps CHIP-8 keys to gamepad control names.
+
+var gamepadIDs []int // Gamepads connected at the previous update
+
+// gamepad reads an ebiten gamepad by its ID
+type gamepad int
+
+func (id gamepad) Button(index int) bool {
+	return index < ebiten.GamepadButtonNum(int(id)) && ebiten.IsGamepadButtonPressed(int(id), ebiten.GamepadButton(index))
+}
+
+func (id gamepad) Axis(index int) float64 {
+	if index >= ebiten.GamepadAxisNum(int(id)) {
+		return 0
+	}
+	return ebiten.GamepadAxis(int(id), index)
+}
+
+func connectedGamepads() []input.Gamepad {
+	var pads []input.Gamepad
+	for _, id := range ebiten.GamepadIDs() {
+		pads = append(pads, gamepad(id))
+	}
+	return pads
+}
+
+func romGamepadPath() string {
+	return romPath + ".gamepad"
+}
+
+// setupGamepads creates the gamepad input source with the profile of the ROM
+func setupGamepads() (*input.GamepadSource, error) {
+	profile := input.Profile(romPath)
+	override, err := keymap.Load(romGamepadPath())
+	if err == nil {
+		if err := override.Check(input.KnownControl, nil); err != nil {
+			return nil, fmt.Errorf("%s: %w", romGamepadPath(), err)
+		}
+		profile = profile.Merge(override)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	source, err := input.NewGamepadSource(profile, connectedGamepads)
+	if err != nil {
+		return nil, err
+	}
+	source.Deadzone = deadzone
+	return source, nil
+}
+
+// updateGamepads reports the gamepads plugged and unplugged, they are polled whenever they are connected
+func updateGamepads() {
+	for _, id := range inpututil.JustConnectedGamepadIDs() {
+		log.Printf("Gamepad %d connected: %s\n", id, ebiten.GamepadName(id))
+		showMessage("Gamepad connected: "+ebiten.GamepadName(id), nil)
+	}
+	for _, id := range gamepadIDs {
+		if inpututil.IsGamepadJustDisconnected(id) {
+			log.Printf("Gamepad %d disconnected\n", id)
+			showMessage("Gamepad disconnected", nil)
+		}
+	}
+	gamepadIDs = ebiten.GamepadIDs()
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"GoCHIP-8/chip8/keymap"
+	"fmt"
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The global keymap is read from -keymap, or from GoCHIP-8/keymap.json in the user config directory when it
+// exists. A ROM keymap stored next to the ROM as <rom>.keymap overrides the CHIP-8 keys it maps.
+
+// reservedKeys are the lowercase names of the keys used by the emulator, they can't press CHIP-8 keys
+var reservedKeys = map[string]string{
+	"escape":    "exit",
+	"p":         "pause",
+	"n":         "step",
+	"shift":     "step over and save states",
+	"i":         "reset",
+	"backspace": "rewind",
+	"f10":       "rebinding",
+	"enter":     "rebinding",
+}
+
+func init() {
+	for i := range slotKeys {
+		reservedKeys[fmt.Sprintf("f%d", i+1)] = "save states"
+	}
+}
+
+// rebindKey opens the rebinding screen
+const rebindKey = ebiten.KeyF10
+
+var (
+	keys         keymap.Keymap // Keymap in use, the global keymap merged with the ROM keymap
+	hostKeys     map[string]ebiten.Key
+	rebinding    bool
+	rebindKeys   keymap.Keymap // Keymap edited by the rebinding screen
+	rebindIndex  int           // Index in keymap.Keypad of the CHIP-8 key being bound
+	rebindPushed bool          // Set once a host key was pressed for the CHIP-8 key being bound
+	rebindError  string
+)
+
+// hostKey returns the ebiten key by its name, ignoring case
+func hostKey(name string) (ebiten.Key, bool) {
+	if hostKeys == nil {
+		hostKeys = make(map[string]ebiten.Key)
+		for key := ebiten.Key(0); key <= ebiten.KeyMax; key++ {
+			hostKeys[strings.ToLower(key.String())] = key
+		}
+	}
+	key, ok := hostKeys[strings.ToLower(name)]
+	return key, ok
+}
+
+func romKeymapPath() string {
+	return romPath + ".keymap"
+}
+
+func globalKeymapPath() string {
+	if keymapPath != "" {
+		return keymapPath
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "GoCHIP-8", "keymap.json")
+}
+
+// loadKeymap reads the global and ROM keymaps. Only the keymap named by -keymap must exist.
+func loadKeymap() (keymap.Keymap, error) {
+	k := keymap.Default()
+	for _, path := range []string{globalKeymapPath(), romKeymapPath()} {
+		if path == "" {
+			continue
+		}
+		override, err := keymap.Load(path)
+		if os.IsNotExist(err) && path != keymapPath {
+			continue
+		}
+		if err != nil {
+			return k, err
+		}
+		if err := override.Check(func(name string) bool {
+			_, ok := hostKey(name)
+			return ok
+		}, reservedKeys); err != nil {
+			return k, fmt.Errorf("%s: %w", path, err)
+		}
+		k = k.Merge(override)
+	}
+	return k, nil
+}
+
+// setupKeys maps the host keys to the CHIP-8 keys
+func setupKeys(k keymap.Keymap) {
+	keys = k
+	keyMap = make(map[ebiten.Key]byte)
+	for value, names := range k {
+		for _, name := range names {
+			if key, ok := hostKey(name); ok {
+				keyMap[key] = byte(value)
+			}
+		}
+	}
+}
+
+// keyboard is the input source of the host keys mapped by keyMap
+type keyboard struct{}
+
+func (keyboard) Poll(keys *[16]bool) {
+	for key, value := range keyMap {
+		if ebiten.IsKeyPressed(key) {
+			keys[value] = true
+		}
+	}
+}
+
+func startRebinding() {
+	rebinding = true
+	rebindKeys = keys
+	rebindIndex = 0
+	rebindPushed = false
+	rebindError = ""
+}
+
+// updateRebinding binds the host keys pressed to the CHIP-8 keys one after another. The first key pressed
+// replaces the host keys of the CHIP-8 key and the next ones are added, Enter goes to the next CHIP-8 key.
+// Once all keys are bound the keymap is saved as the ROM keymap.
+func updateRebinding() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		rebinding = false
+		showMessage("Rebinding cancelled", nil)
+		return
+	}
+	value := keymap.Keypad[rebindIndex]
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		rebindIndex++
+		rebindPushed = false
+		rebindError = ""
+		if rebindIndex == len(keymap.Keypad) {
+			rebinding = false
+			saveRebinding()
+		}
+		return
+	}
+	for key := ebiten.Key(0); key <= ebiten.KeyMax; key++ {
+		if !inpututil.IsKeyJustPressed(key) || key == rebindKey {
+			continue
+		}
+		name := key.String()
+		if action, ok := reservedKeys[strings.ToLower(name)]; ok {
+			rebindError = fmt.Sprintf("%s is reserved to %s", name, action)
+			continue
+		}
+		if !rebindPushed {
+			rebindKeys[value] = nil
+			rebindPushed = true
+		}
+		rebindKeys.Bind(value, name)
+		rebindError = ""
+	}
+}
+
+func saveRebinding() {
+	setupKeys(rebindKeys)
+	if err := rebindKeys.Save(romKeymapPath()); err != nil {
+		log.Printf("Failed to save keymap: %s\n", err)
+		showMessage("Failed to save keymap", nil)
+		return
+	}
+	showMessage("Saved keymap to "+romKeymapPath(), nil)
+}
+
+// rebindText returns the keypad with the host keys of every CHIP-8 key
+func rebindText() string {
+	var sb strings.Builder
+	sb.WriteString("Rebind keys\nPress host keys, Enter: next key, Escape: cancel\n")
+	for i, value := range keymap.Keypad {
+		if i%4 == 0 {
+			sb.WriteString("\n")
+		}
+		marker := " "
+		if i == rebindIndex {
+			marker = ">"
+		}
+		sb.WriteString(fmt.Sprintf("%s%X: %-20s", marker, value, strings.Join(rebindKeys[value], ",")))
+	}
+	if rebindError != "" {
+		sb.WriteString("\n\n" + rebindError)
+	}
+	return sb.String()
+}
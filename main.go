@@ -2,50 +2,123 @@ package main
 
 import (
 	"GoCHIP-8/chip8"
+	"GoCHIP-8/chip8/debugger"
+	"GoCHIP-8/chip8/input"
+	"GoCHIP-8/chip8/movie"
+	"GoCHIP-8/chip8/sound"
+	"GoCHIP-8/chip8/trace"
 	"flag"
 	"fmt"
 	"github.com/hajimehoshi/ebiten"
 	"github.com/hajimehoshi/ebiten/audio"
-	"github.com/hajimehoshi/ebiten/audio/mp3"
 	"github.com/hajimehoshi/ebiten/ebitenutil"
 	"github.com/hajimehoshi/ebiten/inpututil"
+	"image"
 	"image/color"
+	"io/ioutil"
 	"log"
-	"math/rand"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// sampleRate of the audio context
+const sampleRate = 48000
+
 var (
 	cpu         chip8.CPU
 	audioPlayer *audio.Player
+	beep        *sound.Stream
 	keyMap      map[ebiten.Key]byte
+	deadzone    float64
+	sources     []input.Source // Keyboard and gamepads, polled at the start of every frame
 	view        *ebiten.Image
 	romPath     string
 	pixelColor  string
+	paletteStr  string
+	quirksName  string
+	memoryStr   string
+	protectStr  string
 	fullScreen  bool
 	showHelp    bool
 	mute        bool
-	counter     float64
+	waveform    string
+	frequency   float64
+	volume      float64
 	clockSpeed  int
-	r, g, b     uint8 // Pixel color RGB
+	seed        int64
+	rewindSecs  int
+	rewind      *chip8.RewindBuffer
+	palette     [4]color.RGBA // Colors of the four XO-CHIP plane combinations
 	paused      bool
 	debug       bool
+	breakStr    string
+	dbg         *debugger.Debugger
+	keymapPath  string
+	tracePath   string
+	tracePC     string
+	traceOps    string
+	tracer      *trace.Writer
+	recordPath  string
+	playPath    string
+	recorder    *movie.Recorder
+	player      *movie.Player
+	desynced    bool  // Set when the movie played back went differently, only the first desync is reported
+	fault       error // Last CPU fault, emulation is halted until reset
+	// Keys of the save state slots, press to load and hold shift to save
+	slotKeys = []ebiten.Key{
+		ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3,
+		ebiten.KeyF4, ebiten.KeyF5, ebiten.KeyF6,
+		ebiten.KeyF7, ebiten.KeyF8, ebiten.KeyF9,
+	}
+	message          string        // Message shown on top of the display
+	messageThumbnail *ebiten.Image // Save state thumbnail shown with the message
+	messageFrames    int           // Remaining frames to show the message
 )
 
 func init() {
-	rand.Seed(time.Now().UnixNano())
 	flag.StringVar(&romPath, "rom", "roms/PONG", "The `path` to ROM")
 	flag.StringVar(&pixelColor, "color", "white", "Pixel `color`: white, red, green, blue, yellow, pink, cyan")
+	flag.StringVar(&paletteStr, "palette", "", "Four comma separated hex `colors` for background, plane 1, plane 2 and both planes, overrides -color")
+	flag.StringVar(&quirksName, "quirks", chip8.DefaultQuirksPreset, "Quirks `preset`: original, vip, chip48, schip, xochip")
 	flag.IntVar(&clockSpeed, "clock", 400, "CPU `clock speed` in Hz")
+	flag.StringVar(&memoryStr, "memory", "fault", "`Policy` of memory accesses past the end of memory: fault, wrap or log")
+	flag.StringVar(&protectStr, "protect", "", "Comma separated memory `areas` to write protect: interpreter, rom")
+	flag.Int64Var(&seed, "seed", 0, "`Seed` of the random numbers of CXNN, picked from the clock when 0")
+	flag.IntVar(&rewindSecs, "rewind", 10, "`Seconds` of rewind history, 0 to disable")
+	flag.StringVar(&keymapPath, "keymap", "", "Global keymap `file`, GoCHIP-8/keymap.json in the user config directory by default")
+	flag.Float64Var(&deadzone, "deadzone", 0.5, "How far from 0 to 1 a gamepad stick must be pushed to press a key")
 	flag.BoolVar(&mute, "mute", false, "Mute")
-	flag.BoolVar(&debug, "debug", false, "Debug mode")
+	flag.StringVar(&waveform, "waveform", "square", "`Waveform` of the sound: square, sine or triangle")
+	flag.Float64Var(&frequency, "frequency", 440, "`Frequency` of the sound in Hz")
+	flag.Float64Var(&volume, "volume", 0.5, "`Volume` of the sound from 0 to 1")
+	flag.BoolVar(&debug, "debug", false, "Debug mode, show the registers and the next instructions while paused")
+	flag.StringVar(&breakStr, "break", "", "Comma separated breakpoint `addresses`, emulation pauses when one is hit")
+	flag.StringVar(&tracePath, "trace", "", "Write every executed instruction to `file`, as JSON Lines for .jsonl, binary for .bin and text otherwise")
+	flag.StringVar(&tracePC, "trace-pc", "", "Only trace the instructions in the PC `range`, like 0x200-0x2FF")
+	flag.StringVar(&traceOps, "trace-ops", "", "Only trace the comma separated opcode `classes`, like DXYN,F")
+	flag.StringVar(&recordPath, "record", "", "Record the input, seed and quirks to a movie `file`, saved on exit")
+	flag.StringVar(&playPath, "play", "", "Play the movie `file` back, the keyboard takes over at its end")
 	flag.BoolVar(&fullScreen, "full", false, "Full screen")
 	flag.BoolVar(&showHelp, "h", false, "Show help")
 	flag.Usage = usage
 	flag.Parse()
 	view, _ = ebiten.NewImage(chip8.DisplayWidth*10, chip8.DisplayHeight*10, ebiten.FilterDefault)
-	r, g, b = parsePixelColor()
+	r, g, b := parsePixelColor()
+	palette = [4]color.RGBA{
+		{R: 0, G: 0, B: 0, A: 255},
+		{R: r, G: g, B: b, A: 255},
+		{R: 0xFF, G: 0x66, B: 0x00, A: 255},
+		{R: 0x66, G: 0x22, B: 0x00, A: 255},
+	}
+	if paletteStr != "" {
+		var err error
+		palette, err = parsePalette(paletteStr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
 }
 
 func parsePixelColor() (r, g, b uint8) {
@@ -70,49 +143,76 @@ func parsePixelColor() (r, g, b uint8) {
 	return
 }
 
-/*
-       Chip-8                       Keyboard
-+────+────+────+────+       +────+────+────+────+
-| 1  | 2  | 3  | C  |       | 1  | 2  | 3  | 4  |
-+────+────+────+────+       +────+────+────+────+
-| 4  | 5  | 6  | D  |       | Q  | W  | E  | R  |
-+────+────+────+────+  <=>  +────+────+────+────+
-| 7  | 8  | 9  | E  |       | A  | S  | D  | F  |
-+────+────+────+────+       +────+────+────+────+
-| A  | 0  | B  | F  |       | Z  | X  | C  | V  |
-+────+────+────+────+       +────+────+────+────+
-*/
-func setupKeys() {
-	keyMap = make(map[ebiten.Key]byte)
-	keyMap[ebiten.Key1] = 0x01
-	keyMap[ebiten.Key2] = 0x02
-	keyMap[ebiten.Key3] = 0x03
-	keyMap[ebiten.Key4] = 0x0C
+// parsePalette parses four comma separated hex colors like "#000000,#FFFFFF,#FF6600,#662200"
+func parsePalette(str string) (palette [4]color.RGBA, err error) {
+	colors := strings.Split(str, ",")
+	if len(colors) != len(palette) {
+		return palette, fmt.Errorf("palette must have %d colors, got %d", len(palette), len(colors))
+	}
+	for i, c := range colors {
+		value, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(c), "#"), 16, 24)
+		if err != nil {
+			return palette, fmt.Errorf("invalid palette color %q", c)
+		}
+		palette[i] = color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 255}
+	}
+	return palette, nil
+}
 
-	keyMap[ebiten.KeyQ] = 0x04
-	keyMap[ebiten.KeyW] = 0x05
-	keyMap[ebiten.KeyE] = 0x06
-	keyMap[ebiten.KeyR] = 0x0D
+// pollKeys sets the state of the keys from the keyboard and gamepads, or from the movie being played
+func pollKeys() {
+	if player != nil {
+		player.StartFrame(&cpu)
+	} else {
+		input.Poll(&cpu, sources...)
+	}
+	if recorder != nil {
+		recorder.StartFrame(&cpu)
+	}
+}
 
-	keyMap[ebiten.KeyA] = 0x07
-	keyMap[ebiten.KeyS] = 0x08
-	keyMap[ebiten.KeyD] = 0x09
-	keyMap[ebiten.KeyF] = 0x0E
+// endFrame checks and records the frame that just ended, then polls the keys of the next one
+func endFrame() {
+	if recorder != nil {
+		recorder.EndFrame(&cpu)
+	}
+	if player != nil {
+		if err := player.EndFrame(&cpu); err != nil && !desynced {
+			desynced = true
+			log.Println(err)
+			showMessage(fmt.Sprintf("Movie desync at frame %d", player.Frame()-1), nil)
+		}
+		if player.Done() {
+			log.Printf("Movie ended after %d frames\n", player.Frame())
+			if !desynced {
+				showMessage("Movie ended", nil)
+			}
+			player = nil
+		}
+	}
+	pollKeys()
+}
 
-	keyMap[ebiten.KeyZ] = 0x0A
-	keyMap[ebiten.KeyX] = 0x00
-	keyMap[ebiten.KeyC] = 0x0B
-	keyMap[ebiten.KeyV] = 0x0F
+// movieActive reports whether a movie is recorded or played, the machine state must then only change by running
+func movieActive() bool {
+	return recorder != nil || player != nil
 }
 
-func getPressedKeys() bool {
-	for key, value := range keyMap {
-		if ebiten.IsKeyPressed(key) {
-			cpu.KeyState[value] = 0x01
-			return true
+// quit saves the recorded movie and the trace, then exits
+func quit(code int) {
+	if tracer != nil {
+		if err := tracer.Close(); err != nil {
+			log.Printf("Failed to write trace: %s\n", err)
+		}
+	}
+	if recorder != nil {
+		if err := recorder.Movie.Save(recordPath); err != nil {
+			log.Printf("Failed to save movie: %s\n", err)
+		} else {
+			log.Printf("Saved movie of %d frames to %s\n", recorder.Movie.Frames(), recordPath)
 		}
 	}
-	return false
+	os.Exit(code)
 }
 
 type Game struct{}
@@ -124,16 +224,15 @@ func (game *Game) Layout(int, int) (screenWidth, screenHeight int) {
 
 func (game *Game) Draw(screen *ebiten.Image) {
 	if cpu.NeedDraw {
-		_ = view.Fill(color.Black)
-		for i := 0; i < chip8.DisplayHeight; i++ {
-			for j := 0; j < chip8.DisplayWidth; j++ {
-				if cpu.Display[i][j] == 0x01 {
-					ebitenutil.DrawRect(view, float64(j*10), float64(i*10), 10, 10, color.RGBA{
-						R: r,
-						G: g,
-						B: b,
-						A: 255,
-					})
+		_ = view.Fill(palette[0])
+		width, height := cpu.DisplaySize()
+		// Scale either resolution to fill the view
+		scale := chip8.DisplayWidth * 10 / width
+		for i := 0; i < height; i++ {
+			for j := 0; j < width; j++ {
+				// Each pixel holds one bit per plane
+				if planes := cpu.Display[i][j] & 0x03; planes != 0 {
+					ebitenutil.DrawRect(view, float64(j*scale), float64(i*scale), float64(scale), float64(scale), palette[planes])
 				}
 			}
 		}
@@ -141,115 +240,300 @@ func (game *Game) Draw(screen *ebiten.Image) {
 	}
 	opts := &ebiten.DrawImageOptions{}
 	_ = screen.DrawImage(view, opts)
+	if rebinding {
+		_ = ebitenutil.DebugPrint(screen, rebindText())
+	} else if fault != nil {
+		_ = ebitenutil.DebugPrint(screen, fmt.Sprintf("CPU fault: %s\nPress I to reset", fault))
+	} else if cpu.Exited {
+		_ = ebitenutil.DebugPrint(screen, "Program exited\nPress I to reset")
+	} else if messageFrames > 0 {
+		_ = ebitenutil.DebugPrint(screen, message)
+	} else if debug && paused {
+		_ = ebitenutil.DebugPrint(screen, debugText())
+	}
+	if messageFrames > 0 && messageThumbnail != nil {
+		width, _ := messageThumbnail.Size()
+		thumbnailOpts := &ebiten.DrawImageOptions{}
+		thumbnailOpts.GeoM.Scale(float64(chip8.DisplayWidth*3)/float64(width), float64(chip8.DisplayWidth*3)/float64(width))
+		thumbnailOpts.GeoM.Translate(float64(chip8.DisplayWidth*7-10), 10)
+		_ = screen.DrawImage(messageThumbnail, thumbnailOpts)
+	}
+}
+
+// showMessage shows a message and an optional thumbnail on top of the display for two seconds
+func showMessage(msg string, thumbnail image.Image) {
+	message = msg
+	messageThumbnail = nil
+	if thumbnail != nil {
+		messageThumbnail, _ = ebiten.NewImageFromImage(thumbnail, ebiten.FilterNearest)
+	}
+	messageFrames = chip8.TimerFrequency * 2
+}
+
+func updateSlots() {
+	for i, key := range slotKeys {
+		if !inpututil.IsKeyJustPressed(key) {
+			continue
+		}
+		slot := i + 1
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			thumbnail, err := saveSlot(slot)
+			if err != nil {
+				log.Printf("Failed to save slot %d: %s\n", slot, err)
+				showMessage(fmt.Sprintf("Failed to save slot %d", slot), nil)
+			} else {
+				showMessage(fmt.Sprintf("Saved slot %d", slot), thumbnail)
+			}
+		} else if movieActive() {
+			showMessage("Loading is disabled while a movie is recorded or played", nil)
+		} else {
+			thumbnail, err := loadSlot(slot)
+			if err != nil {
+				log.Printf("Failed to load slot %d: %s\n", slot, err)
+				showMessage(fmt.Sprintf("Failed to load slot %d", slot), nil)
+			} else {
+				fault = nil
+				if rewind != nil {
+					rewind.Reset()
+				}
+				showMessage(fmt.Sprintf("Loaded slot %d", slot), thumbnail)
+			}
+		}
+	}
+	if messageFrames > 0 {
+		messageFrames--
+	}
 }
 
 func (game *Game) Update(*ebiten.Image) error {
+	if rebinding {
+		// Emulation is suspended while rebinding, Escape cancels instead of exiting
+		updateRebinding()
+		return nil
+	}
+	updateGamepads()
+	if inpututil.IsKeyJustPressed(rebindKey) {
+		startRebinding()
+		return nil
+	}
+
 	if ebiten.IsKeyPressed(ebiten.KeyEscape) {
-		os.Exit(0)
+		quit(0)
 	}
 
 	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
 		paused = !paused
 	}
 
-	if paused && inpututil.IsKeyJustPressed(ebiten.KeyN) {
-		step()
-	}
+	updateSlots()
 
-	if !paused {
-		for counter > 0 {
-			step()
-			counter -= float64(ebiten.MaxTPS())
+	if fault == nil && paused && inpututil.IsKeyJustPressed(ebiten.KeyN) {
+		// Hold shift to step over subroutine calls
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			handleStop(dbg.StepOver())
+		} else {
+			handleStop(dbg.Step())
 		}
-		counter += float64(clockSpeed)
 	}
 
-	if !mute && cpu.Register.ST > 0 {
-		if audioPlayer != nil {
-			err := audioPlayer.Play()
-			if err != nil {
-				return err
-			}
-			err = audioPlayer.Rewind()
-			if err != nil {
-				return err
+	if rewind != nil && ebiten.IsKeyPressed(ebiten.KeyBackspace) {
+		// Play backwards one frame per update while the key is held
+		ok, err := rewind.Rewind(&cpu)
+		if err != nil {
+			log.Printf("Failed to rewind: %s\n", err)
+		}
+		if ok {
+			fault = nil
+		}
+	} else if fault == nil && !paused {
+		// Update is called at 60 TPS and the debugger ticks the timers at the end of each frame,
+		// so the timers run at 60 Hz whatever the clock speed is
+		if stop := dbg.Frame(); stop.Reason == debugger.StopFrame {
+			if rewind != nil {
+				if err := rewind.Push(&cpu); err != nil {
+					log.Printf("Failed to record rewind history: %s\n", err)
+				}
 			}
+		} else {
+			handleStop(stop)
+		}
+	}
+
+	if beep != nil {
+		// The sound streams all along and is heard while the sound timer is active
+		if paused || fault != nil {
+			beep.SetGate(false)
+		} else {
+			beep.Update(&cpu)
 		}
 	}
 
-	if ebiten.IsKeyPressed(ebiten.KeyI) {
+	if ebiten.IsKeyPressed(ebiten.KeyI) && !movieActive() {
 		cpu.Reset()
 		err := cpu.LoadROM(romPath)
 		if err != nil {
 			return err
 		}
 		paused = false
+		fault = nil
+		if rewind != nil {
+			rewind.Reset()
+		}
 	}
 
 	return nil
 }
 
-func step() {
-	cpu.WaitInput = false
-	if debug {
-		cpu.Debug()
-	}
-	cpu.Run()
-	if cpu.WaitInput {
-		if !getPressedKeys() {
-			cpu.Register.PC -= 2
+// debugText returns the registers, the return addresses on the stack and the next instructions
+func debugText() string {
+	text := dbg.Registers()
+	if calls := dbg.CallStack(); len(calls) > 0 {
+		text += "Returns:"
+		for _, call := range calls {
+			text += fmt.Sprintf(" %03X", call.Return)
 		}
+		text += "\n"
+	}
+	addr := cpu.Register.PC
+	for i := 0; i < 4 && int(addr)+1 < len(cpu.Memory.Memory); i++ {
+		line, size := dbg.Disassemble(addr)
+		text += line + "\n"
+		addr += size
 	}
+	return text
+}
 
-	for key, value := range keyMap {
-		if ebiten.IsKeyPressed(key) {
-			cpu.KeyState[value] = 0x01
-		} else {
-			cpu.KeyState[value] = 0x00
-		}
+// handleStop pauses emulation at breakpoints and watchpoints, and halts it on faults
+func handleStop(stop debugger.Stop) {
+	switch stop.Reason {
+	case debugger.StopBreakpoint, debugger.StopWatchpoint:
+		paused = true
+		log.Println(stop)
+		showMessage("Paused at "+stop.String(), nil)
+	case debugger.StopFault:
+		fault = stop.Err
+	}
+}
+
+// step executes an instruction, FX0A keeps PC on itself until a key completes it
+func step() error {
+	if err := cpu.Cycle(); err != nil {
+		log.Printf("CPU fault: %s\n", err)
+		return err
 	}
+	return nil
 }
 
 func Run() {
 	var err error
-	cpu = chip8.NewCPU()
-	err = cpu.LoadROM(romPath)
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+		log.Printf("Random seed %d, pass -seed %d to replay the same random numbers\n", seed, seed)
+	}
+	cpu, err = chip8.Options{
+		Quirks:  quirksName,
+		Clock:   clockSpeed,
+		Seed:    seed,
+		Memory:  memoryStr,
+		Protect: protectStr,
+		Log: func(err error) {
+			log.Printf("Ignored memory fault: %s\n", err)
+		},
+	}.NewCPU(romPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if tracePath != "" {
+		filter, err := trace.ParseFilter(tracePC, traceOps)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		tracer, err = trace.Create(tracePath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		tracer.Filter = filter
+		cpu.Tracer = tracer
+	}
+	k, err := loadKeymap()
+	if err != nil {
+		log.Fatalf("Invalid keymap: %s\n", err)
+	}
+	setupKeys(k)
+	gamepads, err := setupGamepads()
 	if err != nil {
-		log.Fatalln("Failed to load rom")
+		log.Fatalf("Invalid gamepad profile: %s\n", err)
+	}
+	sources = []input.Source{keyboard{}, gamepads}
+	if recordPath != "" || playPath != "" {
+		rom, err := ioutil.ReadFile(romPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if playPath != "" {
+			m, err := movie.Load(playPath)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			player, err = movie.NewPlayer(m, &cpu, rom)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+		if recordPath != "" {
+			recorder = movie.NewRecorder(&cpu, rom)
+		}
+		// Rewinding would change the machine state behind the movie
+		rewindSecs = 0
+	}
+	pollKeys()
+	dbg = debugger.New(&cpu)
+	dbg.Cycle = step
+	dbg.FrameEnd = endFrame
+	if breakStr != "" {
+		for _, addr := range strings.Split(breakStr, ",") {
+			value, err := strconv.ParseUint(strings.TrimSpace(addr), 0, 16)
+			if err != nil {
+				log.Fatalf("Invalid breakpoint address %q\n", addr)
+			}
+			_, _ = dbg.SetBreakpoint(uint16(value), "")
+		}
+	}
+	if rewindSecs > 0 {
+		rewind = chip8.NewRewindBuffer(rewindSecs * chip8.TimerFrequency)
 	}
-	setupKeys()
 	if !mute {
-		audioContext, err := audio.NewContext(48000)
+		wave, err := sound.ParseWaveform(waveform)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		audioContext, err := audio.NewContext(sampleRate)
 		if err != nil {
 			log.Println("Failed to create audio context")
 		} else {
-			f, err := ebitenutil.OpenFile("assets/beep.mp3")
+			beep = sound.NewStream(sampleRate, sound.NewVoice(sound.NewTone(wave, frequency)), volume)
+			audioPlayer, err = audio.NewPlayer(audioContext, beep)
 			if err != nil {
-				log.Println("Failed to load assets/beep.mp3")
+				log.Println("Failed to create audio player")
 			} else {
-				d, err := mp3.Decode(audioContext, f)
-				if err != nil {
-					log.Println("Failed to decode MP3 file")
-				} else {
-					audioPlayer, err = audio.NewPlayer(audioContext, d)
-					if err != nil {
-						log.Println("Failed to create audio player")
-					}
-				}
+				_ = audioPlayer.Play()
 			}
 		}
 	}
+	ebiten.SetMaxTPS(chip8.TimerFrequency)
 	ebiten.SetFullscreen(fullScreen)
 	ebiten.SetWindowSize(chip8.DisplayWidth*10, chip8.DisplayHeight*10)
 	ebiten.SetWindowTitle(fmt.Sprintf("GoCHIP-8 | %s", romPath))
 	if err := ebiten.RunGame(&Game{}); err != nil {
-		panic(err)
+		log.Println(err)
+		quit(1)
 	}
+	quit(0)
 }
 
 func usage() {
 	_, _ = fmt.Fprintf(os.Stderr, `GoCHIP-8
-Usage: ./GoCHIP-8 <-path pathToROM> [-clock clock_speed] [-color color] [-mute] [-full]
+Usage: ./GoCHIP-8 <-path pathToROM> [-clock clock_speed] [-quirks preset] [-color color] [-palette colors] [-mute] [-full]
 
 Options:
 `)
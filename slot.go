@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// A save state slot is stored next to the ROM as <rom>.slot<N>. It holds a magic header, the length of the PNG
+// thumbnail of the display, the thumbnail itself and then the CPU save state.
+var slotMagic = [4]byte{'G', 'C', '8', 'T'}
+
+// maxThumbnailSize bounds the thumbnail length read from a slot, the PNG of a 128x64 display takes a few KiB
+const maxThumbnailSize = 64 << 10
+
+func slotPath(slot int) string {
+	return fmt.Sprintf("%s.slot%d", romPath, slot)
+}
+
+func colorPalette() color.Palette {
+	return color.Palette{palette[0], palette[1], palette[2], palette[3]}
+}
+
+// saveSlot writes the CPU state to the given slot and returns its thumbnail
+func saveSlot(slot int) (image.Image, error) {
+	thumbnail := cpu.DisplayImage(colorPalette())
+	var thumbnailBuf bytes.Buffer
+	if err := png.Encode(&thumbnailBuf, thumbnail); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.Write(slotMagic[:])
+	_ = binary.Write(&buf, binary.BigEndian, uint32(thumbnailBuf.Len()))
+	buf.Write(thumbnailBuf.Bytes())
+	if err := cpu.SaveState(&buf); err != nil {
+		return nil, err
+	}
+	// Write the whole slot at once so a failed save does not corrupt an existing slot
+	if err := ioutil.WriteFile(slotPath(slot), buf.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	return thumbnail, nil
+}
+
+// loadSlot restores the CPU state from the given slot and returns its thumbnail
+func loadSlot(slot int) (image.Image, error) {
+	f, err := os.Open(slotPath(slot))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var magic [4]byte
+	if _, err := io.ReadFull(f, magic[:]); err != nil {
+		return nil, err
+	}
+	if magic != slotMagic {
+		return nil, errors.New("not a save state slot")
+	}
+	var length uint32
+	if err := binary.Read(f, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if length > maxThumbnailSize {
+		return nil, fmt.Errorf("save state slot thumbnail is %d bytes long, at most %d expected", length, maxThumbnailSize)
+	}
+	thumbnailBuf := make([]byte, length)
+	if _, err := io.ReadFull(f, thumbnailBuf); err != nil {
+		return nil, err
+	}
+	thumbnail, err := png.Decode(bytes.NewReader(thumbnailBuf))
+	if err != nil {
+		return nil, err
+	}
+	if err := cpu.LoadState(f); err != nil {
+		return nil, err
+	}
+	return thumbnail, nil
+}